@@ -16,6 +16,10 @@
 
 package stompngo
 
+import (
+	"context"
+)
+
 /*
 	Abort a STOMP transaction.
 
@@ -30,7 +34,22 @@ package stompngo
 		}
 */
 func (c *Connection) Abort(h Headers) error {
-	c.log(ABORT, "start", h)
+	return c.AbortContext(context.Background(), h)
+}
+
+/*
+	AbortContext aborts a STOMP transaction, honoring ctx cancellation and
+	deadlines.  See Abort for details.
+*/
+func (c *Connection) AbortContext(ctx context.Context, h Headers) error {
+	return c.abort(ctx, h, false)
+}
+
+/*
+	Abort, waiting for the broker's RECEIPT if rcpt is set.
+*/
+func (c *Connection) abort(ctx context.Context, h Headers, rcpt bool) error {
+	c.logcmd(ABORT, "start", h)
 	if !c.isConnected() {
 		return ECONBAD
 	}
@@ -42,7 +61,15 @@ func (c *Connection) Abort(h Headers) error {
 	if h.Value(HK_TRANSACTION) == "" {
 		return ETIDABTEMT
 	}
-	e := c.transmitCommon(ABORT, h) // transmitCommon Clones() the headers
-	c.log(ABORT, "end", h)
+	e := c.transmitCommon(ctx, ABORT, h, rcpt) // transmitCommon Clones() the headers
+	c.logcmd(ABORT, "end", h)
 	return e
 }
+
+/*
+	AbortWithReceipt aborts a STOMP transaction, and waits for the broker's
+	RECEIPT.  See SendWithReceipt and Abort for details.
+*/
+func (c *Connection) AbortWithReceipt(ctx context.Context, h Headers) error {
+	return c.abort(ctx, h, true)
+}
@@ -16,6 +16,10 @@
 
 package stompngo
 
+import (
+	"context"
+)
+
 /*
 	Ack a STOMP MESSAGE.
 
@@ -40,7 +44,22 @@ package stompngo
 
 */
 func (c *Connection) Ack(h Headers) error {
-	c.log(ACK, "start", h, c.Protocol())
+	return c.AckContext(context.Background(), h)
+}
+
+/*
+	AckContext acks a STOMP MESSAGE, honoring ctx cancellation and deadlines.
+	See Ack for details.
+*/
+func (c *Connection) AckContext(ctx context.Context, h Headers) error {
+	return c.ack(ctx, h, false)
+}
+
+/*
+	Ack, waiting for the broker's RECEIPT if rcpt is set.
+*/
+func (c *Connection) ack(ctx context.Context, h Headers, rcpt bool) error {
+	c.logcmd(ACK, "start", h)
 	if !c.isConnected() {
 		return ECONBAD
 	}
@@ -66,7 +85,18 @@ func (c *Connection) Ack(h Headers) error {
 		}
 	}
 
-	e = c.transmitCommon(ACK, h) // transmitCommon Clones() the headers
-	c.log(ACK, "end", h, c.Protocol())
+	e = c.transmitCommon(ctx, ACK, h, rcpt) // transmitCommon Clones() the headers
+	if e == nil {
+		c.settleAck(h)
+	}
+	c.logcmd(ACK, "end", h)
 	return e
 }
+
+/*
+	AckWithReceipt acks a STOMP MESSAGE, and waits for the broker's RECEIPT.
+	See SendWithReceipt and Ack for details.
+*/
+func (c *Connection) AckWithReceipt(ctx context.Context, h Headers) error {
+	return c.ack(ctx, h, true)
+}
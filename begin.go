@@ -16,6 +16,10 @@
 
 package stompngo
 
+import (
+	"context"
+)
+
 /*
 	Begin a STOMP transaction.
 
@@ -30,7 +34,22 @@ package stompngo
 		}
 */
 func (c *Connection) Begin(h Headers) error {
-	c.log(BEGIN, "start", h)
+	return c.BeginContext(context.Background(), h)
+}
+
+/*
+	BeginContext begins a STOMP transaction, honoring ctx cancellation and
+	deadlines.  See Begin for details.
+*/
+func (c *Connection) BeginContext(ctx context.Context, h Headers) error {
+	return c.begin(ctx, h, false)
+}
+
+/*
+	Begin, waiting for the broker's RECEIPT if rcpt is set.
+*/
+func (c *Connection) begin(ctx context.Context, h Headers, rcpt bool) error {
+	c.logcmd(BEGIN, "start", h)
 	if !c.isConnected() {
 		return ECONBAD
 	}
@@ -42,7 +61,15 @@ func (c *Connection) Begin(h Headers) error {
 	if h.Value(HK_TRANSACTION) == "" {
 		return ETIDBEGEMT
 	}
-	e := c.transmitCommon(BEGIN, h) // transmitCommon Clones() the headers
-	c.log(BEGIN, "end", h)
+	e := c.transmitCommon(ctx, BEGIN, h, rcpt) // transmitCommon Clones() the headers
+	c.logcmd(BEGIN, "end", h)
 	return e
 }
+
+/*
+	BeginWithReceipt begins a STOMP transaction, and waits for the broker's
+	RECEIPT.  See SendWithReceipt and Begin for details.
+*/
+func (c *Connection) BeginWithReceipt(ctx context.Context, h Headers) error {
+	return c.begin(ctx, h, true)
+}
@@ -0,0 +1,337 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"time"
+)
+
+/*
+	CloseOption configures Close.
+*/
+type CloseOption func(*closeConfig)
+
+type closeConfig struct {
+	unsub bool // Unsubscribe before DISCONNECT
+	drain bool // Wait for subscriptions to drain, and be acknowledged
+}
+
+/*
+	CloseUnsubscribe unsubscribes every subscription, waiting for each
+	RECEIPT, before the DISCONNECT.  MESSAGE frames sent by the broker before
+	the RECEIPT are still delivered.
+*/
+func CloseUnsubscribe() CloseOption {
+	return func(cc *closeConfig) {
+		cc.unsub = true
+	}
+}
+
+/*
+	CloseDrain waits until every subscription channel has been emptied by
+	its consumer, and every MESSAGE taken from a client or client-individual
+	subscription has been ACKed or NACKed.  Subscriptions using the
+	FlowDropNewest or FlowDropOldest policies, or StompPlusDrainAfter, are
+	only waited for until their channels are empty.  At most 65536 MESSAGE
+	frames per connection are tracked until acknowledged; later ones are not
+	waited for.
+
+	The broker may keep sending while Close drains, so use a ctx deadline.
+*/
+func CloseDrain() CloseOption {
+	return func(cc *closeConfig) {
+		cc.drain = true
+	}
+}
+
+/*
+	Close shuts the connection down gracefully, within the ctx deadline.
+
+	New operations are refused with ECLOSING, except ACK and NACK.  Close
+	waits for operations in flight, including SendAsync frames, then drains
+	with CloseDrain, and unsubscribes with CloseUnsubscribe.  Last, it sends
+	DISCONNECT with a receipt, and waits for the RECEIPT.
+
+	If ctx is done first, ctx.Err() is returned, and the connection is shut
+	down without waiting further.
+
+	Example:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		e := c.Close(ctx, stompngo.CloseDrain(), stompngo.CloseUnsubscribe())
+		if e != nil {
+			// Do something sane ...
+		}
+
+*/
+func (c *Connection) Close(ctx context.Context, opts ...CloseOption) error {
+	var cc closeConfig
+	for _, o := range opts {
+		o(&cc)
+	}
+	if !c.isConnected() {
+		return ECONBAD
+	}
+	c.opsLock.Lock()
+	if c.clsg {
+		c.opsLock.Unlock()
+		return ECLOSING
+	}
+	c.clsg = true
+	c.opsLock.Unlock()
+	c.log("CLOSE", "starts")
+	//
+	cx := context.WithValue(ctx, closeKey{}, true)
+	e := c.waitOps(ctx)
+	if e == nil && cc.drain {
+		e = c.drain(ctx)
+	}
+	if e == nil && cc.unsub {
+		e = c.unsubscribeAll(cx)
+	}
+	if e != nil {
+		c.log("CLOSE", "abandoned", e)
+		c.discLock.Lock()
+		// As in DisconnectContext, abort before shutdown takes c.subs.
+		c.sysAbort()
+		c.closeNetconn()
+		c.shutdown()
+		c.discLock.Unlock()
+		return e
+	}
+	e = c.DisconnectContext(cx, Headers{})
+	c.log("CLOSE", "ends", e)
+	return e
+}
+
+/*
+	Context key marking operations started by Close.
+*/
+type closeKey struct{}
+
+/*
+	Start an operation, unless Close has started.  ACK, NACK, heart beats,
+	and Close's own frames are always allowed.
+*/
+func (c *Connection) opBegin(ctx context.Context, cmd string) error {
+	c.opsLock.Lock()
+	defer c.opsLock.Unlock()
+	if c.clsg {
+		cl, _ := ctx.Value(closeKey{}).(bool)
+		if !cl && cmd != ACK && cmd != NACK && cmd != "\n" {
+			return ECLOSING
+		}
+	}
+	c.ops++
+	return nil
+}
+
+/*
+	End an operation.
+*/
+func (c *Connection) opEnd() {
+	c.opsLock.Lock()
+	c.ops--
+	if c.ops == 0 && c.opsIdle != nil {
+		close(c.opsIdle)
+		c.opsIdle = nil
+	}
+	c.opsLock.Unlock()
+}
+
+/*
+	Wait for operations in flight to end.
+*/
+func (c *Connection) waitOps(ctx context.Context) error {
+	c.opsLock.Lock()
+	if c.ops == 0 {
+		c.opsLock.Unlock()
+		return nil
+	}
+	if c.opsIdle == nil {
+		c.opsIdle = make(chan struct{})
+	}
+	ic := c.opsIdle
+	c.opsLock.Unlock()
+	select {
+	case <-ic:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+	Unsubscribe every subscription, waiting for each RECEIPT.
+*/
+func (c *Connection) unsubscribeAll(ctx context.Context) error {
+	c.subsLock.RLock()
+	sds := make([]*subscription, 0, len(c.subs))
+	for _, sd := range c.subs {
+		if !sd.brkr {
+			sds = append(sds, sd)
+		}
+	}
+	c.subsLock.RUnlock()
+	for _, sd := range sds {
+		h := Headers{HK_DESTINATION, sd.dest, HK_ID, sd.id}
+		if e := c.UnsubscribeWithReceipt(ctx, h); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+/*
+	Wait for every subscription channel to be empty, with no MESSAGE left
+	unacknowledged, and no operation in flight.
+*/
+func (c *Connection) drain(ctx context.Context) error {
+	t := time.NewTicker(closeDrainTick)
+	defer t.Stop()
+	for !c.drained() {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return c.waitOps(ctx)
+}
+
+/*
+	Check that subscription channels are empty, and nothing is unacked.
+*/
+func (c *Connection) drained() bool {
+	c.subsLock.RLock()
+	defer c.subsLock.RUnlock()
+	for _, sd := range c.subs {
+		if len(sd.md) != 0 || len(sd.ovch) != 0 {
+			return false
+		}
+	}
+	c.ackLock.Lock()
+	defer c.ackLock.Unlock()
+	return len(c.unak) == 0
+}
+
+/*
+	A MESSAGE delivered on a client or client-individual subscription, and
+	not yet ACKed or NACKed.
+*/
+type unacked struct {
+	sd  *subscription
+	seq uint64 // Delivery order
+}
+
+/*
+	An entry in the delivery order queue of a client mode subscription.
+*/
+type ackRef struct {
+	k   string // Ack key
+	seq uint64 // Delivery order
+}
+
+/*
+	The key matching a MESSAGE to its ACK or NACK.  For STOMP 1.2 id names the
+	header holding it: HK_ACK in the MESSAGE, HK_ID in the ACK or NACK.
+*/
+func ackKey(p string, h Headers, id string) string {
+	switch p {
+	case SPL_12:
+		return h.Value(id)
+	case SPL_11:
+		return h.Value(HK_SUBSCRIPTION) + ":" + h.Value(HK_MESSAGE_ID)
+	}
+	return h.Value(HK_MESSAGE_ID)
+}
+
+/*
+	Record a MESSAGE about to be delivered, if it must be acknowledged and can
+	not be dropped.
+*/
+func (c *Connection) trackAck(sd *subscription, h Headers) {
+	if sd.am == AckModeAuto || sd.am == "" {
+		return
+	}
+	if sd.fp != FlowBlock && sd.fp != FlowOverflow {
+		return
+	}
+	k := ackKey(c.Protocol(), h, HK_ACK)
+	c.ackLock.Lock()
+	defer c.ackLock.Unlock()
+	if c.unak == nil {
+		c.unak = make(map[string]unacked)
+	}
+	if len(c.unak) >= closeAckMax {
+		return
+	}
+	c.unsq++
+	c.unak[k] = unacked{sd, c.unsq}
+	if sd.am == AckModeClient {
+		sd.ackq = append(sd.ackq, ackRef{k, c.unsq})
+	}
+}
+
+/*
+	Forget a MESSAGE that has been ACKed or NACKed, and in client mode every
+	MESSAGE before it on the same subscription.
+*/
+func (c *Connection) settleAck(h Headers) {
+	k := ackKey(c.Protocol(), h, HK_ID)
+	c.ackLock.Lock()
+	defer c.ackLock.Unlock()
+	u, ok := c.unak[k]
+	if !ok {
+		return
+	}
+	delete(c.unak, k)
+	if u.sd.am != AckModeClient {
+		return
+	}
+	q := u.sd.ackq
+	for len(q) > 0 && q[0].seq <= u.seq {
+		if v, ok := c.unak[q[0].k]; ok && v.seq == q[0].seq {
+			delete(c.unak, q[0].k)
+		}
+		q = q[1:]
+	}
+	if len(q) == 0 {
+		q = nil
+	}
+	u.sd.ackq = q
+}
+
+/*
+	Forget every MESSAGE of a removed subscription.
+*/
+func (c *Connection) untrackAcks(sd *subscription) {
+	c.ackLock.Lock()
+	for k, v := range c.unak {
+		if v.sd == sd {
+			delete(c.unak, k)
+		}
+	}
+	sd.ackq = nil
+	c.ackLock.Unlock()
+}
+
+const (
+	closeDrainTick = 10 * time.Millisecond // Close drain poll interval
+	closeAckMax    = 1 << 16               // Most MESSAGE frames tracked
+)
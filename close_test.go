@@ -0,0 +1,257 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+/*
+	Test Close refuses new operations, and waits for one in flight.
+*/
+func TestCloseInFlight(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Expect(SEND).Pause(closePause).Receipt().
+		Expect(DISCONNECT).Receipt().WaitClose()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestCloseInFlight Expected [nil], got [%v]\n", e)
+	}
+	sr := make(chan error, 1)
+	go func() {
+		sr <- c.SendWithReceipt(context.Background(), Headers{HK_DESTINATION, closeDest}, "m")
+	}()
+	for !closeBusy(c) {
+		time.Sleep(time.Millisecond)
+	}
+	cr := make(chan error, 1)
+	go func() {
+		cr <- c.Close(context.Background())
+	}()
+	for !closeStarted(c) {
+		time.Sleep(time.Millisecond)
+	}
+	if e = c.Send(Headers{HK_DESTINATION, closeDest}, "late"); e != ECLOSING {
+		t.Fatalf("TestCloseInFlight Expected [%v], got [%v]\n", ECLOSING, e)
+	}
+	if e = <-sr; e != nil {
+		t.Fatalf("TestCloseInFlight Send Expected [nil], got [%v]\n", e)
+	}
+	if e = <-cr; e != nil {
+		t.Fatalf("TestCloseInFlight Close Expected [nil], got [%v]\n", e)
+	}
+	_ = n.Close()
+	if se := <-rc; se != nil {
+		t.Fatalf("TestCloseInFlight Script error [%v]\n", se)
+	}
+}
+
+/*
+	Test Close honors the ctx deadline while waiting for the RECEIPT.
+*/
+func TestCloseDeadline(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Expect(DISCONNECT).WaitClose()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestCloseDeadline Expected [nil], got [%v]\n", e)
+	}
+	ctx, cf := context.WithTimeout(context.Background(), closePause)
+	defer cf()
+	if e = c.Close(ctx); !errors.Is(e, context.DeadlineExceeded) {
+		t.Fatalf("TestCloseDeadline Expected [%v], got [%v]\n",
+			context.DeadlineExceeded, e)
+	}
+	if c.Connected() {
+		t.Fatalf("TestCloseDeadline Expected a shut down connection\n")
+	}
+	_ = n.Close()
+	<-rc
+}
+
+/*
+	Test Close unsubscribes with a receipt before the DISCONNECT.
+*/
+func TestCloseUnsubscribe(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Expect(SUBSCRIBE, HK_ID, closeDest).
+		Expect(UNSUBSCRIBE, HK_ID, closeDest).Receipt().
+		Expect(DISCONNECT).Receipt().WaitClose()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestCloseUnsubscribe Expected [nil], got [%v]\n", e)
+	}
+	if _, e = c.Subscribe(Headers{HK_DESTINATION, closeDest, HK_ID, closeDest}); e != nil {
+		t.Fatalf("TestCloseUnsubscribe Expected [nil], got [%v]\n", e)
+	}
+	if e = c.Close(context.Background(), CloseUnsubscribe()); e != nil {
+		t.Fatalf("TestCloseUnsubscribe Expected [nil], got [%v]\n", e)
+	}
+	_ = n.Close()
+	if se := <-rc; se != nil {
+		t.Fatalf("TestCloseUnsubscribe Script error [%v]\n", se)
+	}
+}
+
+/*
+	Test Close with CloseDrain waits for the consumer to take and ACK every
+	message.
+*/
+func TestCloseDrain(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	c, e := Connect(b.Pipe(), ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestCloseDrain Expected [nil], got [%v]\n", e)
+	}
+	c.SetSubChanCap(closeCount)
+	sc, e := c.Subscribe(Headers{HK_DESTINATION, closeDest, HK_ID, closeDest,
+		HK_ACK, AckModeClientIndividual})
+	if e != nil {
+		t.Fatalf("TestCloseDrain Expected [nil], got [%v]\n", e)
+	}
+	for i := 0; i < closeCount; i++ {
+		if e = c.Send(Headers{HK_DESTINATION, closeDest}, "drain"); e != nil {
+			t.Fatalf("TestCloseDrain Expected [nil], got [%v]\n", e)
+		}
+	}
+	for len(sc) < closeCount {
+		time.Sleep(time.Millisecond)
+	}
+	got := make(chan int, 1)
+	go func() {
+		k := 0
+		for i := 0; i < closeCount; i++ {
+			md := <-sc
+			if md.Error != nil {
+				break
+			}
+			time.Sleep(closeConsume)
+			if c.Ack(Headers{HK_ID, md.Message.Headers.Value(HK_ACK)}) == nil {
+				k++
+			}
+		}
+		got <- k
+	}()
+	if e = c.Close(context.Background(), CloseDrain(), CloseUnsubscribe()); e != nil {
+		t.Fatalf("TestCloseDrain Expected [nil], got [%v]\n", e)
+	}
+	if k := <-got; k != closeCount {
+		t.Fatalf("TestCloseDrain Expected [%d] ACKs, got [%d]\n", closeCount, k)
+	}
+	if p := b.Pending(closeDest); p != 0 {
+		t.Fatalf("TestCloseDrain Expected [0] pending, got [%d]\n", p)
+	}
+}
+
+/*
+	Test Close with CloseDrain returns by its ctx deadline when a consumer
+	never reads, and the reader is blocked delivering to it.
+*/
+func TestCloseStalled(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	c, e := Connect(b.Pipe(), ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestCloseStalled Expected [nil], got [%v]\n", e)
+	}
+	sc, e := c.Subscribe(Headers{HK_DESTINATION, closeDest, HK_ID, closeDest})
+	if e != nil {
+		t.Fatalf("TestCloseStalled Expected [nil], got [%v]\n", e)
+	}
+	for i := 0; i < closeCount; i++ {
+		if e = c.Send(Headers{HK_DESTINATION, closeDest}, "stalled"); e != nil {
+			t.Fatalf("TestCloseStalled Expected [nil], got [%v]\n", e)
+		}
+	}
+	for len(sc) < cap(sc) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(closeConsume) // Reader blocks on the full subscription
+	ctx, cf := context.WithTimeout(context.Background(), closePause)
+	defer cf()
+	cr := make(chan error, 1)
+	go func() {
+		cr <- c.Close(ctx, CloseDrain())
+	}()
+	select {
+	case e = <-cr:
+	case <-time.After(closeStall):
+		t.Fatalf("TestCloseStalled Close hangs\n")
+	}
+	if !errors.Is(e, context.DeadlineExceeded) {
+		t.Fatalf("TestCloseStalled Expected [%v], got [%v]\n",
+			context.DeadlineExceeded, e)
+	}
+}
+
+/*
+	Test ACK tracking for CloseDrain.  A client mode ACK settles earlier
+	MESSAGE frames, and tracking is bounded.
+*/
+func TestCloseAckTracking(t *testing.T) {
+	c := &Connection{protocol: SPL_12}
+	sd := &subscription{am: AckModeClient, fp: FlowBlock}
+	for i := 0; i < closeCount; i++ {
+		c.trackAck(sd, Headers{HK_ACK, strconv.Itoa(i)})
+	}
+	c.settleAck(Headers{HK_ID, strconv.Itoa(closeCount - 2)})
+	if len(c.unak) != 1 || len(sd.ackq) != 1 {
+		t.Fatalf("TestCloseAckTracking Expected [1] unacked, got [%d] [%d]\n",
+			len(c.unak), len(sd.ackq))
+	}
+	c.settleAck(Headers{HK_ID, strconv.Itoa(closeCount - 1)})
+	if len(c.unak) != 0 || sd.ackq != nil {
+		t.Fatalf("TestCloseAckTracking Expected [0] unacked, got [%d] [%d]\n",
+			len(c.unak), len(sd.ackq))
+	}
+	sd.am = AckModeClientIndividual
+	for i := 0; i <= closeAckMax; i++ {
+		c.trackAck(sd, Headers{HK_ACK, strconv.Itoa(i)})
+	}
+	if len(c.unak) != closeAckMax || sd.ackq != nil {
+		t.Fatalf("TestCloseAckTracking Expected [%d] unacked, got [%d] [%d]\n",
+			closeAckMax, len(c.unak), len(sd.ackq))
+	}
+}
+
+/*
+	Check for an operation in flight.
+*/
+func closeBusy(c *Connection) bool {
+	c.opsLock.Lock()
+	defer c.opsLock.Unlock()
+	return c.ops > 0
+}
+
+/*
+	Check that Close has started.
+*/
+func closeStarted(c *Connection) bool {
+	c.opsLock.Lock()
+	defer c.opsLock.Unlock()
+	return c.clsg
+}
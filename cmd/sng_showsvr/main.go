@@ -17,10 +17,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
 	"os"
+	"time"
 
 	//
 	sng "github.com/photostorm/stompngo"
@@ -30,17 +32,13 @@ import (
 func main() {
 
 	//=========================================================================
-	// Use something like this a boilerplate for connect (Yes, a lot of work,
-	// network connects usually are.)
+	// Use something like this a boilerplate for connect.  Dial takes care
+	// of the network connect and the STOMP CONNECT.
 	host, port := senv.HostAndPort()
 	hap := net.JoinHostPort(host, port)
-	n, err := net.Dial(sng.NetProtoTCP, hap)
 	log.Printf("Connect Host and Port: %s\n", hap)
 	log.Printf("Connect Login: %s\n", senv.Login())
 	log.Printf("Connect Passcode: %s\n", senv.Passcode())
-	if err != nil {
-		log.Fatalln("Net Connect error for:", hap, "error:", err)
-	}
 	//
 	connect_headers := sng.Headers{sng.HK_LOGIN, senv.Login(),
 		sng.HK_PASSCODE, senv.Passcode(),
@@ -49,15 +47,16 @@ func main() {
 		sng.HK_ACCEPT_VERSION, senv.Protocol(),
 	}
 	//
-	stomp_conn, err := sng.Connect(n, connect_headers)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	stomp_conn, err := sng.Dial(ctx, "stomp://"+hap, connect_headers)
 	if err != nil {
-		log.Printf("STOMP Connect failed, error:%v\n", err)
+		log.Printf("STOMP Dial failed, error:%v\n", err)
 		if stomp_conn != nil {
 			log.Printf("Connect Response: %v\n", stomp_conn.ConnectResponse)
 		}
 		os.Exit(1)
 	}
-
 	//=========================================================================
 	// Use something like this as real application logic
 	fmt.Printf("Client CONNECT Headers:\n%v\n", connect_headers)
@@ -75,12 +74,9 @@ func main() {
 	//=========================================================================
 	// Use something like this as boilerplate for disconnect (Clean disconnects
 	// are also a lot of work.)
+	// The network connection is owned by stomp_conn, and is closed here.
 	err = stomp_conn.Disconnect(sng.Headers{})
 	if err != nil {
 		log.Fatalf("DISCONNECT Failed, error:%v\n", err)
 	}
-	err = n.Close()
-	if err != nil {
-		log.Fatalf("Net Close Error:%v\n", err)
-	}
 }
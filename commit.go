@@ -16,6 +16,10 @@
 
 package stompngo
 
+import (
+	"context"
+)
+
 /*
 	Commit a STOMP transaction.
 
@@ -31,7 +35,22 @@ package stompngo
 
 */
 func (c *Connection) Commit(h Headers) error {
-	c.log(COMMIT, "start", h)
+	return c.CommitContext(context.Background(), h)
+}
+
+/*
+	CommitContext commits a STOMP transaction, honoring ctx cancellation and
+	deadlines.  See Commit for details.
+*/
+func (c *Connection) CommitContext(ctx context.Context, h Headers) error {
+	return c.commit(ctx, h, false)
+}
+
+/*
+	Commit, waiting for the broker's RECEIPT if rcpt is set.
+*/
+func (c *Connection) commit(ctx context.Context, h Headers, rcpt bool) error {
+	c.logcmd(COMMIT, "start", h)
 	if !c.isConnected() {
 		return ECONBAD
 	}
@@ -43,7 +62,15 @@ func (c *Connection) Commit(h Headers) error {
 	if h.Value(HK_TRANSACTION) == "" {
 		return ETIDCOMEMT
 	}
-	e := c.transmitCommon(COMMIT, h) // transmitCommon Clones() the headers
-	c.log(COMMIT, "end", h)
+	e := c.transmitCommon(ctx, COMMIT, h, rcpt) // transmitCommon Clones() the headers
+	c.logcmd(COMMIT, "end", h)
 	return e
 }
+
+/*
+	CommitWithReceipt commits a STOMP transaction, and waits for the broker's
+	RECEIPT.  See SendWithReceipt and Commit for details.
+*/
+func (c *Connection) CommitWithReceipt(ctx context.Context, h Headers) error {
+	return c.commit(ctx, h, true)
+}
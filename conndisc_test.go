@@ -17,6 +17,7 @@
 package stompngo
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -355,7 +356,7 @@ func TestConnCDRespData(t *testing.T) {
 		}
 		for i, f := range frames {
 			_, e = connectResponse(f.data)
-			if e != f.resp {
+			if !errors.Is(e, f.resp) {
 				t.Fatalf("TestConnCDRespData Index [%v], expected [%v], got [%v]\n", i, f.resp, e)
 			}
 		}
@@ -18,7 +18,8 @@ package stompngo
 
 import (
 	"bufio"
-
+	"context"
+	"log/slog"
 	"net"
 	"os"
 	"time"
@@ -67,6 +68,26 @@ func NewConnector(n net.Conn, h Headers) (STOMPConnector, error) {
 		// Use c
 */
 func Connect(n net.Conn, h Headers) (*Connection, error) {
+	return connect(n, h, connOpts{})
+}
+
+/*
+	Options fixed before the CONNECT handshake starts.
+*/
+type connOpts struct {
+	clnc bool            // New Connection owns the network connection
+	lgr  *slog.Logger    // Initial logger
+	wtrc bool            // Initial wire trace setting
+	orph OrphanPolicy    // Initial orphan MESSAGE policy
+	lobs LatencyObserver // Elapsed time observer
+	trc  Tracer          // Initial Tracer
+	rlim *ReadLimits     // Initial read limits, nil for the default
+}
+
+/*
+	Connect, with options that must be in place before the handshake.
+*/
+func connect(n net.Conn, h Headers, co connOpts) (*Connection, error) {
 	if h == nil {
 		return nil, EHDRNIL
 	}
@@ -89,7 +110,18 @@ func Connect(n net.Conn, h Headers) (*Connection, error) {
 		ssdc:              make(chan struct{}),
 		wtrsdc:            make(chan struct{}),
 		scc:               1,
-		dld:               &deadlineData{}}
+		dld:               &deadlineData{},
+		rcpts:             &receiptManager{waiters: make(map[string]chan MessageData)},
+		clnc:              co.clnc}
+	c.lgr.Store(co.lgr)
+	c.wtrc.Store(co.wtrc)
+	c.orph.Store(int32(co.orph))
+	c.SetTracer(co.trc)
+	if co.rlim != nil {
+		c.SetReadLimits(*co.rlim)
+	} else {
+		c.SetReadLimits(DefaultReadLimits)
+	}
 
 	// Basic metric data
 	c.mets = &metrics{st: time.Now()}
@@ -104,8 +136,8 @@ func Connect(n net.Conn, h Headers) (*Connection, error) {
 
 	// Initialize elapsed time tracking data if needed
 	c.eltd = nil
-	if os.Getenv("STOMP_TRACKELT") != "" {
-		c.eltd = &eltmets{}
+	if os.Getenv("STOMP_TRACKELT") != "" || co.lobs != nil {
+		c.eltd = &eltmets{obs: co.lobs}
 	}
 
 	// OK, put a CONNECT on the wire
@@ -124,11 +156,7 @@ func Connect(n net.Conn, h Headers) (*Connection, error) {
 		f = Frame{CONNECT, ch, NULLBUFF} // Create actual CONNECT frame
 		// fmt.Printf("Frame: %q\n", f)
 	}
-	r := make(chan error)                               // Make the error channel for a write
-	if e := c.writeWireData(wiredata{f, r}); e != nil { // Send the CONNECT frame
-		return c, e
-	}
-	e := <-r // Retrieve any error
+	e := c.transmitFrame(context.Background(), f, false) // Send the CONNECT frame
 	//
 	if e != nil {
 		c.sysAbort() // Shutdown,  we are done with errors
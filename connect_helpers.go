@@ -26,6 +26,10 @@ import (
 	"github.com/photostorm/stompngo/senv"
 )
 
+/*
+	CONNERROR is a Connect or Dial failure with additional detail.  It wraps
+	one of the Error constants.
+*/
 type CONNERROR struct {
 	err  error
 	desc string
@@ -35,6 +39,10 @@ func (e *CONNERROR) Error() string {
 	return e.err.Error() + ":" + e.desc
 }
 
+func (e *CONNERROR) Unwrap() error {
+	return e.err
+}
+
 /*
 	Connection handler, one time use during initial connect.
 
@@ -44,7 +52,11 @@ func (e *CONNERROR) Error() string {
 func (c *Connection) connectHandler(h Headers) (e error) {
 	//fmt.Printf("CHDB01\n")
 	c.rdr = bufio.NewReaderSize(c.netconn, senv.ReadBufsz())
-	b, e := c.rdr.ReadBytes(0)
+	rl := c.readLimits()
+	b, big, e := readDelim(c.rdr, 0, rl.connectMax())
+	if big {
+		return &FrameTooLargeError{LimitFrame, rl.connectMax()}
+	}
 	if e != nil {
 		return e
 	}
@@ -53,11 +65,15 @@ func (c *Connection) connectHandler(h Headers) (e error) {
 	if e != nil {
 		return e
 	}
+	if e = rl.check(f); e != nil {
+		return e
+	}
 	//fmt.Printf("CHDB03\n")
+	c.logx("recv", f)
 	//
 	c.ConnectResponse = &Message{f.Command, f.Headers, f.Body}
 	if c.ConnectResponse.Command == ERROR {
-		return &CONNERROR{ECONERR, string(f.Body)}
+		return &BrokerError{*c.ConnectResponse, ECONERR}
 	}
 	//fmt.Printf("CHDB04\n")
 	//
@@ -82,8 +98,7 @@ func (c *Connection) connectHandler(h Headers) (e error) {
 	//fmt.Printf("CHDB06\n")
 
 	c.setConnected(true)
-	c.mets.tfr += 1
-	c.mets.tbr += c.ConnectResponse.Size(false)
+	c.mets.read(c.ConnectResponse.Command, c.ConnectResponse.Size(false))
 	return nil
 }
 
@@ -108,17 +123,17 @@ func connectResponse(s string) (*Frame, error) {
 				return nil, EBADSSLP
 			}
 		}
-		return nil, EBADFRM
+		return nil, &ProtocolError{EBADFRM, []byte(s)}
 	}
 	f.Command = c[0]
 	if f.Command != CONNECTED && f.Command != ERROR {
-		return f, EUNKFRM
+		return f, &ProtocolError{EUNKFRM, []byte(s)}
 	}
 
 	switch c[1] {
 	case "\x00", "\n": // No headers, malformed bodies
 		f.Body = []uint8(c[1])
-		return f, EBADFRM
+		return f, &ProtocolError{EBADFRM, []byte(s)}
 	case "\n\x00": // No headers, no body is OK
 		return f, nil
 	default: // Otherwise continue
@@ -129,7 +144,7 @@ func connectResponse(s string) (*Frame, error) {
 		w := []uint8(b[0])
 		f.Body = w[0 : len(w)-1]
 		if f.Command == CONNECTED && len(f.Body) > 0 {
-			return f, EBDYDATA
+			return f, &ProtocolError{EBDYDATA, f.Body}
 		}
 		return f, nil
 	}
@@ -143,7 +158,7 @@ func connectResponse(s string) (*Frame, error) {
 		p := strings.SplitN(l, ":", 2)
 		if len(p) < 2 {
 			f.Body = []uint8(p[0]) // Bad feedback
-			return f, EUNKHDR
+			return f, &ProtocolError{EUNKHDR, f.Body}
 		}
 		f.Headers = append(f.Headers, p[0], p[1])
 	}
@@ -151,7 +166,7 @@ func connectResponse(s string) (*Frame, error) {
 	w := []uint8(b[1])
 	f.Body = w[0 : len(w)-1]
 	if f.Command == CONNECTED && len(f.Body) > 0 {
-		return f, EBDYDATA
+		return f, &ProtocolError{EBDYDATA, f.Body}
 	}
 
 	return f, nil
@@ -75,7 +75,7 @@ func (c *Connection) SendTickerCount() int64 {
 	if c.hbd == nil {
 		return 0
 	}
-	return c.hbd.sc
+	return c.hbd.sc.Load()
 }
 
 /*
@@ -86,35 +86,53 @@ func (c *Connection) ReceiveTickerCount() int64 {
 	if c.hbd == nil {
 		return 0
 	}
-	return c.hbd.rc
+	return c.hbd.rc.Load()
+}
+
+/*
+	HeartBeatReceiveFailed returns true if the latest heart beat receive check
+	failed, which is possibly transient.  Valid for 1.1+ only.  Unlike the Hbrf
+	field, it is safe to call while the connection is running.
+*/
+func (c *Connection) HeartBeatReceiveFailed() bool {
+	return c.hbrf.Load()
+}
+
+/*
+	HeartBeatSendFailed returns true if the latest heart beat send failed,
+	which is possibly transient.  Valid for 1.1+ only.  Unlike the Hbsf field,
+	it is safe to call while the connection is running.
+*/
+func (c *Connection) HeartBeatSendFailed() bool {
+	return c.hbsf.Load()
 }
 
 /*
 	FramesRead returns a count of the number of frames read on the connection.
 */
 func (c *Connection) FramesRead() int64 {
-	return c.mets.tfr
+	return c.mets.tfr.Load()
 }
 
 /*
 	BytesRead returns a count of the number of bytes read on the connection.
 */
 func (c *Connection) BytesRead() int64 {
-	return c.mets.tbr
+	return c.mets.tbr.Load()
 }
 
 /*
 	FramesWritten returns a count of the number of frames written on the connection.
 */
 func (c *Connection) FramesWritten() int64 {
-	return c.mets.tfw
+	return c.mets.tfw.Load()
 }
 
 /*
 	BytesWritten returns a count of the number of bytes written on the connection.
 */
 func (c *Connection) BytesWritten() int64 {
-	return c.mets.tbw
+	return c.mets.tbw.Load()
 }
 
 /*
@@ -140,22 +158,80 @@ func (c *Connection) SetSubChanCap(nc int) {
 	return
 }
 
-// Unexported Connection methods
+/*
+	SetAutoPrefetch enables or disables the activemq.prefetchSize and
+	prefetch-count headers added to future SUBSCRIBE frames.  The default is
+	disabled.  See Subscribe.
+*/
+func (c *Connection) SetAutoPrefetch(on bool) {
+	c.apf.Store(on)
+}
 
 /*
-	Log data if possible.
+	SetStreamBodies enables or disables streamed MESSAGE bodies.  When
+	enabled, a MESSAGE with a non-zero content-length header is delivered
+	with an empty Message.Body, and MessageData.Stream reading the body
+	directly from the network.  A MESSAGE without content-length is read
+	into Message.Body as usual.
+
+	The connection reads nothing more until the Stream is read to the end
+	or closed, so every streamed MessageData received must be closed, even
+	if not read.  The default is disabled.
 */
-func (c *Connection) log(v ...interface{}) {
-	return
+func (c *Connection) SetStreamBodies(on bool) {
+	c.sbdy.Store(on)
 }
 
 /*
-	Log data if possible (extended / abbreviated) logic).
+	SetMaxBodySize sets the largest body accepted on a received frame,
+	streamed or not.  It changes only the MaxBody field of the ReadLimits.
+	Zero, the default, means no limit.
 */
-func (c *Connection) logx(v ...interface{}) {
-	return
+func (c *Connection) SetMaxBodySize(n int64) {
+	for { // Retry if SetReadLimits, or another call, gets in first
+		o := c.rlim.Load()
+		l := ReadLimits{}
+		if o != nil {
+			l = *o
+		}
+		l.MaxBody = n
+		if c.rlim.CompareAndSwap(o, &l) {
+			return
+		}
+	}
 }
 
+/*
+	SetWriteBatch lets the writer coalesce up to max queued frames into one
+	flush.  After the first frame of a batch, the writer waits up to linger
+	for more frames, trading latency for throughput; with no linger only
+	frames already waiting are added.  A max of 1 or less, the default,
+	flushes every frame.  Batching matters most with SendAsync, or many
+	goroutines sending on one connection.
+*/
+func (c *Connection) SetWriteBatch(max int, linger time.Duration) {
+	c.wbmx.Store(int32(max))
+	c.wbln.Store(int64(linger))
+}
+
+/*
+	SetReadLimits sets the limits on frames received from the broker.  The
+	default is DefaultReadLimits.  See ReadLimits.
+*/
+func (c *Connection) SetReadLimits(l ReadLimits) {
+	c.rlim.Store(&l)
+}
+
+/*
+	SetOrphanPolicy sets the handling of MESSAGE frames for unknown
+	subscriptions.  The default is OrphanDrop.  See OrphanPolicy.
+*/
+func (c *Connection) SetOrphanPolicy(p OrphanPolicy) {
+	c.orph.Store(int32(p))
+}
+
+// Unexported Connection methods
+
 /*
 	Shutdown heartbeats
 */
@@ -186,8 +262,7 @@ func (c *Connection) shutdown() {
 	// This is a write lock
 	c.subsLock.Lock()
 	for key := range c.subs {
-		close(c.subs[key].md)
-		c.subs[key].cs = true
+		c.subs[key].close()
 	}
 	c.setConnected(false)
 	c.subsLock.Unlock()
@@ -219,7 +294,7 @@ func (c *Connection) handleReadError(md MessageData) {
 	c.subsLock.RLock()
 	if c.isConnected() {
 		for key := range c.subs {
-			c.subs[key].md <- md
+			c.subs[key].deliverError(md)
 		}
 	}
 	c.subsLock.RUnlock()
@@ -230,6 +305,15 @@ func (c *Connection) handleReadError(md MessageData) {
 	return
 }
 
+/*
+	Close the network connection if this package owns it.
+*/
+func (c *Connection) closeNetconn() {
+	if c.clnc {
+		_ = c.netconn.Close()
+	}
+}
+
 /*
 	Connected check
 */
@@ -0,0 +1,200 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+	Test SendContext with an already canceled context.
+*/
+func TestContextSendCanceled(t *testing.T) {
+	c, pb := pipeConnect(t, ctxHeaders, ctxConnected)
+	defer pb.n.Close()
+	ctx, cf := context.WithCancel(context.Background())
+	cf()
+	e = c.SendContext(ctx, Headers{HK_DESTINATION, "/queue/ctx.canceled"}, tm)
+	if e != context.Canceled {
+		t.Fatalf("TestContextSendCanceled Expected [%v], got [%v]\n",
+			context.Canceled, e)
+	}
+	if !c.Connected() {
+		t.Fatalf("TestContextSendCanceled Expected connected [true], got [false]\n")
+	}
+}
+
+/*
+	Test SendContext with a deadline, and a broker which does not read.  The
+	blocked wire write must be interrupted, and the connection shut down.
+*/
+func TestContextSendDeadline(t *testing.T) {
+	c, pb := pipeConnect(t, ctxHeaders, ctxConnected)
+	defer pb.n.Close()
+	ctx, cf := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cf()
+	e = c.SendContext(ctx, Headers{HK_DESTINATION, "/queue/ctx.deadline"}, tm)
+	if e != context.DeadlineExceeded {
+		t.Fatalf("TestContextSendDeadline Expected [%v], got [%v]\n",
+			context.DeadlineExceeded, e)
+	}
+	// Writer is shut down, so further sends must fail promptly.
+	e = c.Send(Headers{HK_DESTINATION, "/queue/ctx.deadline"}, tm)
+	if e != ECONBAD && e != context.DeadlineExceeded {
+		t.Fatalf("TestContextSendDeadline Expected [%v], got [%v]\n", ECONBAD, e)
+	}
+}
+
+/*
+	Test SendContext success, the frame is on the wire.
+*/
+func TestContextSendOK(t *testing.T) {
+	c, pb := pipeConnect(t, ctxHeaders, ctxConnected)
+	defer pb.n.Close()
+	go func() {
+		if s, e := pb.readFrame(); e == nil {
+			if !strings.HasPrefix(s, SEND+"\n") {
+				t.Errorf("TestContextSendOK Expected SEND, got [%q]\n", s)
+			}
+		}
+	}()
+	ctx, cf := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cf()
+	e = c.SendContext(ctx, Headers{HK_DESTINATION, "/queue/ctx.ok"}, tm)
+	if e != nil {
+		t.Fatalf("TestContextSendOK Expected [nil], got [%v]\n", e)
+	}
+}
+
+/*
+	Test DisconnectContext when the broker never sends the RECEIPT.
+*/
+func TestContextDisconnectNoReceipt(t *testing.T) {
+	c, pb := pipeConnect(t, ctxHeaders, ctxConnected)
+	defer pb.n.Close()
+	go func() {
+		_, _ = pb.readFrame() // DISCONNECT, never answered
+	}()
+	ctx, cf := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cf()
+	e = c.DisconnectContext(ctx, empty_headers)
+	if e != context.DeadlineExceeded {
+		t.Fatalf("TestContextDisconnectNoReceipt Expected [%v], got [%v]\n",
+			context.DeadlineExceeded, e)
+	}
+	if c.Connected() {
+		t.Fatalf("TestContextDisconnectNoReceipt Expected connected [false], got [true]\n")
+	}
+}
+
+/*
+	Test DisconnectContext when a subscriber never reads, and the broker never
+	sends the RECEIPT.  The reader is blocked delivering a MESSAGE, and
+	DisconnectContext must still return by the ctx deadline.
+*/
+func TestContextDisconnectStalledSub(t *testing.T) {
+	c, pb := pipeConnect(t, ctxHeaders, ctxConnected)
+	defer pb.n.Close()
+	sent := make(chan struct{})
+	go func() {
+		_, _ = pb.readFrame() // SUBSCRIBE
+		for i := 0; i < c.SubChanCap()+1; i++ {
+			_, _ = pb.n.Write([]byte(ctxStalledMessage))
+		}
+		close(sent)
+		for {
+			if _, e := pb.readFrame(); e != nil { // DISCONNECT, never answered
+				return
+			}
+		}
+	}()
+	_, e = c.Subscribe(Headers{HK_DESTINATION, "/queue/ctx.stalled",
+		HK_ID, "ctx-stalled"})
+	if e != nil {
+		t.Fatalf("TestContextDisconnectStalledSub Expected [nil], got [%v]\n", e)
+	}
+	<-sent
+	time.Sleep(ctxStallPause) // Reader blocks on the full subscription
+	ctx, cf := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cf()
+	dc := make(chan error, 1)
+	go func() { dc <- c.DisconnectContext(ctx, empty_headers) }()
+	select {
+	case e = <-dc:
+	case <-time.After(ctxStallWait):
+		t.Fatalf("TestContextDisconnectStalledSub DisconnectContext hangs\n")
+	}
+	if e != context.DeadlineExceeded {
+		t.Fatalf("TestContextDisconnectStalledSub Expected [%v], got [%v]\n",
+			context.DeadlineExceeded, e)
+	}
+	if c.Connected() {
+		t.Fatalf("TestContextDisconnectStalledSub Expected connected [false], got [true]\n")
+	}
+}
+
+/*
+	Test SendContext with write deadlines enabled.  The deadline armed for
+	each part of the frame must not undo the context abort, so the frame is
+	never completed on the wire.
+*/
+func TestContextSendWriteDeadline(t *testing.T) {
+	cn, sn := net.Pipe()
+	defer sn.Close()
+	go func() {
+		pb := &pipe_broker{n: sn, r: bufio.NewReader(sn)}
+		if _, e := pb.readFrame(); e != nil {
+			return
+		}
+		_, _ = sn.Write([]byte(ctxConnected))
+		_, _ = io.Copy(io.Discard, pb.r)
+	}()
+	sc := &slow_arm_conn{Conn: cn}
+	c, e := Connect(sc, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestContextSendWriteDeadline CONNECT expected [nil], got [%v]\n", e)
+	}
+	c.WriteDeadline(ctxWriteDeadline)
+	c.EnableWriteDeadline(true)
+	sc.arm = ctxArmPause
+	h := Headers{HK_DESTINATION, "/queue/ctx.wdl"}
+	for i := 0; i < ctxArmHeaders; i++ {
+		h = h.Add("ctx-wdl-"+strconv.Itoa(i), "v")
+	}
+	ctx, cf := context.WithTimeout(context.Background(), 2*ctxArmPause)
+	defer cf()
+	e = c.SendContext(ctx, h, tm)
+	if e != context.DeadlineExceeded {
+		t.Fatalf("TestContextSendWriteDeadline Expected [%v], got [%v]\n",
+			context.DeadlineExceeded, e)
+	}
+	// The interrupted write shuts the connection down.
+	dl := time.Now().Add(ctxStallWait)
+	for c.Connected() && time.Now().Before(dl) {
+		time.Sleep(ctxArmPause)
+	}
+	if c.Connected() {
+		t.Fatalf("TestContextSendWriteDeadline Expected connected [false], got [true]\n")
+	}
+}
@@ -18,8 +18,12 @@ package stompngo
 
 import (
 	"bufio"
+	"context"
+	"io"
+	"log/slog"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -92,6 +96,7 @@ type Frame Message
 type MessageData struct {
 	Message Message
 	Error   error
+	Stream  io.ReadCloser // MESSAGE body, if streamed (see SetStreamBodies)
 }
 
 /*
@@ -100,6 +105,20 @@ type MessageData struct {
 type wiredata struct {
 	frame   Frame
 	errchan chan error
+	ctx     context.Context // Caller's context, never nil
+	body    *sendStream     // Streamed body, or nil
+	fut     *SendFuture     // Result for SendAsync, used instead of errchan
+}
+
+/*
+	Report the result of a wire write to the sender.
+*/
+func (d *wiredata) report(e error) {
+	if d.fut != nil {
+		d.fut.complete(e)
+		return
+	}
+	d.errchan <- e
 }
 
 /*
@@ -119,6 +138,41 @@ type Stomper interface {
 	SendBytes(h Headers, b []byte) error
 }
 
+/*
+	ContextStomper is an interface that models STOMP specification commands
+	which honor context.Context cancellation and deadlines.
+*/
+type ContextStomper interface {
+	AbortContext(ctx context.Context, h Headers) error
+	AckContext(ctx context.Context, headers Headers) error
+	BeginContext(ctx context.Context, h Headers) error
+	CommitContext(ctx context.Context, h Headers) error
+	DisconnectContext(ctx context.Context, headers Headers) error
+	NackContext(ctx context.Context, headers Headers) error
+	SendContext(ctx context.Context, h Headers, b string) error
+	SubscribeContext(ctx context.Context, headers Headers) (<-chan MessageData, error)
+	UnsubscribeContext(ctx context.Context, headers Headers) error
+	//
+	SendBytesContext(ctx context.Context, h Headers, b []byte) error
+}
+
+/*
+	ReceiptStomper is an interface that models STOMP specification commands
+	which wait for the broker's RECEIPT before returning.
+*/
+type ReceiptStomper interface {
+	AbortWithReceipt(ctx context.Context, h Headers) error
+	AckWithReceipt(ctx context.Context, headers Headers) error
+	BeginWithReceipt(ctx context.Context, h Headers) error
+	CommitWithReceipt(ctx context.Context, h Headers) error
+	NackWithReceipt(ctx context.Context, headers Headers) error
+	SendWithReceipt(ctx context.Context, h Headers, b string) error
+	SubscribeWithReceipt(ctx context.Context, headers Headers) (<-chan MessageData, error)
+	UnsubscribeWithReceipt(ctx context.Context, headers Headers) error
+	//
+	SendBytesWithReceipt(ctx context.Context, h Headers, b []byte) error
+}
+
 /*
 	StatsReader is an interface that modela a reader for the statistics
 	maintained by the stompngo package.
@@ -130,6 +184,14 @@ type StatsReader interface {
 	BytesWritten() int64
 }
 
+/*
+	StatsSnapshotReader is an interface that models a reader for a snapshot
+	of all statistics maintained by the stompngo package.
+*/
+type StatsSnapshotReader interface {
+	Stats() Stats
+}
+
 /*
 	HBDataReader is an interface that modela a reader for the heart beat
 	data maintained by the stompngo package.
@@ -141,6 +203,15 @@ type HBDataReader interface {
 	ReceiveTickerCount() int64
 }
 
+/*
+	HBStatusReader is an interface that models a reader for the heart beat
+	failure flags maintained by the stompngo package.
+*/
+type HBStatusReader interface {
+	HeartBeatReceiveFailed() bool
+	HeartBeatSendFailed() bool
+}
+
 /*
 	Deadliner is an interface that models the optional network deadline
 	functionality implemented by the stompngo package.
@@ -180,6 +251,8 @@ type ParmHandler interface {
 */
 type STOMPConnector interface {
 	Stomper
+	ContextStomper
+	ReceiptStomper
 	StatsReader
 	HBDataReader
 	Deadliner
@@ -212,13 +285,41 @@ type Connection struct {
 	hbd               *heartBeatData
 	wtr               *bufio.Writer
 	rdr               *bufio.Reader
-	Hbrf              bool          // Indicates a heart beat read/receive failure, which is possibly transient.  Valid for 1.1+ only.
-	Hbsf              bool          // Indicates a heart beat send failure, which is possibly transient.  Valid for 1.1+ only.
-	mets              *metrics      // Client metrics
-	scc               int           // Subscribe channel capacity
-	discLock          sync.Mutex    // DISCONNECT lock
-	dld               *deadlineData // Deadline data
-	eltd              *eltmets      // Elapsed time data
+	Hbrf              bool                        // Indicates a heart beat read/receive failure, which is possibly transient.  Valid for 1.1+ only.
+	Hbsf              bool                        // Indicates a heart beat send failure, which is possibly transient.  Valid for 1.1+ only.
+	hbrf              atomic.Bool                 // Heart beat read/receive failure, see HeartBeatReceiveFailed
+	hbsf              atomic.Bool                 // Heart beat send failure, see HeartBeatSendFailed
+	mets              *metrics                    // Client metrics
+	scc               int                         // Subscribe channel capacity
+	discLock          sync.Mutex                  // DISCONNECT lock
+	dld               *deadlineData               // Deadline data
+	eltd              *eltmets                    // Elapsed time data
+	clnc              bool                        // Close netconn at shutdown (Dial)
+	rcpts             *receiptManager             // Callers waiting for a RECEIPT
+	lgr               atomic.Pointer[slog.Logger] // Logger, nil for none
+	wtrc              atomic.Bool                 // Wire trace enabled
+	orph              atomic.Int32                // OrphanPolicy
+	dscw              atomic.Bool                 // Waiting for the DISCONNECT RECEIPT
+	trc               atomic.Pointer[tracerRef]   // Tracer, nil for none
+	apf               atomic.Bool                 // Prefetch headers enabled
+	sbdy              atomic.Bool                 // Stream MESSAGE bodies
+	rlim              atomic.Pointer[ReadLimits]  // Received frame limits
+	pbs               *bodyStream                 // Pending streamed body, reader only
+	wbmx              atomic.Int32                // Most frames per write batch
+	wbln              atomic.Int64                // Write batch linger, ns
+	txLock            sync.Mutex                  // Open transactions lock
+	txns              map[string]*Tx              // Open transactions, by id
+	opsLock           sync.Mutex                  // In-flight operations lock
+	ops               int                         // In-flight operations
+	opsIdle           chan struct{}               // Closed when ops reaches 0
+	clsg              bool                        // Close started
+	ackLock           sync.Mutex                  // Unacked MESSAGE lock
+	unak              map[string]unacked          // Unacked MESSAGE frames, by ack key
+	unsq              uint64                      // Unacked MESSAGE sequence
+	rpcLock           sync.Mutex                  // Request replier lock
+	rpc               *replier                    // Request replier, nil until first used or after it stops
+	rpcw              chan struct{}               // Closed when a replier being set up is ready
+	rsty              atomic.Int32                // ReplyStyle
 }
 
 type subscription struct {
@@ -229,13 +330,73 @@ type subscription struct {
 	drav bool             // Drain After value validity
 	dra  uint             // Start draining after # messages (MESSAGE frames)
 	drmc uint             // Current drain count if draining
+	uc   chan struct{}    // Closed when the subscription is removed
+	fp   string           // Flow control policy
+	ovch chan MessageData // Overflow buffer (FlowOverflow)
+	ovsd chan struct{}    // Closed at shutdown (FlowOverflow)
+	drps atomic.Int64     // MESSAGE frames dropped by flow control
+	dest string           // Destination
+	brkr bool             // Subscribed by the broker, never UNSUBSCRIBE
+	ssdc chan struct{}    // Connection system shutdown channel
+	ackq []ackRef         // Unacked MESSAGE frames, client mode, c.ackLock
 }
 
+/*
+	OrphanPolicy determines what happens to a MESSAGE frame whose subscription
+	is unknown to the Connection.
+*/
+type OrphanPolicy int32
+
+const (
+	// Log the frame, and discard it.  The default.
+	OrphanDrop OrphanPolicy = iota
+
+	// Deliver the frame to the Connection MessageData channel.
+	OrphanDeliver
+
+	// Shut the Connection down.  Subscribers receive EORPHMSG.
+	OrphanAbort
+)
+
 /*
 	Error definition.
 */
 type Error string
 
+/*
+	BrokerError is an ERROR frame sent by the broker in response to a
+	specific client request, or to CONNECT.
+*/
+type BrokerError struct {
+	Message Message // The ERROR frame
+	Err     error   // Error category, ECONERR for CONNECT, else nil
+}
+
+/*
+	ProtocolError is a frame from the broker that could not be parsed, or is
+	not valid in context.  Err is one of the Error constants, and Data holds
+	the offending bytes.
+*/
+type ProtocolError struct {
+	Err  error
+	Data []byte
+}
+
+/*
+	HandlerPanic is a panic recovered from a SubscribeFunc MessageHandler.
+*/
+type HandlerPanic struct {
+	Value any    // The value passed to panic
+	Stack []byte // The handler goroutine stack
+}
+
+/*
+	CodecError is a content-type with no registered Codec.
+*/
+type CodecError struct {
+	ContentType string // The media type, without parameters
+}
+
 /*
 	Error constants.
 */
@@ -293,6 +454,12 @@ const (
 	ETIDCOMEMT = Error("transaction-id empty, COMMIT")
 	ETIDABTEMT = Error("transaction-id empty, ABORT")
 
+	// Tx used after Commit or Abort.
+	ETXDONE = Error("transaction already committed or aborted")
+
+	// Operation started after Close.
+	ECLOSING = Error("connection closing")
+
 	// Host header required, STOMP 1.1+
 	EREQHOST = Error("host header required for STOMP 1.1+")
 
@@ -301,7 +468,10 @@ const (
 	EBADSID = Error("invalid subscription-id")
 
 	// Subscribe errors.
-	ESBADAM = Error("invalid ackmode, SUBSCRIBE")
+	ESBADAM   = Error("invalid ackmode, SUBSCRIBE")
+	ESBADFLOW = Error("invalid flow control policy, SUBSCRIBE")
+	ESBADOVFL = Error("invalid overflow buffer size, SUBSCRIBE")
+	ESBADCAP  = Error("flow control policy needs a buffered channel, SUBSCRIBE")
 
 	// Unsubscribe error.
 	EUNOSID  = Error("id required, UNSUBSCRIBE")
@@ -311,6 +481,8 @@ const (
 	EBADVERCLI = Error("unsupported protocol version, client")
 	EBADVERSVR = Error("unsupported protocol version, server")
 	EBADVERNAK = Error("unsupported protocol version, NACK")
+	EBADVERSUB = Error("unsupported protocol version, SUBSCRIBE")
+	EBADVERUNS = Error("unsupported protocol version, UNSUBSCRIBE")
 
 	// Unsupported Headers type.
 	EBADHDR = Error("unsupported Headers type")
@@ -321,11 +493,39 @@ const (
 	// Invalid broker command
 	EINVBCMD = Error("invalid broker command")
 
+	// Invalid content-length header value
+	EBADCLEN = Error("invalid content-length")
+
+	// Received frame over a ReadLimits limit, see FrameTooLargeError
+	ErrFrameTooLarge = Error("frame too large")
+
+	// Streamed body errors
+	ESTRMLEN    = Error("invalid stream length, SEND")
+	ESTRMSHORT  = Error("stream shorter than length, SEND")
+	ESTRMCLOSED = Error("body stream closed")
+
+	// Unexpected frame in response to DISCONNECT
+	EUNKFRMDIS = Error("unrecognized frame returned, DISCONNECT")
+
+	// Broker MESSAGE errors
+	EMSGNOSUB = Error("subscription header required, MESSAGE")
+	EORPHMSG  = Error("MESSAGE for unknown subscription")
+
 	// Invalid receipt-id string
 	EBADRID = Error("invalid receipt-id")
 
 	// DISCONNECT timeout
 	EDISCTO = Error("DISCONNECT timeout")
+
+	// Dial URL errors
+	EBADSCHEME  = Error("unsupported URL scheme, Dial")
+	EBADURLHOST = Error("host required in URL, Dial")
+
+	// Pool errors
+	EPOOLSIZE = Error("invalid pool size, min must be > 0 and <= max")
+
+	// Request errors
+	ERPCHDR = Error("correlation-id and reply-to headers are set by Request")
 )
 
 /*
@@ -338,25 +538,6 @@ var NULLBUFF = make([]uint8, 0)
 */
 var NoDiscReceipt = Headers{"noreceipt", "true"}
 
-/*
-	Codec data structure definition.
-*/
-type codecdata struct {
-	encoded string
-	decoded string
-}
-
-/*
-	STOMP specification defined encoded / decoded values for the Message
-	command and headers.
-*/
-var codecValues = []codecdata{
-	codecdata{"\\\\", "\\"},
-	codecdata{"\\" + "n", "\n"},
-	codecdata{"\\" + "r", "\r"},
-	codecdata{"\\c", ":"},
-}
-
 /*
 	Control data for initialization of heartbeats with STOMP 1.1+, and the
 	subsequent control of any heartbeat routines.
@@ -378,8 +559,8 @@ type heartBeatData struct {
 	sti int64 // local sender ticker interval, ns
 	rti int64 // local receiver ticker interval, ns
 	//
-	sc int64 // local sender ticker count
-	rc int64 // local receiver ticker count
+	sc atomic.Int64 // local sender ticker count
+	rc atomic.Int64 // local receiver ticker count
 	//
 	ssd chan struct{} // sender shutdown channel
 	rsd chan struct{} // receiver shutdown channel
@@ -389,21 +570,110 @@ type heartBeatData struct {
 }
 
 /*
-	Control structure for basic client metrics.
+	Control structure for basic client metrics.  All counters are updated
+	atomically.
 */
 type metrics struct {
-	st  time.Time // Start Time
-	tfr int64     // Total frame reads
-	tbr int64     // Total bytes read
-	tfw int64     // Total frame writes
-	tbw int64     // Total bytes written
+	st   time.Time                 // Start Time
+	tfr  atomic.Int64              // Total frame reads
+	tbr  atomic.Int64              // Total bytes read
+	tfw  atomic.Int64              // Total frame writes
+	tbw  atomic.Int64              // Total bytes written
+	cr   [len(statCmds)]cmdMetrics // Reads by command
+	cw   [len(statCmds)]cmdMetrics // Writes by command
+	hbsm atomic.Int64              // Heart beat send misses
+	hbrm atomic.Int64              // Heart beat receive misses
+	lr   atomic.Int64              // Last read time, ns
+	lw   atomic.Int64              // Last write time, ns
+}
+
+/*
+	Frame and byte counts for one command.
+*/
+type cmdMetrics struct {
+	f atomic.Int64 // Frames
+	b atomic.Int64 // Bytes
 }
 
+/*
+	Commands with their own metrics.
+*/
+var statCmds = [...]string{CONNECT, STOMP, DISCONNECT, SEND, SUBSCRIBE,
+	UNSUBSCRIBE, ACK, NACK, BEGIN, COMMIT, ABORT, CONNECTED, MESSAGE, RECEIPT,
+	ERROR}
+
 /*
   Valid broker commands.
 */
 var validCmds = map[string]bool{MESSAGE: true, ERROR: true, RECEIPT: true}
 
+/*
+	All STOMP commands.  Values are used to avoid allocation.
+*/
+var stompCmds = map[string]string{
+	CONNECT:     CONNECT,
+	STOMP:       STOMP,
+	DISCONNECT:  DISCONNECT,
+	SEND:        SEND,
+	SUBSCRIBE:   SUBSCRIBE,
+	UNSUBSCRIBE: UNSUBSCRIBE,
+	ACK:         ACK,
+	NACK:        NACK,
+	BEGIN:       BEGIN,
+	COMMIT:      COMMIT,
+	ABORT:       ABORT,
+	CONNECTED:   CONNECTED,
+	MESSAGE:     MESSAGE,
+	RECEIPT:     RECEIPT,
+	ERROR:       ERROR,
+}
+
+/*
+	Commands, header keys, and header values common in received frames.  The
+	frame parser returns these without allocation.
+*/
+var internStrings = map[string]string{
+	MESSAGE:           MESSAGE,
+	ERROR:             ERROR,
+	RECEIPT:           RECEIPT,
+	CONNECTED:         CONNECTED,
+	HK_ACK:            HK_ACK,
+	HK_CONTENT_TYPE:   HK_CONTENT_TYPE,
+	HK_CONTENT_LENGTH: HK_CONTENT_LENGTH,
+	HK_DESTINATION:    HK_DESTINATION,
+	HK_HEART_BEAT:     HK_HEART_BEAT,
+	HK_ID:             HK_ID,
+	HK_MESSAGE:        HK_MESSAGE,
+	HK_MESSAGE_ID:     HK_MESSAGE_ID,
+	HK_RECEIPT_ID:     HK_RECEIPT_ID,
+	HK_SESSION:        HK_SESSION,
+	HK_SERVER:         HK_SERVER,
+	HK_SUBSCRIPTION:   HK_SUBSCRIPTION,
+	HK_TRANSACTION:    HK_TRANSACTION,
+	HK_VERSION:        HK_VERSION,
+	"expires":         "expires",
+	"priority":        "priority",
+	"persistent":      "persistent",
+	"redelivered":     "redelivered",
+	"timestamp":       "timestamp",
+	"true":            "true",
+	"false":           "false",
+	"0":               "0",
+	"4":               "4",
+	DFLT_CONTENT_TYPE: DFLT_CONTENT_TYPE,
+	"text/plain":      "text/plain",
+}
+
+/*
+	Header scratch space for the frame parser.  Scratch for more than
+	headersPoolMax header strings is not kept.
+*/
+var headersPool = sync.Pool{New: func() any {
+	return &headerScratch{h: make(Headers, 0, 32), pos: make([]int, 0, 64)}
+}}
+
+const headersPoolMax = 256
+
 const (
 	NetProtoTCP = "tcp" // Protocol Name
 )
@@ -418,6 +688,7 @@ const (
 	HK_ACK            = "ack"
 	HK_CONTENT_TYPE   = "content-type"
 	HK_CONTENT_LENGTH = "content-length"
+	HK_CORRELATION_ID = "correlation-id"
 	HK_DESTINATION    = "destination"
 	HK_HEART_BEAT     = "heart-beat"
 	HK_HOST           = "host" // HK_VHOST aloas
@@ -430,12 +701,16 @@ const (
 	HK_PASSCODE       = "passcode"
 	HK_RECEIPT        = "receipt"
 	HK_RECEIPT_ID     = "receipt-id"
+	HK_REPLY_TO       = "reply-to"
 	HK_SESSION        = "session"
 	HK_SERVER         = "server"
 	HK_SUBSCRIPTION   = "subscription"
 	HK_TRANSACTION    = "transaction"
 	HK_VERSION        = "version"
 	HK_VHOST          = "host" // HK_HOST alias
+	//
+	HK_AMQ_PREFETCH = "activemq.prefetchSize" // ActiveMQ SUBSCRIBE
+	HK_RMQ_PREFETCH = "prefetch-count"        // RabbitMQ SUBSCRIBE
 )
 
 /*
@@ -453,6 +728,33 @@ var (
 	validAckModes1x = map[string]bool{AckModeClientIndividual: true}
 )
 
+/*
+	Flow control policies, values of the StompPlusFlow SUBSCRIBE header.  They
+	decide what the reader does with a MESSAGE when the subscription
+	MessageData channel is full.
+*/
+const (
+	// Wait for room.  This stalls every subscription on the connection.
+	// The default.
+	FlowBlock = "block"
+
+	// Discard the new MESSAGE.
+	FlowDropNewest = "drop-newest"
+
+	// Discard the oldest queued MESSAGE, and queue the new one.  The
+	// channel capacity must be at least 1.
+	FlowDropOldest = "drop-oldest"
+
+	// Queue the MESSAGE to a bounded overflow buffer, of StompPlusOverflow
+	// entries.  Wait for room only when that is also full.
+	FlowOverflow = "overflow"
+)
+
+var (
+	validFlowPolicies = map[string]bool{FlowBlock: true, FlowDropNewest: true,
+		FlowDropOldest: true, FlowOverflow: true}
+)
+
 /*
 	Default content-type.
 */
@@ -460,12 +762,22 @@ const (
 	DFLT_CONTENT_TYPE = "text/plain; charset=UTF-8"
 )
 
+/*
+	Content-types with a built in Codec.
+*/
+const (
+	CT_JSON = "application/json"  // The SendTyped and Decode default
+	CT_GOB  = "application/x-gob" // Go gob encoding
+)
+
 /*
 	Extensions to STOMP protocol.
 */
 const (
 	StompPlusDrainAfter = "sng_drafter" // SUBSCRIBE Header
 	StompPlusDrainNow   = "sng_drnow"   // UNSUBSCRIBE Header
+	StompPlusFlow       = "sng_flow"    // SUBSCRIBE Header, flow control policy
+	StompPlusOverflow   = "sng_ovfl"    // SUBSCRIBE Header, overflow buffer size
 )
 
 var (
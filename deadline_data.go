@@ -16,7 +16,10 @@
 
 package stompngo
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 /*
 	ExpiredNotification is a callback function, provided by the client
@@ -43,6 +46,9 @@ type deadlineData struct {
 	t0   time.Time     // 0 value of Time
 	//
 	rfsw bool // Attempt to recover from short writes
+	//
+	wdlk sync.Mutex // Guards write deadline changes
+	wabt bool       // A context abort of the current write is pending
 }
 
 /*
@@ -111,3 +117,30 @@ func (c *Connection) IsReadDeadlineEnabled() bool {
 func (c *Connection) ShortWriteRecovery(ro bool) {
 	c.dld.rfsw = ro // Set recovery option
 }
+
+/*
+	Arm the write deadline for the next wire write, if enabled.  A pending
+	context abort keeps its deadline in the past.
+*/
+func (c *Connection) armWriteDeadline() {
+	if !c.dld.wde || !c.dld.wds {
+		return
+	}
+	c.dld.wdlk.Lock()
+	if !c.dld.wabt {
+		_ = c.netconn.SetWriteDeadline(time.Now().Add(c.dld.wdld))
+	}
+	c.dld.wdlk.Unlock()
+}
+
+/*
+	Clear the write deadline after a wire write, unless a context abort is
+	pending.
+*/
+func (c *Connection) clearWriteDeadline() {
+	c.dld.wdlk.Lock()
+	if !c.dld.wabt {
+		_ = c.netconn.SetWriteDeadline(c.dld.t0)
+	}
+	c.dld.wdlk.Unlock()
+}
@@ -0,0 +1,332 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"net/url"
+	"time"
+)
+
+/*
+	DialOption is a function that modifies Dial behavior.
+*/
+type DialOption func(*dialConfig)
+
+/*
+	Dial configuration, built from DialOptions.
+*/
+type dialConfig struct {
+	tlsc *tls.Config     // TLS configuration, nil for plain TCP
+	dto  time.Duration   // Dial timeout, 0 means none
+	dka  time.Duration   // TCP keep alive period, 0 means the net package default
+	lgr  *slog.Logger    // Connection logger
+	wtrc bool            // Wire trace enabled
+	orph OrphanPolicy    // Orphan MESSAGE policy
+	lobs LatencyObserver // Elapsed time observer
+	trc  Tracer          // Tracer
+	rlim *ReadLimits     // Read limits, nil for the default
+}
+
+/*
+	URL scheme data.
+*/
+var dialSchemes = map[string]struct {
+	tls  bool   // Use TLS
+	port string // Default port
+}{
+	"stomp":     {false, "61613"},
+	"tcp":       {false, "61613"},
+	"stomp+ssl": {true, "61612"},
+	"ssl":       {true, "61612"},
+}
+
+/*
+	DialTimeout sets the timeout for the network dial.  The timeout does not
+	include the CONNECT handshake, which is bounded only by the Dial context.
+*/
+func DialTimeout(d time.Duration) DialOption {
+	return func(dc *dialConfig) {
+		dc.dto = d
+	}
+}
+
+/*
+	DialKeepAlive sets the TCP keep alive period.  A negative value disables
+	keep alives.
+*/
+func DialKeepAlive(d time.Duration) DialOption {
+	return func(dc *dialConfig) {
+		dc.dka = d
+	}
+}
+
+/*
+	DialTLSConfig sets the TLS configuration used for stomp+ssl:// and ssl://
+	URLs.  Client certificates, the CA pool, and any SNI server name are all
+	taken from the supplied configuration.  If ServerName is not set, the URL
+	host name is used.
+*/
+func DialTLSConfig(tc *tls.Config) DialOption {
+	return func(dc *dialConfig) {
+		dc.tlsc = tc
+	}
+}
+
+/*
+	DialLogger sets the connection logger.  Unlike Connection.SetLogger, the
+	CONNECT handshake is logged.
+*/
+func DialLogger(l *slog.Logger) DialOption {
+	return func(dc *dialConfig) {
+		dc.lgr = l
+	}
+}
+
+/*
+	DialWireTrace enables or disables the frame level wire trace, starting with
+	the CONNECT frame.  See Connection.SetWireTrace.
+*/
+func DialWireTrace(on bool) DialOption {
+	return func(dc *dialConfig) {
+		dc.wtrc = on
+	}
+}
+
+/*
+	DialOrphanPolicy sets the handling of MESSAGE frames for unknown
+	subscriptions.  See Connection.SetOrphanPolicy.
+*/
+func DialOrphanPolicy(p OrphanPolicy) DialOption {
+	return func(dc *dialConfig) {
+		dc.orph = p
+	}
+}
+
+/*
+	DialLatencyObserver enables elapsed time tracking, as for STOMP_TRACKELT,
+	and reports each measurement to o.  See LatencyObserver.
+*/
+func DialLatencyObserver(o LatencyObserver) DialOption {
+	return func(dc *dialConfig) {
+		dc.lobs = o
+	}
+}
+
+/*
+	DialTracer sets the connection Tracer.  See Connection.SetTracer.
+*/
+func DialTracer(t Tracer) DialOption {
+	return func(dc *dialConfig) {
+		dc.trc = t
+	}
+}
+
+/*
+	DialReadLimits sets the limits on frames received from the broker,
+	starting with the CONNECT response.  See Connection.SetReadLimits.
+*/
+func DialReadLimits(l ReadLimits) DialOption {
+	return func(dc *dialConfig) {
+		dc.rlim = &l
+	}
+}
+
+/*
+	Dial a STOMP broker, and perform the CONNECT handshake.
+
+	Supported URL schemes are stomp:// and tcp:// for plain connections, and
+	stomp+ssl:// and ssl:// for TLS connections.  If the port is omitted,
+	61613 is used for plain connections, and 61612 for TLS connections.
+	Any user information in the URL is used for the login and passcode
+	headers, unless those headers are already present.
+
+	The Headers parameter is used exactly as with Connect.
+
+	ctx bounds both the dial and the CONNECT handshake.
+
+	The returned Connection owns the network connection, which is closed when
+	the connection is shut down.  On error the network connection is closed,
+	and any partially initialized Connection is returned, as with Connect.
+
+	A plain client that reaches a TLS port gets an error that wraps EBADSSLP.
+
+	Example:
+		h := stompngo.Headers{stompngo.HK_ACCEPT_VERSION, "1.2",
+			stompngo.HK_HOST, "localhost"}
+		c, e := stompngo.Dial(ctx, "stomp://localhost:61613", h,
+			stompngo.DialTimeout(5*time.Second))
+		if e != nil {
+			// Do something sane ...
+		}
+		// Use c
+*/
+func Dial(ctx context.Context, rawurl string, h Headers,
+	opts ...DialOption) (*Connection, error) {
+	dc := &dialConfig{}
+	for _, o := range opts {
+		o(dc)
+	}
+	u, e := url.Parse(rawurl)
+	if e != nil {
+		return nil, e
+	}
+	sd, ok := dialSchemes[u.Scheme]
+	if !ok {
+		return nil, EBADSCHEME
+	}
+	if u.Hostname() == "" {
+		return nil, EBADURLHOST
+	}
+	hap := u.Host
+	if u.Port() == "" {
+		hap = net.JoinHostPort(u.Hostname(), sd.port)
+	}
+	if u.User != nil {
+		h = h.Clone() // A nil h gets just the URL credentials
+		if _, ok := h.Contains(HK_LOGIN); !ok {
+			h = h.Add(HK_LOGIN, u.User.Username())
+		}
+		if p, ok := u.User.Password(); ok {
+			if _, ok := h.Contains(HK_PASSCODE); !ok {
+				h = h.Add(HK_PASSCODE, p)
+			}
+		}
+	}
+	//
+	nd := &net.Dialer{Timeout: dc.dto, KeepAlive: dc.dka}
+	n, e := nd.DialContext(ctx, NetProtoTCP, hap)
+	if e != nil {
+		return nil, e
+	}
+	if sd.tls {
+		tc := &tls.Config{}
+		if dc.tlsc != nil {
+			tc = dc.tlsc.Clone()
+		}
+		if tc.ServerName == "" {
+			tc.ServerName = u.Hostname()
+		}
+		tn := tls.Client(n, tc)
+		if e = tn.HandshakeContext(ctx); e != nil {
+			_ = n.Close()
+			return nil, e
+		}
+		n = tn
+	}
+	//
+	c, e := connectContext(ctx, n, h, connOpts{clnc: true, lgr: dc.lgr,
+		wtrc: dc.wtrc, orph: dc.orph, lobs: dc.lobs, trc: dc.trc, rlim: dc.rlim})
+	if e != nil {
+		_ = n.Close()
+		if e == EBADSSLP && !sd.tls {
+			e = &CONNERROR{e, "plain client hit TLS port " + hap +
+				", use a stomp+ssl:// or ssl:// URL"}
+		}
+		return c, e
+	}
+	return c, nil
+}
+
+/*
+	DialTLS dials a STOMP broker using TLS, regardless of the URL scheme, and
+	performs the CONNECT handshake.  See Dial for details.
+*/
+func DialTLS(ctx context.Context, rawurl string, h Headers, tc *tls.Config,
+	opts ...DialOption) (*Connection, error) {
+	u, e := url.Parse(rawurl)
+	if e != nil {
+		return nil, e
+	}
+	if _, ok := dialSchemes[u.Scheme]; !ok {
+		return nil, EBADSCHEME
+	}
+	u.Scheme = "stomp+ssl"
+	opts = append(opts, DialTLSConfig(tc))
+	return Dial(ctx, u.String(), h, opts...)
+}
+
+/*
+	DialConn performs the CONNECT handshake on an already open network
+	connection, such as a WebSocket adapter or a Unix socket.  Unlike Connect,
+	the returned Connection owns n, and closes it when the connection is shut
+	down or the handshake fails.  ctx bounds the handshake.  DialOptions that
+	apply to the network dial are ignored.
+*/
+func DialConn(ctx context.Context, n net.Conn, h Headers,
+	opts ...DialOption) (*Connection, error) {
+	dc := &dialConfig{}
+	for _, o := range opts {
+		o(dc)
+	}
+	c, e := connectContext(ctx, n, h, connOpts{clnc: true, lgr: dc.lgr,
+		wtrc: dc.wtrc, orph: dc.orph, lobs: dc.lobs, trc: dc.trc, rlim: dc.rlim})
+	if e != nil {
+		_ = n.Close()
+	}
+	return c, e
+}
+
+/*
+	Connect, with the CONNECT handshake bounded by ctx.
+*/
+func connectContext(ctx context.Context, n net.Conn, h Headers,
+	co connOpts) (*Connection, error) {
+	if ctx.Done() == nil {
+		return connect(n, h, co)
+	}
+	dl, dok := ctx.Deadline()
+	if dok {
+		_ = n.SetDeadline(dl)
+	}
+	sc := make(chan struct{})
+	dc := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = n.SetDeadline(time.Unix(1, 0)) // Unblock the handshake
+			dc <- true
+		case <-sc:
+			dc <- false
+		}
+	}()
+	c, e := connect(n, h, co)
+	close(sc)
+	if <-dc && e == nil {
+		// ctx ended as the handshake succeeded.  The reader and heart beats
+		// are running, and may already have seen the past deadline, so tear
+		// the connection down completely.
+		c.sysAbort()
+		c.closeNetconn()
+		c.shutdown()
+		for range c.input {
+		} // Until the reader ends
+		e = ctx.Err()
+	}
+	_ = n.SetDeadline(time.Time{})
+	if e != nil {
+		if ctx.Err() != nil {
+			e = ctx.Err()
+		} else if ne, ok := e.(net.Error); ok && ne.Timeout() && dok {
+			e = context.DeadlineExceeded // The network noticed first
+		}
+	}
+	return c, e
+}
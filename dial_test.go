@@ -0,0 +1,216 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+/*
+	Test Dial URL validation.
+*/
+func TestDialBadURL(t *testing.T) {
+	for _, dd := range dialBadURLs {
+		_, e = Dial(context.Background(), dd.url, empty_headers)
+		if e != dd.err {
+			t.Fatalf("TestDialBadURL Expected [%v], got [%v] for [%s]\n",
+				dd.err, e, dd.url)
+		}
+	}
+}
+
+/*
+	Test Dial, plain TCP, with URL user information.
+*/
+func TestDialPlain(t *testing.T) {
+	fc := make(chan string, 1)
+	hap := listenBroker(t, nil, func(pb *pipe_broker) {
+		f, _ := pb.readFrame()
+		fc <- f
+		_, _ = pb.n.Write([]byte(ctxConnected))
+		_, _ = pb.readFrame() // DISCONNECT
+		// Dial owns the connection, it must be closed after DISCONNECT
+		if _, e := pb.r.ReadByte(); e == nil {
+			t.Errorf("TestDialPlain Expected closed connection\n")
+		}
+	})
+	ctx, cf := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cf()
+	c, e := Dial(ctx, "stomp://dialuser:dialpw@"+hap, ctxHeaders,
+		DialTimeout(time.Second), DialKeepAlive(-1))
+	if e != nil {
+		t.Fatalf("TestDialPlain Expected [nil], got [%v]\n", e)
+	}
+	f := <-fc
+	if !strings.Contains(f, "login:dialuser\n") ||
+		!strings.Contains(f, "passcode:dialpw\n") {
+		t.Fatalf("TestDialPlain Expected URL credentials, got [%q]\n", f)
+	}
+	e = c.Disconnect(NoDiscReceipt)
+	if e != nil {
+		t.Fatalf("TestDialPlain Expected [nil], got [%v]\n", e)
+	}
+}
+
+/*
+	Test Dial with nil headers still sends the URL user information.
+*/
+func TestDialNilHeaders(t *testing.T) {
+	fc := make(chan string, 1)
+	hap := listenBroker(t, nil, func(pb *pipe_broker) {
+		f, _ := pb.readFrame()
+		fc <- f
+		_, _ = pb.n.Write([]byte(dialConnected10))
+		_, _ = pb.readFrame() // DISCONNECT
+	})
+	ctx, cf := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cf()
+	c, e := Dial(ctx, "stomp://dialuser:dialpw@"+hap, nil)
+	if e != nil {
+		t.Fatalf("TestDialNilHeaders Expected [nil], got [%v]\n", e)
+	}
+	f := <-fc
+	if !strings.Contains(f, "login:dialuser\n") ||
+		!strings.Contains(f, "passcode:dialpw\n") {
+		t.Fatalf("TestDialNilHeaders Expected URL credentials, got [%q]\n", f)
+	}
+	_ = c.Disconnect(NoDiscReceipt)
+}
+
+/*
+	Test Dial, plain client connected to a TLS port.
+*/
+func TestDialPlainToTLS(t *testing.T) {
+	hap := listenBroker(t, nil, func(pb *pipe_broker) {
+		_, _ = pb.r.ReadByte()
+		// A TLS alert record, as sent by many TLS servers
+		_, _ = pb.n.Write([]byte{0x15, 0x03, 0x03, 0x00, 0x02, 0x02, 0x0a})
+	})
+	_, e = Dial(context.Background(), "tcp://"+hap, ctxHeaders)
+	if !errors.Is(e, EBADSSLP) {
+		t.Fatalf("TestDialPlainToTLS Expected [%v], got [%v]\n", EBADSSLP, e)
+	}
+	if !strings.Contains(e.Error(), "plain client hit TLS port") {
+		t.Fatalf("TestDialPlainToTLS Expected TLS port text, got [%v]\n", e)
+	}
+}
+
+/*
+	Test DialTLS, with a private CA pool and SNI.
+*/
+func TestDialTLS(t *testing.T) {
+	cert := testCertificate(t)
+	sc := make(chan string, 1)
+	stc := &tls.Config{Certificates: []tls.Certificate{cert},
+		GetConfigForClient: func(hi *tls.ClientHelloInfo) (*tls.Config, error) {
+			sc <- hi.ServerName
+			return nil, nil
+		}}
+	hap := listenBroker(t, stc, func(pb *pipe_broker) {
+		_, _ = pb.readFrame()
+		_, _ = pb.n.Write([]byte(ctxConnected))
+		_, _ = pb.readFrame() // DISCONNECT
+	})
+	cp := x509.NewCertPool()
+	cp.AddCert(cert.Leaf)
+	ctc := &tls.Config{RootCAs: cp, ServerName: "stomp.example"}
+	ctx, cf := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cf()
+	c, e := DialTLS(ctx, "stomp://"+hap, ctxHeaders, ctc)
+	if e != nil {
+		t.Fatalf("TestDialTLS Expected [nil], got [%v]\n", e)
+	}
+	if sn := <-sc; sn != "stomp.example" {
+		t.Fatalf("TestDialTLS Expected SNI [stomp.example], got [%s]\n", sn)
+	}
+	if c.Session() != "ctx-test" {
+		t.Fatalf("TestDialTLS Expected session [ctx-test], got [%s]\n",
+			c.Session())
+	}
+	e = c.Disconnect(NoDiscReceipt)
+	if e != nil {
+		t.Fatalf("TestDialTLS Expected [nil], got [%v]\n", e)
+	}
+}
+
+/*
+	Test Dial when the CONNECT handshake outlives the context.
+*/
+func TestDialHandshakeTimeout(t *testing.T) {
+	hap := listenBroker(t, nil, func(pb *pipe_broker) {
+		_, _ = pb.readFrame()
+		time.Sleep(time.Second) // No CONNECTED
+	})
+	ctx, cf := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cf()
+	_, e = Dial(ctx, "stomp://"+hap, ctxHeaders)
+	if e != context.DeadlineExceeded {
+		t.Fatalf("TestDialHandshakeTimeout Expected [%v], got [%v]\n",
+			context.DeadlineExceeded, e)
+	}
+}
+
+/*
+	Test DialConn owns the network connection: it is closed after DISCONNECT,
+	without a Close by the caller.
+*/
+func TestDialConn(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Expect(DISCONNECT).Receipt().WaitClose()
+	n, rc := s.Pipe()
+	c, e := DialConn(context.Background(), n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestDialConn Expected [nil], got [%v]\n", e)
+	}
+	if e = c.Disconnect(empty_headers); e != nil {
+		t.Fatalf("TestDialConn Expected [nil], got [%v]\n", e)
+	}
+	if se := <-rc; se != nil {
+		t.Fatalf("TestDialConn Script error [%v]\n", se)
+	}
+}
+
+/*
+	Test DialConn when ctx is canceled just as the CONNECTED frame arrives.
+	The handshake succeeds, but the connection is torn down completely and
+	the network connection closed.
+*/
+func TestDialConnCancelConnected(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).WaitClose()
+	n, rc := s.Pipe()
+	ctx, cf := context.WithCancel(context.Background())
+	defer cf()
+	c, e := DialConn(ctx, &cancel_read_conn{Conn: n, cf: cf}, ctxHeaders)
+	if e != context.Canceled {
+		t.Fatalf("TestDialConnCancelConnected Expected [%v], got [%v]\n",
+			context.Canceled, e)
+	}
+	if c != nil && c.Connected() {
+		t.Fatalf("TestDialConnCancelConnected Expected connected [false], got [true]\n")
+	}
+	if se := <-rc; se != nil {
+		t.Fatalf("TestDialConnCancelConnected Script error [%v]\n", se)
+	}
+}
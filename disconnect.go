@@ -17,6 +17,7 @@
 package stompngo
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
@@ -29,6 +30,8 @@ import (
 	Set connection status to false to disable further actions with this
 	connection.
 
+	Abort any Tx still open.
+
 
 	Obtain a receipt unless the client specifically indicates a receipt request
 	should be excluded.  If the client  actually asks for a receipt, use the
@@ -52,17 +55,30 @@ import (
 
 */
 func (c *Connection) Disconnect(h Headers) error {
+	return c.DisconnectContext(context.Background(), h)
+}
+
+/*
+	DisconnectContext disconnects from a STOMP broker, honoring ctx
+	cancellation and deadlines while the DISCONNECT frame is written and
+	while waiting for any RECEIPT.
+
+	If ctx is done first, ctx.Err() is returned.  Unless ECONBAD is returned,
+	the connection is shut down in all cases.  See Disconnect for details.
+*/
+func (c *Connection) DisconnectContext(ctx context.Context, h Headers) error {
 	c.discLock.Lock()
 	defer c.discLock.Unlock()
 	//
 	if !c.isConnected() {
 		return ECONBAD
 	}
-	c.log(DISCONNECT, "start", h)
+	c.logcmd(DISCONNECT, "start", h)
 	e := checkHeaders(h, c.Protocol())
 	if e != nil {
 		return e
 	}
+	c.abortTransactions(ctx)
 	ch := h.Clone()
 	// If the caller does not want a receipt do not ask for one.  Otherwise,
 	// add a receipt request if caller did not specifically ask for one.  This is
@@ -80,65 +96,81 @@ func (c *Connection) Disconnect(h Headers) error {
 	//
 	f := Frame{DISCONNECT, ch, NULLBUFF}
 	//
-	r := make(chan error)
-	if e = c.writeWireData(wiredata{f, r}); e != nil {
+	c.dscw.Store(!cwr)
+	e = c.transmitFrame(ctx, f, false)
+	if e != nil {
+		c.dscw.Store(false)
+	}
+	if e == ECONBAD {
 		return e
 	}
-	e = <-r
 	// Drive shutdown logic
 	// Only set DisconnectReceipt if we sucessfully received one, and it is
 	// the one we were expecting.
 	if !cwr && e == nil {
 		// Can be RECEIPT or ERROR frame
-		mds, e := c.getMessageData()
+		var mds MessageData
+		mds, e = c.getMessageData(ctx)
+		c.dscw.Store(false)
 		//
 		// fmt.Println(DISCONNECT, "sanchek", mds)
 		//
-		switch mds.Message.Command {
-		case ERROR:
-			e = fmt.Errorf("DISBRKERR -> %q", mds.Message)
-			c.log(DISCONNECT, "errf", e)
-		case RECEIPT:
-			gr := mds.Message.Headers.Value(HK_RECEIPT_ID)
-			if wrid != gr {
-				e = fmt.Errorf("%s wanted:%s got:%s", EBADRID, wrid, gr)
-				c.log(DISCONNECT, "nadrid", e)
-			} else {
-				c.DisconnectReceipt = mds
-				c.log(DISCONNECT, "OK")
+		if e == nil {
+			switch mds.Message.Command {
+			case ERROR:
+				e = &BrokerError{Message: mds.Message}
+				c.log(DISCONNECT, "errf", e)
+			case RECEIPT:
+				gr := mds.Message.Headers.Value(HK_RECEIPT_ID)
+				if wrid != gr {
+					e = fmt.Errorf("%w wanted:%s got:%s", EBADRID, wrid, gr)
+					c.log(DISCONNECT, "nadrid", e)
+				} else {
+					c.DisconnectReceipt = mds
+					c.log(DISCONNECT, "OK")
+				}
+			default:
+				e = &ProtocolError{EUNKFRMDIS, []byte(mds.Message.Command)}
+				c.log(DISCONNECT, "badf", e)
 			}
-		default:
-			e = fmt.Errorf("DISBADFRM -> %q", mds.Message)
-			c.log(DISCONNECT, "badf", e)
 		}
 	}
-	c.log(DISCONNECT, "ends", ch)
+	c.logcmd(DISCONNECT, "ends", ch)
+	if e != nil {
+		// The reader can be blocked delivering to a stalled consumer, holding
+		// c.subs.  Abort first, so that shutdown can take that lock.
+		c.sysAbort()
+		c.closeNetconn()
+	}
 	c.shutdown()
 	c.sysAbort()
 	c.log(DISCONNECT, "system shutdown cannel closed")
+	c.closeNetconn()
 	return e
 }
 
-func (c *Connection) getMessageData() (MessageData, error) {
+func (c *Connection) getMessageData(ctx context.Context) (MessageData, error) {
 	var md MessageData
 	var me error
 	me = nil
+	var to <-chan time.Time // nil, never fires
 	if os.Getenv("STOMP_MAXDISCTO") != "" {
 		d, e := time.ParseDuration(os.Getenv("STOMP_MAXDISCTO"))
 		if e != nil {
 			c.log("DISCGETMD PDERROR -> ", e)
-			md = <-c.input
 		} else {
 			c.log("DISCGETMD DUR -> ", d)
-			select {
-			case <-time.After(d):
-				me = EDISCTO
-			case md = <-c.input:
-			}
+			to = time.After(d)
 		}
 	} else {
 		c.log("DISNOMAX", me)
-		md = <-c.input
+	}
+	select {
+	case <-to:
+		me = EDISCTO
+	case <-ctx.Done():
+		me = ctx.Err()
+	case md = <-c.input:
 	}
 	//
 	return md, me
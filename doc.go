@@ -36,6 +36,17 @@
 			// Do something sane ...
 		}
 
+	Alternatively, Dial establishes the network connection, plain or TLS,
+	from a stomp://, stomp+ssl://, tcp:// or ssl:// URL, and then performs
+	the STOMP CONNECT.  A Connection returned by Dial owns its network
+	connection, and closes it at shutdown.
+
+		c, err := stompngo.Dial(ctx, "stomp+ssl://localhost:61612", h,
+			stompngo.DialTLSConfig(tc))
+		if err != nil {
+			// Do something sane ...
+		}
+
 
 	Shutdown
 
@@ -80,6 +91,70 @@
 
 	For details on Subscribe requirements and behavior, see: https://github.com/photostorm/stompngo/wiki/subscribe-and-messagedata
 
+	As an alternative, SubscribeFunc calls a MessageHandler for each
+	MESSAGE, from a pool of worker goroutines, and ACKs or NACKs the
+	message according to the handler result and the subscription ack mode.
+
+	SendAsync returns a SendFuture as soon as the writer accepts the frame,
+	and SetWriteBatch lets the writer put many queued frames on the wire with
+	a single flush.
+
+	Large bodies need not be held in memory.  SendStream copies a body from
+	an io.Reader, and after SetStreamBodies(true) a MESSAGE body is read
+	through MessageData.Stream.
+
+	Frames received from the broker are bounded by ReadLimits: command
+	length, header count, header line length, and body size.  A frame over
+	a limit is reported as a *FrameTooLargeError, which wraps
+	ErrFrameTooLarge, and shuts the connection down.  DefaultReadLimits
+	apply unless changed with SetReadLimits, SetMaxBodySize, or the
+	DialReadLimits option.
+
+
+	Transactions
+
+	Transaction begins a transaction with a generated id, and returns a Tx
+	whose Send, Ack, and Nack methods add the transaction header.
+	WithTransaction commits when its function returns nil, and aborts on an
+	error, a panic, or a failed commit.  Disconnect aborts any transaction still open.
+
+
+	Producer Pools
+
+	NewPool manages several connections to one broker for high volume
+	producers.  Pool.Send uses the least busy healthy connection, dials more
+	under load up to a maximum, and replaces connections whose reader has
+	exited, whose SEND failed, or whose heart beats kept failing.
+
+
+	Request and Reply
+
+	Request sends a MESSAGE with correlation-id and reply-to headers, and
+	waits for the reply.  Concurrent Requests on a connection share one
+	reply subscription, to a temporary destination chosen by the broker
+	convention (see ReplyStyle): /temp-queue/ with a SUBSCRIBE for ActiveMQ,
+	/temp-queue/ with no SUBSCRIBE for RabbitMQ, and an auto created queue
+	for ActiveMQ Artemis.  Respond serves a destination, sending the result
+	of a ReplyFunc to each request reply-to destination.
+
+
+	Typed Payloads
+
+	SendTyped marshals a value with the Codec registered for the
+	content-type header, and sets a content-type of CT_JSON if there is
+	none.  Decode unmarshals a MESSAGE body the same way.  Codecs for JSON
+	and gob are built in, RegisterCodec adds others, and a content-type
+	with no Codec is a *CodecError.
+
+
+	Graceful Close
+
+	Close refuses new operations with ECLOSING, waits for those in flight,
+	and then disconnects.  CloseDrain also waits until subscription
+	channels are empty and every client ack MESSAGE has been ACKed or NACKed.
+	CloseUnsubscribe unsubscribes each subscription first.  The ctx bounds
+	the whole Close; when it ends the connection is torn down.
+
 
 	RECEIPTs
 
@@ -95,5 +170,54 @@
 
 	package for several examples.
 
+	The exception is the WithReceipt family of methods (SendWithReceipt,
+	SubscribeWithReceipt, and so on).  These add a receipt header if one is
+	not supplied, and return only after the matching RECEIPT arrives.  Those
+	RECEIPT frames, and any ERROR frame carrying the same receipt-id, are
+	handed directly to the waiting caller, and are not queued to
+	stompngo.Connection.MessageData.
+
+
+	Errors
+
+	Errors wrap one of the package Error constants where possible, so test
+	them with errors.Is, for example errors.Is(e, stompngo.ECONBAD).  An ERROR
+	frame from the broker is returned as a *BrokerError, which wraps ECONERR
+	when it is the response to CONNECT.  A frame that can not be parsed is
+	reported as a *ProtocolError holding the offending bytes.  Use errors.As
+	to inspect either.
+
+
+	Broker Protocol Errors
+
+	A frame the client can not handle (a MESSAGE with no subscription header,
+	for example) shuts the connection down.  The error is delivered as
+	MessageData.Error on all subscription channels, and, if it has room, on
+	stompngo.Connection.MessageData.  Errors delivered this way, including
+	network read errors, wrap ECONBAD as well as the cause.
+
+	This is a change from earlier releases, where MessageData.Error was the
+	raw cause, for example io.EOF.  Code that compares MessageData.Error with
+	== must use errors.Is instead:
+
+		if errors.Is(md.Error, io.EOF) {
+			// The broker closed the connection ...
+		}
+
+	A MESSAGE for a subscription that is not known to the connection is an
+	orphan.  SetOrphanPolicy chooses whether orphans are dropped (the
+	default), delivered to stompngo.Connection.MessageData, or treated as a
+	protocol error (EORPHMSG).
+
+
+	Logging
+
+	Connection events are not logged by default.  SetLogger supplies a
+	*slog.Logger, and SetWireTrace adds a trace of every frame sent and
+	received, with any passcode header value redacted.  Use the DialLogger
+	and DialWireTrace options to also log the CONNECT handshake.
+
+		c.SetLogger(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
 */
 package stompngo
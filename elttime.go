@@ -19,8 +19,28 @@ package stompngo
 import (
 	"fmt"
 	"log"
+	"time"
 )
 
+/*
+	LatencyObserver receives each elapsed time measured by the reader and
+	writer.  The point names are those used by ShowEltdCsv:
+
+		ROV  - Reader overall, including any wait for the next frame
+		RCMD - Reader command
+		RIVH - Reader individual headers
+		RUN  - Reader until null
+		RBDY - Reader body
+		WOV  - Writer overall
+		WCMD - Writer command
+		WIVH - Writer individual headers
+		WBDY - Writer body
+
+	An observer is called from the reader and writer goroutines, and must not
+	block.
+*/
+type LatencyObserver func(point string, d time.Duration)
+
 type eltd struct {
 	ens int64 // elapsed nanoseconds
 	ec  int64 // call count
@@ -47,6 +67,21 @@ type eltmets struct {
 	wivh eltd
 	// Writer - Body
 	wbdy eltd
+
+	// Optional observer
+	obs LatencyObserver
+}
+
+/*
+	Record one elapsed time, started at st.
+*/
+func (m *eltmets) add(d *eltd, point string, st int64) {
+	ns := time.Now().UnixNano() - st
+	d.ens += ns
+	d.ec++
+	if m.obs != nil {
+		m.obs(point, time.Duration(ns))
+	}
 }
 
 func (c *Connection) ShowEltd(ll *log.Logger) {
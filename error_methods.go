@@ -16,9 +16,78 @@
 
 package stompngo
 
+import (
+	"fmt"
+)
+
 /*
 	Error returns a string for a particular Error.
 */
 func (e Error) Error() string {
 	return string(e)
 }
+
+/*
+	Error returns a string for a BrokerError, using the ERROR frame message
+	header.
+*/
+func (e *BrokerError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error() + ": " + e.Description()
+	}
+	return "broker returned ERROR frame: " + e.Description()
+}
+
+/*
+	Unwrap returns the BrokerError category, for use with errors.Is.
+*/
+func (e *BrokerError) Unwrap() error {
+	return e.Err
+}
+
+/*
+	Description returns the ERROR frame message header, or if that is not
+	present, the ERROR frame body.
+*/
+func (e *BrokerError) Description() string {
+	if v, ok := e.Message.Headers.Contains(HK_MESSAGE); ok {
+		return v
+	}
+	return e.Message.BodyString()
+}
+
+/*
+	ReceiptId returns the ERROR frame receipt-id header, if any.
+*/
+func (e *BrokerError) ReceiptId() string {
+	return e.Message.Headers.Value(HK_RECEIPT_ID)
+}
+
+/*
+	Error returns a string for a HandlerPanic.
+*/
+func (e *HandlerPanic) Error() string {
+	return fmt.Sprintf("MessageHandler panic: %v", e.Value)
+}
+
+/*
+	Error returns a string for a CodecError.
+*/
+func (e *CodecError) Error() string {
+	return "no codec for content-type: " + e.ContentType
+}
+
+/*
+	Error returns a string for a ProtocolError, including a hex dump of the
+	offending data.
+*/
+func (e *ProtocolError) Error() string {
+	return e.Err.Error() + HexData(e.Data)
+}
+
+/*
+	Unwrap returns the ProtocolError cause, for use with errors.Is.
+*/
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,264 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+/*
+	Fault Test: malformed CONNECT responses.
+*/
+func TestFaultConnectResponse(t *testing.T) {
+	for i, fd := range faultConnResponses {
+		s := stomptest.NewScript().Expect(CONNECT).Write(fd.resp)
+		n, rc := s.Pipe()
+		_, e = Connect(n, ctxHeaders)
+		if !errors.Is(e, fd.err) {
+			t.Fatalf("TestFaultConnectResponse[%d] Expected [%v], got [%v]\n",
+				i, fd.err, e)
+		}
+		var pe *ProtocolError
+		if !errors.As(e, &pe) || len(pe.Data) == 0 {
+			t.Fatalf("TestFaultConnectResponse[%d] Expected *ProtocolError, got [%v]\n",
+				i, e)
+		}
+		_ = n.Close()
+		if se := <-rc; se != nil {
+			t.Fatalf("TestFaultConnectResponse[%d] Script error [%v]\n", i, se)
+		}
+	}
+}
+
+/*
+	Fault Test: a MESSAGE body dripped a few bytes at a time.
+*/
+func TestFaultSlowDrip(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Expect(SUBSCRIBE, HK_ID, "fault-sub").
+		Drip(faultMessage, 3, 2*time.Millisecond).
+		Expect(DISCONNECT).Receipt().WaitClose()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestFaultSlowDrip Expected [nil], got [%v]\n", e)
+	}
+	sc, e := c.Subscribe(Headers{HK_DESTINATION, "/queue/fault", HK_ID, "fault-sub"})
+	if e != nil {
+		t.Fatalf("TestFaultSlowDrip Expected [nil], got [%v]\n", e)
+	}
+	md := <-sc
+	if md.Error != nil || md.Message.BodyString() != "fault message" {
+		t.Fatalf("TestFaultSlowDrip Expected [fault message], got [%v] [%v]\n",
+			md.Message.BodyString(), md.Error)
+	}
+	if e = c.Disconnect(empty_headers); e != nil {
+		t.Fatalf("TestFaultSlowDrip Expected [nil], got [%v]\n", e)
+	}
+	_ = n.Close()
+	if se := <-rc; se != nil {
+		t.Fatalf("TestFaultSlowDrip Script error [%v]\n", se)
+	}
+}
+
+/*
+	Fault Test: the connection drops part way through a MESSAGE.
+*/
+func TestFaultDropMidFrame(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Expect(SUBSCRIBE).Write(faultMessage[:len(faultMessage)-8]).Drop()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestFaultDropMidFrame Expected [nil], got [%v]\n", e)
+	}
+	sc, e := c.Subscribe(Headers{HK_DESTINATION, "/queue/fault", HK_ID, "fault-sub"})
+	if e != nil {
+		t.Fatalf("TestFaultDropMidFrame Expected [nil], got [%v]\n", e)
+	}
+	select {
+	case md := <-sc:
+		if md.Error == nil {
+			t.Fatalf("TestFaultDropMidFrame Expected an error, got [%v]\n", md.Message)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("TestFaultDropMidFrame Expected an error, got none\n")
+	}
+	if se := <-rc; se != nil {
+		t.Fatalf("TestFaultDropMidFrame Script error [%v]\n", se)
+	}
+	_ = n.Close()
+}
+
+/*
+	Fault Test: the broker's heart beats stall, then resume.
+*/
+func TestFaultStalledHeartBeats(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).
+		Connected(HK_VERSION, SPL_12, HK_HEART_BEAT, "50,0").
+		HeartBeats(3, 40*time.Millisecond).Pause(200*time.Millisecond).
+		HeartBeats(5, 40*time.Millisecond).Drop()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders.Add(HK_HEART_BEAT, "0,50"))
+	if e != nil {
+		t.Fatalf("TestFaultStalledHeartBeats Expected [nil], got [%v]\n", e)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if c.HeartBeatReceiveFailed() {
+		t.Fatalf("TestFaultStalledHeartBeats Expected clean reads\n")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if !c.HeartBeatReceiveFailed() {
+		t.Fatalf("TestFaultStalledHeartBeats Expected a dirty read\n")
+	}
+	time.Sleep(150 * time.Millisecond)
+	if c.HeartBeatReceiveFailed() {
+		t.Fatalf("TestFaultStalledHeartBeats Expected reads clean again\n")
+	}
+	if se := <-rc; se != nil {
+		t.Fatalf("TestFaultStalledHeartBeats Script error [%v]\n", se)
+	}
+	_ = n.Close()
+}
+
+/*
+	Fault Test: a MESSAGE without a subscription header shuts the connection
+	down, and is reported to subscribers.
+*/
+func TestFaultMessageNoSub(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Expect(SUBSCRIBE).Write(faultNoSubMessage).WaitClose()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestFaultMessageNoSub Expected [nil], got [%v]\n", e)
+	}
+	sc, e := c.Subscribe(Headers{HK_DESTINATION, "/queue/fault", HK_ID, "fault-sub"})
+	if e != nil {
+		t.Fatalf("TestFaultMessageNoSub Expected [nil], got [%v]\n", e)
+	}
+	select {
+	case md := <-sc:
+		if !errors.Is(md.Error, EMSGNOSUB) || !errors.Is(md.Error, ECONBAD) {
+			t.Fatalf("TestFaultMessageNoSub Expected [%v], got [%v]\n", EMSGNOSUB,
+				md.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("TestFaultMessageNoSub Expected an error, got none\n")
+	}
+	_ = n.Close()
+	<-rc
+	if c.Connected() {
+		t.Fatalf("TestFaultMessageNoSub Expected a closed connection\n")
+	}
+}
+
+/*
+	Fault Test: each orphan MESSAGE policy.
+*/
+func TestFaultOrphanPolicy(t *testing.T) {
+	for i, fo := range faultOrphans {
+		s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+			Expect(SUBSCRIBE).Write(faultOrphanMessage).
+			Expect(DISCONNECT).Receipt().WaitClose()
+		n, rc := s.Pipe()
+		c, e := Connect(n, ctxHeaders)
+		if e != nil {
+			t.Fatalf("TestFaultOrphanPolicy[%d] Expected [nil], got [%v]\n", i, e)
+		}
+		c.SetOrphanPolicy(fo.op)
+		sc, e := c.Subscribe(Headers{HK_DESTINATION, "/queue/fault", HK_ID, "fault-sub"})
+		if e != nil {
+			t.Fatalf("TestFaultOrphanPolicy[%d] Expected [nil], got [%v]\n", i, e)
+		}
+		if fo.err != nil {
+			md := <-sc
+			if !errors.Is(md.Error, fo.err) {
+				t.Fatalf("TestFaultOrphanPolicy[%d] Expected [%v], got [%v]\n", i,
+					fo.err, md.Error)
+			}
+			_ = n.Close()
+			<-rc
+			continue
+		}
+		if fo.body != "" {
+			md := <-c.MessageData
+			if md.Message.BodyString() != fo.body {
+				t.Fatalf("TestFaultOrphanPolicy[%d] Expected [%s], got [%s]\n", i,
+					fo.body, md.Message.BodyString())
+			}
+		}
+		if e = c.Disconnect(empty_headers); e != nil {
+			t.Fatalf("TestFaultOrphanPolicy[%d] Expected [nil], got [%v]\n", i, e)
+		}
+		if md, ok := <-sc; ok { // Closed by Disconnect
+			t.Fatalf("TestFaultOrphanPolicy[%d] Expected no message, got [%v]\n", i,
+				md.Message)
+		}
+		_ = n.Close()
+		if se := <-rc; se != nil {
+			t.Fatalf("TestFaultOrphanPolicy[%d] Script error [%v]\n", i, se)
+		}
+	}
+}
+
+/*
+	Fault Test: an ERROR frame in response to CONNECT.
+*/
+func TestFaultConnectError(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(faultConnError)
+	n, rc := s.Pipe()
+	_, e := Connect(n, ctxHeaders)
+	if !errors.Is(e, ECONERR) {
+		t.Fatalf("TestFaultConnectError Expected [%v], got [%v]\n", ECONERR, e)
+	}
+	var be *BrokerError
+	if !errors.As(e, &be) || be.Description() != "bad login" {
+		t.Fatalf("TestFaultConnectError Expected *BrokerError, got [%v]\n", e)
+	}
+	_ = n.Close()
+	if se := <-rc; se != nil {
+		t.Fatalf("TestFaultConnectError Script error [%v]\n", se)
+	}
+}
+
+/*
+	Fault Test: an ERROR frame in response to DISCONNECT.
+*/
+func TestFaultDisconnectError(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Expect(DISCONNECT, HK_RECEIPT, "fault-disc").Write(faultDiscError).
+		WaitClose()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestFaultDisconnectError Expected [nil], got [%v]\n", e)
+	}
+	e = c.Disconnect(Headers{HK_RECEIPT, "fault-disc"})
+	var be *BrokerError
+	if !errors.As(e, &be) || be.ReceiptId() != "fault-disc" {
+		t.Fatalf("TestFaultDisconnectError Expected *BrokerError, got [%v]\n", e)
+	}
+	_ = n.Close()
+	if se := <-rc; se != nil {
+		t.Fatalf("TestFaultDisconnectError Script error [%v]\n", se)
+	}
+}
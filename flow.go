@@ -0,0 +1,206 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"strconv"
+)
+
+/*
+	Set up flow control for a new subscription, from the StompPlusFlow and
+	StompPlusOverflow headers.
+*/
+func (c *Connection) setFlow(sd *subscription, h Headers) error {
+	sd.fp = FlowBlock
+	if fp, ok := h.Contains(StompPlusFlow); ok {
+		if !validFlowPolicies[fp] {
+			return ESBADFLOW
+		}
+		sd.fp = fp
+	}
+	if sd.fp == FlowDropOldest && cap(sd.md) == 0 {
+		return ESBADCAP // Nothing queued to drop, makeRoom would spin
+	}
+	if sd.fp != FlowOverflow {
+		return nil
+	}
+	n := c.scc // Default overflow size
+	if n < 1 {
+		n = 1
+	}
+	if ov, ok := h.Contains(StompPlusOverflow); ok {
+		v, e := strconv.Atoi(ov)
+		if e != nil || v < 1 {
+			return ESBADOVFL
+		}
+		n = v
+	}
+	sd.ovch = make(chan MessageData, n)
+	sd.ovsd = make(chan struct{})
+	return nil
+}
+
+/*
+	Add broker prefetch headers derived from the subscription buffering, if
+	enabled and not supplied by the caller.
+*/
+func (c *Connection) addPrefetch(sd *subscription, h Headers) Headers {
+	if !c.apf.Load() {
+		return h
+	}
+	pf := strconv.FormatInt(max(int64(cap(sd.md)+cap(sd.ovch)), 1), 10)
+	if _, ok := h.Contains(HK_AMQ_PREFETCH); !ok {
+		h = h.Add(HK_AMQ_PREFETCH, pf)
+	}
+	if _, ok := h.Contains(HK_RMQ_PREFETCH); !ok {
+		h = h.Add(HK_RMQ_PREFETCH, pf)
+	}
+	return h
+}
+
+/*
+	Deliver a MESSAGE to a subscription, according to its flow control policy.
+	Caller holds a read lock on c.subs.
+*/
+func (sd *subscription) deliver(md MessageData) {
+	switch sd.fp {
+	case FlowDropNewest:
+		select {
+		case sd.md <- md:
+		default:
+			sd.drps.Add(1)
+			closeStream(md)
+		}
+	case FlowDropOldest:
+		sd.makeRoom(md)
+	case FlowOverflow:
+		sd.put(sd.ovch, md)
+	default:
+		sd.put(sd.md, md)
+	}
+}
+
+/*
+	Deliver a read error to a subscription.  The drop policies make room, so
+	that the error is never lost.  Caller holds a read lock on c.subs.
+*/
+func (sd *subscription) deliverError(md MessageData) {
+	switch sd.fp {
+	case FlowDropNewest, FlowDropOldest:
+		sd.makeRoom(md)
+	case FlowOverflow:
+		sd.put(sd.ovch, md)
+	default:
+		sd.put(sd.md, md)
+	}
+}
+
+/*
+	Queue md on ch, blocking until there is room.  Once the connection is
+	aborted md is dropped instead, so that a stalled consumer can not hold the
+	reader, and c.subs with it, forever.
+*/
+func (sd *subscription) put(ch chan MessageData, md MessageData) {
+	select {
+	case ch <- md:
+		return
+	default:
+	}
+	select {
+	case ch <- md:
+	case <-sd.ssdc:
+		sd.drps.Add(1)
+		closeStream(md)
+	}
+}
+
+/*
+	Queue md, discarding the oldest queued values until it fits.  Receivers
+	may empty the channel concurrently.  An unbuffered channel has nothing to
+	discard, so then md waits for a receiver.
+*/
+func (sd *subscription) makeRoom(md MessageData) {
+	if cap(sd.md) == 0 {
+		sd.put(sd.md, md)
+		return
+	}
+	for {
+		select {
+		case sd.md <- md:
+			return
+		default:
+		}
+		select {
+		case od := <-sd.md:
+			sd.drps.Add(1)
+			closeStream(od)
+		default:
+		}
+	}
+}
+
+/*
+	Move MessageData from the overflow buffer to the subscription channel
+	(FlowOverflow).  This ends when the subscription is removed, or at
+	shutdown, after queueing what fits and closing the subscription channel.
+	A read error is queued at shutdown in place of older MessageData.
+*/
+func (sd *subscription) pump() {
+	for {
+		select {
+		case md, ok := <-sd.ovch:
+			if !ok { // Shutdown
+				close(sd.md)
+				return
+			}
+			select {
+			case sd.md <- md:
+				continue
+			default:
+			}
+			select {
+			case sd.md <- md:
+			case <-sd.ovsd:
+				if md.Error != nil { // The consumer must still see it
+					sd.makeRoom(md)
+					continue
+				}
+				sd.drps.Add(1)
+				closeStream(md)
+			case <-sd.uc:
+				return
+			}
+		case <-sd.uc:
+			return
+		}
+	}
+}
+
+/*
+	Close the subscription channel at shutdown.  With FlowOverflow the pump
+	goroutine closes it, once the overflow buffer is empty.  Caller holds the
+	c.subs write lock.
+*/
+func (sd *subscription) close() {
+	if sd.fp == FlowOverflow {
+		close(sd.ovsd)
+		close(sd.ovch)
+	} else {
+		close(sd.md)
+	}
+	sd.cs = true
+}
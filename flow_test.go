@@ -0,0 +1,219 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+/*
+	Test the flow control policies.  A full subscription must not stall
+	another subscription on the same connection.
+*/
+func TestFlowPolicies(t *testing.T) {
+	for _, fd := range flowData {
+		n, _ = openConn(t)
+		ch := headersProtocol(login_headers, SPL_12)
+		conn, e = Connect(n, ch)
+		if e != nil {
+			t.Fatalf("TestFlowPolicies CONNECT expected nil, got %v\n", e)
+		}
+		conn.SetSubChanCap(flowCap)
+		d := tdest("/queue/flow.policy." + fd.policy)
+		sh := Headers{HK_DESTINATION, d, HK_ID, d, StompPlusFlow, fd.policy}
+		if fd.policy == FlowOverflow {
+			sh = sh.Add(StompPlusOverflow, "3")
+		}
+		sc, e = conn.Subscribe(sh)
+		if e != nil {
+			t.Fatalf("TestFlowPolicies Expected no subscribe error, got [%v]\n", e)
+		}
+		mk := tdest("/queue/flow.marker." + fd.policy)
+		mc, e := conn.Subscribe(Headers{HK_DESTINATION, mk, HK_ID, mk})
+		if e != nil {
+			t.Fatalf("TestFlowPolicies Expected no subscribe error, got [%v]\n", e)
+		}
+		for _, b := range flowBodies {
+			if e = conn.Send(Headers{HK_DESTINATION, d}, b); e != nil {
+				t.Fatalf("TestFlowPolicies Expected nil error, got [%v]\n", e)
+			}
+		}
+		if e = conn.Send(Headers{HK_DESTINATION, mk}, "marker"); e != nil {
+			t.Fatalf("TestFlowPolicies Expected nil error, got [%v]\n", e)
+		}
+		select {
+		case <-mc:
+		case <-time.After(flowWait):
+			t.Fatalf("TestFlowPolicies [%s] marker not received\n", fd.policy)
+		}
+		for _, w := range fd.want {
+			mdr := getMessageData(sc, conn, t)
+			if got := mdr.Message.BodyString(); got != w {
+				t.Fatalf("TestFlowPolicies [%s] Expected [%s], got [%s]\n",
+					fd.policy, w, got)
+			}
+		}
+		if s := conn.Stats().Subscriptions[d]; s.Dropped != fd.dropped || s.Queued != 0 {
+			t.Fatalf("TestFlowPolicies [%s] Expected [%d] dropped, got [%v]\n",
+				fd.policy, fd.dropped, s)
+		}
+		checkReceived(t, conn, false)
+		e = conn.Disconnect(empty_headers)
+		checkDisconnectError(t, e)
+		_ = closeConn(t, n)
+	}
+}
+
+/*
+	Test invalid flow control headers.
+*/
+func TestFlowBadHeaders(t *testing.T) {
+	n, _ = openConn(t)
+	ch := headersProtocol(login_headers, SPL_12)
+	conn, e = Connect(n, ch)
+	if e != nil {
+		t.Fatalf("TestFlowBadHeaders CONNECT expected nil, got %v\n", e)
+	}
+	d := tdest("/queue/flow.bad")
+	_, e = conn.Subscribe(Headers{HK_DESTINATION, d, StompPlusFlow, "bogus"})
+	if e != ESBADFLOW {
+		t.Fatalf("TestFlowBadHeaders Expected [%v], got [%v]\n", ESBADFLOW, e)
+	}
+	_, e = conn.Subscribe(Headers{HK_DESTINATION, d, StompPlusFlow, FlowOverflow,
+		StompPlusOverflow, "0"})
+	if e != ESBADOVFL {
+		t.Fatalf("TestFlowBadHeaders Expected [%v], got [%v]\n", ESBADOVFL, e)
+	}
+	if s := conn.Stats().Subscriptions; len(s) != 0 {
+		t.Fatalf("TestFlowBadHeaders Expected no subscriptions, got [%v]\n", s)
+	}
+	checkReceived(t, conn, false)
+	e = conn.Disconnect(empty_headers)
+	checkDisconnectError(t, e)
+	_ = closeConn(t, n)
+}
+
+/*
+	Test prefetch header injection, off by default, caller supplied values,
+	and SetAutoPrefetch.
+*/
+func TestFlowPrefetch(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Expect(SUBSCRIBE, HK_ID, "flow-default").
+		Expect(SUBSCRIBE, HK_AMQ_PREFETCH, "5", HK_RMQ_PREFETCH, "5").
+		Expect(SUBSCRIBE, HK_AMQ_PREFETCH, "2", HK_RMQ_PREFETCH, "7").
+		Expect(SUBSCRIBE, HK_ID, "flow-none").
+		Expect(DISCONNECT).Receipt().WaitClose()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestFlowPrefetch Expected [nil], got [%v]\n", e)
+	}
+	c.SetSubChanCap(flowCap)
+	_, ch, e := c.subscribe(context.Background(), Headers{HK_DESTINATION,
+		"/queue/flow", HK_ID, "flow-default"}, false)
+	if e != nil {
+		t.Fatalf("TestFlowPrefetch Expected [nil], got [%v]\n", e)
+	}
+	if _, ok := ch.Contains(HK_AMQ_PREFETCH); ok {
+		t.Fatalf("TestFlowPrefetch Expected no prefetch header, got [%v]\n", ch)
+	}
+	c.SetAutoPrefetch(true)
+	_, e = c.Subscribe(Headers{HK_DESTINATION, "/queue/flow", HK_ID, "flow-ovfl",
+		StompPlusFlow, FlowOverflow, StompPlusOverflow, "3"})
+	if e != nil {
+		t.Fatalf("TestFlowPrefetch Expected [nil], got [%v]\n", e)
+	}
+	_, e = c.Subscribe(Headers{HK_DESTINATION, "/queue/flow", HK_ID, "flow-caller",
+		HK_RMQ_PREFETCH, "7"})
+	if e != nil {
+		t.Fatalf("TestFlowPrefetch Expected [nil], got [%v]\n", e)
+	}
+	c.SetAutoPrefetch(false)
+	_, e = c.Subscribe(Headers{HK_DESTINATION, "/queue/flow", HK_ID, "flow-none"})
+	if e != nil {
+		t.Fatalf("TestFlowPrefetch Expected [nil], got [%v]\n", e)
+	}
+	if e = c.Disconnect(empty_headers); e != nil {
+		t.Fatalf("TestFlowPrefetch Expected [nil], got [%v]\n", e)
+	}
+	_ = n.Close()
+	if se := <-rc; se != nil {
+		t.Fatalf("TestFlowPrefetch Script error [%v]\n", se)
+	}
+}
+
+/*
+	Test flow control with an unbuffered subscription channel.  FlowDropOldest
+	is rejected, and FlowDropNewest still delivers a read error.
+*/
+func TestFlowZeroCap(t *testing.T) {
+	c, pb := pipeConnect(t, ctxHeaders, ctxConnected)
+	defer pb.n.Close()
+	c.SetSubChanCap(0)
+	_, e = c.Subscribe(Headers{HK_DESTINATION, "/queue/flow.zero",
+		StompPlusFlow, FlowDropOldest})
+	if e != ESBADCAP {
+		t.Fatalf("TestFlowZeroCap Expected [%v], got [%v]\n", ESBADCAP, e)
+	}
+	go func() {
+		_, _ = pb.readFrame() // SUBSCRIBE
+		_ = pb.n.Close()
+	}()
+	sc, e := c.Subscribe(Headers{HK_DESTINATION, "/queue/flow.zero",
+		HK_ID, "flow-zero", StompPlusFlow, FlowDropNewest})
+	if e != nil {
+		t.Fatalf("TestFlowZeroCap Expected [nil], got [%v]\n", e)
+	}
+	select {
+	case md := <-sc:
+		if md.Error == nil {
+			t.Fatalf("TestFlowZeroCap Expected a read error, got [%v]\n", md)
+		}
+	case <-time.After(flowWait):
+		t.Fatalf("TestFlowZeroCap Expected a read error, got nothing\n")
+	}
+}
+
+/*
+	Test a read error queued to a full FlowOverflow subscription at shutdown.
+	It replaces older MessageData, and is not dropped.
+*/
+func TestFlowOverflowShutdownError(t *testing.T) {
+	sd := &subscription{md: make(chan MessageData, 1), uc: make(chan struct{}),
+		ssdc: make(chan struct{}), fp: FlowOverflow,
+		ovch: make(chan MessageData, 1), ovsd: make(chan struct{})}
+	go sd.pump()
+	for _, b := range flowBodies[:2] {
+		sd.deliver(MessageData{Message: Message{Command: MESSAGE, Body: []byte(b)}})
+	}
+	sd.deliverError(MessageData{Error: ECONBAD})
+	sd.close()
+	time.Sleep(flowSettle) // Let the pump finish, with the channel still full
+	var last MessageData
+	for md := range sd.md {
+		last = md
+	}
+	if last.Error != ECONBAD {
+		t.Fatalf("TestFlowOverflowShutdownError Expected [%v], got [%v]\n",
+			ECONBAD, last)
+	}
+}
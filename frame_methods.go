@@ -29,6 +29,13 @@ func (f *Frame) Size(e bool) int64 {
 	return r
 }
 
+/*
+	Whether the frame headers are encoded on the wire at protocol level p.
+*/
+func (f *Frame) encoded(p string) bool {
+	return p > SPL_10 && f.Command != CONNECT
+}
+
 /*
 	Bytes returns a byte slice of all frame data, ready for the wire
 */
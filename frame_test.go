@@ -0,0 +1,222 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+/*
+	A reader returning the same data forever.
+*/
+type loopReader struct {
+	b []byte
+	i int
+}
+
+func (r *loopReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		k := copy(p[n:], r.b[r.i:])
+		n += k
+		r.i = (r.i + k) % len(r.b)
+	}
+	return n, nil
+}
+
+/*
+	A Connection able to read and write frames without a network.
+*/
+func frameConn(r io.Reader, w io.Writer) *Connection {
+	c := &Connection{dld: &deadlineData{}, protocol: SPL_12}
+	c.rdr = bufio.NewReader(r)
+	c.wtr = bufio.NewWriter(w)
+	c.SetReadLimits(DefaultReadLimits)
+	return c
+}
+
+/*
+	Test that frames written are read back unchanged, with header escapes.
+	STOMP 1.0 has no escapes.
+*/
+func TestFrameRoundTrip(t *testing.T) {
+	for _, p := range []string{SPL_11, SPL_12} {
+		for _, fd := range frameRoundTrip {
+			var b bytes.Buffer
+			c := frameConn(&b, &b)
+			c.protocol = p
+			f := Frame{fd.Command, fd.Headers.Clone(), []byte(fd.Body)}
+			if e := f.writeFrame(c.wtr, c, nil); e != nil {
+				t.Fatalf("TestFrameRoundTrip Expected [nil], got [%v]\n", e)
+			}
+			if e := c.wtr.Flush(); e != nil {
+				t.Fatalf("TestFrameRoundTrip Expected [nil], got [%v]\n", e)
+			}
+			for i := 0; i < len(fd.Headers); i += 2 {
+				if v := f.Headers.Value(fd.Headers[i]); v != fd.Headers[i+1] {
+					t.Fatalf("TestFrameRoundTrip Headers changed, expected [%q], got [%q]\n",
+						fd.Headers[i+1], v)
+				}
+			}
+			r, e := c.readFrame()
+			if e != nil {
+				t.Fatalf("TestFrameRoundTrip Expected [nil], got [%v]\n", e)
+			}
+			if r.Command != fd.Command || string(r.Body) != fd.Body {
+				t.Fatalf("TestFrameRoundTrip Expected [%v %q], got [%v %q]\n",
+					fd.Command, fd.Body, r.Command, r.Body)
+			}
+			for i := 0; i < len(fd.Headers); i += 2 {
+				if v, _ := r.Headers.Contains(fd.Headers[i]); v != fd.Headers[i+1] {
+					t.Fatalf("TestFrameRoundTrip Expected [%v:%q], got [%q] protocol:%s\n",
+						fd.Headers[i], fd.Headers[i+1], v, p)
+				}
+			}
+		}
+	}
+}
+
+/*
+	Test FrameWriter and FrameReader, without a Connection.  A heart beat
+	reads as an empty Command, and CR LF line ends are accepted.
+*/
+func TestFrameReaderWriter(t *testing.T) {
+	for _, p := range []string{SPL_11, SPL_12} {
+		var b bytes.Buffer
+		fw := NewFrameWriter(&b)
+		fw.SetProtocol(p)
+		fr := NewFrameReader(&b, DefaultReadLimits)
+		fr.SetProtocol(p)
+		for _, fd := range frameRoundTrip {
+			if e := fw.WriteHeartBeat(); e != nil {
+				t.Fatalf("TestFrameReaderWriter Expected [nil], got [%v]\n", e)
+			}
+			f := Frame{fd.Command, fd.Headers, []byte(fd.Body)}
+			if e := fw.WriteFrame(f); e != nil {
+				t.Fatalf("TestFrameReaderWriter Expected [nil], got [%v]\n", e)
+			}
+			if r, e := fr.ReadFrame(); e != nil || r.Command != "" {
+				t.Fatalf("TestFrameReaderWriter Expected a heart beat, got [%v] [%v]\n",
+					r.Command, e)
+			}
+			r, e := fr.ReadFrame()
+			if e != nil {
+				t.Fatalf("TestFrameReaderWriter Expected [nil], got [%v]\n", e)
+			}
+			if r.Command != fd.Command || string(r.Body) != fd.Body {
+				t.Fatalf("TestFrameReaderWriter Expected [%v %q], got [%v %q]\n",
+					fd.Command, fd.Body, r.Command, r.Body)
+			}
+			for i := 0; i < len(fd.Headers); i += 2 {
+				if v := r.Headers.Value(fd.Headers[i]); v != fd.Headers[i+1] {
+					t.Fatalf("TestFrameReaderWriter Expected [%v:%q], got [%q] protocol:%s\n",
+						fd.Headers[i], fd.Headers[i+1], v, p)
+				}
+			}
+		}
+	}
+	fr := NewFrameReader(bytes.NewBufferString(frameCRLF), DefaultReadLimits)
+	r, e := fr.ReadFrame()
+	if e != nil {
+		t.Fatalf("TestFrameReaderWriter Expected [nil], got [%v]\n", e)
+	}
+	if r.Command != SEND || r.Headers.Value(HK_DESTINATION) != "/queue/crlf" ||
+		string(r.Body) != "crlf" {
+		t.Fatalf("TestFrameReaderWriter Expected a SEND, got [%v]\n", r)
+	}
+	if _, e = fr.ReadFrame(); e == nil {
+		t.Fatalf("TestFrameReaderWriter Expected an error, got [nil]\n")
+	}
+}
+
+/*
+	Test a Connection parses frames as FrameReader does: CR LF line ends are
+	accepted, and a body must be followed by a NUL.
+*/
+func TestFrameConnectionParse(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Expect(SUBSCRIBE).Write(frameConnCRLF).Write(frameConnNoNul).WaitClose()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestFrameConnectionParse Expected [nil], got [%v]\n", e)
+	}
+	sc, e := c.Subscribe(Headers{HK_DESTINATION, "/queue/crlf", HK_ID, "crlf-sub"})
+	if e != nil {
+		t.Fatalf("TestFrameConnectionParse Expected [nil], got [%v]\n", e)
+	}
+	md := <-sc // The error that follows is also sent to c.MessageData
+	if md.Error != nil || md.Message.Command != MESSAGE ||
+		md.Message.Headers.Value(HK_DESTINATION) != "/queue/crlf" ||
+		string(md.Message.Body) != "crlf" {
+		t.Fatalf("TestFrameConnectionParse Expected a MESSAGE, got [%v] [%v]\n",
+			md.Message, md.Error)
+	}
+	select {
+	case md = <-sc:
+		if !errors.Is(md.Error, EBADFRM) {
+			t.Fatalf("TestFrameConnectionParse Expected [%v], got [%v]\n", EBADFRM,
+				md.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("TestFrameConnectionParse Expected an error, got none\n")
+	}
+	_ = n.Close()
+	if se := <-rc; se != nil {
+		t.Fatalf("TestFrameConnectionParse Script error [%v]\n", se)
+	}
+}
+
+/*
+	Allocations per MESSAGE read.
+*/
+func BenchmarkReadFrame(b *testing.B) {
+	c := frameConn(&loopReader{b: []byte(frameBenchMessage)}, io.Discard)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(frameBenchMessage)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, e := c.readFrame(); e != nil {
+			b.Fatal(e)
+		}
+	}
+}
+
+/*
+	Allocations per MESSAGE written.
+*/
+func BenchmarkWriteFrame(b *testing.B) {
+	c := frameConn(nil, io.Discard)
+	h := frameRoundTrip[0].Headers.Add(HK_CONTENT_TYPE, "text/plain").
+		Add(HK_CONTENT_LENGTH, "64")
+	f := Frame{MESSAGE, h, bytes.Repeat([]byte("x"), 64)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g := f
+		if e := g.writeFrame(c.wtr, c, nil); e != nil {
+			b.Fatal(e)
+		}
+	}
+}
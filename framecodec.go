@@ -0,0 +1,132 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+)
+
+/*
+	FrameReader reads STOMP frames from any peer, client or broker, without a
+	Connection.  It uses the same parser, header decoding, and ReadLimits as
+	Connection, for servers, gateways, and test doubles.
+*/
+type FrameReader struct {
+	p frameParser
+}
+
+/*
+	NewFrameReader returns a FrameReader reading from r, with limits l.
+*/
+func NewFrameReader(r io.Reader, l ReadLimits) *FrameReader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &FrameReader{frameParser{r: br, lim: l}}
+}
+
+/*
+	SetProtocol sets the protocol level used to validate frame headers.  Until
+	it is set, as before CONNECT is negotiated, empty header values are
+	accepted.
+*/
+func (fr *FrameReader) SetProtocol(p string) {
+	fr.p.proto = p
+}
+
+/*
+	ReadFrame reads the next frame.  A heart beat is returned as a Frame with
+	an empty Command.  Any command defined by STOMP is accepted; others return
+	a ProtocolError wrapping EINVBCMD.
+*/
+func (fr *FrameReader) ReadFrame() (Frame, error) {
+	return fr.p.readFrame()
+}
+
+/*
+	FrameWriter writes STOMP frames to any peer, without a Connection.  Header
+	encoding follows the protocol level, as for Connection.  It is not safe
+	for concurrent use.
+*/
+type FrameWriter struct {
+	w     *bufio.Writer
+	proto string
+}
+
+/*
+	NewFrameWriter returns a FrameWriter writing to w.
+*/
+func NewFrameWriter(w io.Writer) *FrameWriter {
+	bw, ok := w.(*bufio.Writer)
+	if !ok {
+		bw = bufio.NewWriter(w)
+	}
+	return &FrameWriter{w: bw, proto: SPL_10}
+}
+
+/*
+	SetProtocol sets the protocol level used to encode frame headers.  The
+	default is SPL_10, with no encoding.
+*/
+func (fw *FrameWriter) SetProtocol(p string) {
+	fw.proto = p
+}
+
+/*
+	WriteFrame writes f and flushes.  A content-length header is added when a
+	non-empty body is written without one.  f.Headers is not changed.
+*/
+func (fw *FrameWriter) WriteFrame(f Frame) error {
+	enc := f.encoded(fw.proto) && f.Command != CONNECTED
+	if e := writeLine(fw.w, f.Command); e != nil {
+		return e
+	}
+	for i := 0; i < len(f.Headers); i += 2 {
+		if e := writeHeader(fw.w, f.Headers[i], f.Headers[i+1], enc); e != nil {
+			return e
+		}
+	}
+	if _, ok := f.Headers.Contains(HK_CONTENT_LENGTH); !ok && len(f.Body) > 0 {
+		e := writeHeader(fw.w, HK_CONTENT_LENGTH, strconv.Itoa(len(f.Body)), false)
+		if e != nil {
+			return e
+		}
+	}
+	if e := fw.w.WriteByte('\n'); e != nil {
+		return e
+	}
+	if _, e := fw.w.Write(f.Body); e != nil {
+		return e
+	}
+	if e := fw.w.WriteByte(0); e != nil {
+		return e
+	}
+	return fw.w.Flush()
+}
+
+/*
+	WriteHeartBeat writes a single EOL heart beat, and flushes.
+*/
+func (fw *FrameWriter) WriteHeartBeat() error {
+	if e := fw.w.WriteByte('\n'); e != nil {
+		return e
+	}
+	return fw.w.Flush()
+}
@@ -153,7 +153,7 @@ func (h Headers) Size(e bool) int64 {
 	l := 0
 	for i := 0; i < len(h); i += 2 {
 		if e {
-			l += len(encode(h[i])) + 1 + len(encode(h[i+1])) + 1
+			l += encodedLen(h[i]) + 1 + encodedLen(h[i+1]) + 1
 		} else {
 			l += len(h[i]) + 1 + len(h[i+1]) + 1
 		}
@@ -17,6 +17,7 @@
 package stompngo
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -119,7 +120,7 @@ func (c *Connection) initializeHeartBeats(ch Headers) (e error) {
 	The heart beat send ticker.
 */
 func (c *Connection) sendTicker() {
-	c.hbd.sc = 0
+	c.hbd.sc.Store(0)
 	ticker := time.NewTicker(time.Duration(c.hbd.sti))
 	defer ticker.Stop()
 hbSend:
@@ -129,20 +130,24 @@ hbSend:
 			c.log("HeartBeat Send data")
 			// Send a heartbeat
 			f := Frame{"\n", Headers{}, NULLBUFF} // Heartbeat frame
-			r := make(chan error)
-			if e := c.writeWireData(wiredata{f, r}); e != nil {
+			e := c.transmitFrame(context.Background(), f, false)
+			if e == ECONBAD {
 				c.Hbsf = true
+				c.hbsf.Store(true)
+				c.mets.hbsm.Add(1)
 				break hbSend
 			}
-			e := <-r
 			//
 			c.hbd.sdl.Lock()
 			if e != nil {
 				fmt.Printf("Heartbeat Send Failure: %v\n", e)
 				c.Hbsf = true
+				c.hbsf.Store(true)
+				c.mets.hbsm.Add(1)
 			} else {
 				c.Hbsf = false
-				c.hbd.sc++
+				c.hbsf.Store(false)
+				c.hbd.sc.Add(1)
 			}
 			c.hbd.sdl.Unlock()
 			//
@@ -160,7 +165,7 @@ hbSend:
 	The heart beat receive ticker.
 */
 func (c *Connection) receiveTicker() {
-	c.hbd.rc = 0
+	c.hbd.rc.Store(0)
 	var first, last, nd int64
 hbGet:
 	for {
@@ -183,9 +188,12 @@ hbGet:
 			if ld > (c.hbd.rti + (c.hbd.rti / 5)) { // swag plus to be tolerant
 				c.log("HeartBeat Receive Read is dirty")
 				c.Hbrf = true // Flag possible dirty connection
+				c.hbrf.Store(true)
+				c.mets.hbrm.Add(1)
 			} else {
 				c.Hbrf = false // Reset
-				c.hbd.rc++
+				c.hbrf.Store(false)
+				c.hbd.rc.Add(1)
 			}
 			c.hbd.rdl.Unlock()
 			last = time.Now().UnixNano()
@@ -18,14 +18,37 @@ package stompngo
 
 import (
 	"flag"
+	"net"
 	"os"
 	"testing"
+
+	"github.com/photostorm/stompngo/stomptest"
 )
 
 func TestMain(m *testing.M) {
 	flag.Parse()
 	packageInit()
-	os.Exit(m.Run())
+	s := startTestBroker()
+	rc := m.Run()
+	if s != nil {
+		_ = s.Close()
+	}
+	os.Exit(rc)
+}
+
+/*
+	Start an in-process broker, unless a live broker is named by STOMP_HOST
+	or STOMP_PORT.
+*/
+func startTestBroker() *stomptest.Server {
+	if os.Getenv("STOMP_HOST") != "" || os.Getenv("STOMP_PORT") != "" {
+		return nil
+	}
+	s := stomptest.NewServer()
+	h, p, _ := net.SplitHostPort(s.Addr)
+	_ = os.Setenv("STOMP_HOST", h)
+	_ = os.Setenv("STOMP_PORT", p)
+	return s
 }
 
 //
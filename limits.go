@@ -0,0 +1,168 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"bufio"
+	"fmt"
+)
+
+/*
+	ReadLimits bounds the size of frames received from the broker, so that a
+	faulty broker can not make the client allocate without limit.  A zero
+	field means no limit.
+
+	A frame over a limit is reported as a *FrameTooLargeError, which wraps
+	ErrFrameTooLarge, and the connection is shut down.
+*/
+type ReadLimits struct {
+	MaxCommand    int   // Command line length, excluding the line end
+	MaxHeaders    int   // Number of headers
+	MaxHeaderLine int   // Header line length, excluding the line end
+	MaxBody       int64 // Body length
+}
+
+/*
+	DefaultReadLimits are the limits of a new Connection.  The body size is
+	not limited by default.
+*/
+var DefaultReadLimits = ReadLimits{
+	MaxCommand:    1024,
+	MaxHeaders:    1000,
+	MaxHeaderLine: 1024 * 1024,
+}
+
+/*
+	Names of the limits, for FrameTooLargeError.
+*/
+const (
+	LimitCommand    = "command"
+	LimitHeaders    = "headers"
+	LimitHeaderLine = "header line"
+	LimitBody       = "body"
+	LimitFrame      = "frame" // A whole CONNECT response, see ReadLimits.check
+)
+
+/*
+	FrameTooLargeError reports a received frame that exceeds one of the
+	ReadLimits.
+*/
+type FrameTooLargeError struct {
+	Limit string // The limit exceeded, one of the Limit constants
+	Max   int64  // The limit value
+}
+
+/*
+	Error returns a string for a FrameTooLargeError.
+*/
+func (e *FrameTooLargeError) Error() string {
+	return fmt.Sprintf("%v: %s exceeds %d", ErrFrameTooLarge, e.Limit, e.Max)
+}
+
+/*
+	Unwrap returns ErrFrameTooLarge, for use with errors.Is.
+*/
+func (e *FrameTooLargeError) Unwrap() error {
+	return ErrFrameTooLarge
+}
+
+/*
+	The current read limits.
+*/
+func (c *Connection) readLimits() ReadLimits {
+	if l := c.rlim.Load(); l != nil {
+		return *l
+	}
+	return ReadLimits{}
+}
+
+/*
+	Check the parts of a whole frame against the limits.
+*/
+func (l ReadLimits) check(f *Frame) error {
+	if l.MaxCommand > 0 && len(f.Command) > l.MaxCommand {
+		return &FrameTooLargeError{LimitCommand, int64(l.MaxCommand)}
+	}
+	if l.MaxHeaders > 0 && len(f.Headers)/2 > l.MaxHeaders {
+		return &FrameTooLargeError{LimitHeaders, int64(l.MaxHeaders)}
+	}
+	if l.MaxHeaderLine > 0 {
+		for i := 0; i+1 < len(f.Headers); i += 2 {
+			if len(f.Headers[i])+1+len(f.Headers[i+1]) > l.MaxHeaderLine {
+				return &FrameTooLargeError{LimitHeaderLine, int64(l.MaxHeaderLine)}
+			}
+		}
+	}
+	if l.MaxBody > 0 && int64(len(f.Body)) > l.MaxBody {
+		return &FrameTooLargeError{LimitBody, l.MaxBody}
+	}
+	return nil
+}
+
+/*
+	The most data a whole CONNECT response can hold within the limits, with
+	the body counted as a header line if it is not limited.  Zero if the
+	frame is not limited.
+*/
+func (l ReadLimits) connectMax() int64 {
+	if l.MaxCommand <= 0 || l.MaxHeaders <= 0 || l.MaxHeaderLine <= 0 {
+		return 0
+	}
+	b := l.MaxBody
+	if b <= 0 {
+		b = int64(l.MaxHeaderLine)
+	}
+	return int64(l.MaxCommand) + 2 + int64(l.MaxHeaders)*(int64(l.MaxHeaderLine)+2) + 2 +
+		b + 1
+}
+
+/*
+	Read through the next delim, returning at most max bytes including the
+	delimiter, or no limit if max <= 0.  The bool result is true if the limit
+	was exceeded.
+
+	Data that fits in the reader's buffer is returned without a copy, and is
+	only valid until the next read.
+*/
+func readDelim(r *bufio.Reader, delim byte, max int64) ([]byte, bool, error) {
+	s, e := r.ReadSlice(delim)
+	if max > 0 && int64(len(s)) > max {
+		return nil, true, nil
+	}
+	if e != bufio.ErrBufferFull {
+		return s, false, e
+	}
+	b := append([]byte(nil), s...)
+	for e == bufio.ErrBufferFull {
+		s, e = r.ReadSlice(delim)
+		if max > 0 && int64(len(b)+len(s)) > max {
+			return nil, true, nil
+		}
+		b = append(b, s...)
+	}
+	return b, false, e
+}
+
+/*
+	A limit as a readDelim maximum, allowing for a CR LF line end.
+*/
+func lineMax(n int) int64 {
+	if n <= 0 {
+		return 0
+	}
+	return int64(n) + 2
+}
@@ -0,0 +1,122 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+/*
+	Test each read limit.  A frame over a limit is reported on the
+	subscription, and the connection is shut down.
+*/
+func TestLimitsExceeded(t *testing.T) {
+	for _, ld := range limitsData {
+		s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+			Expect(SUBSCRIBE).Write(ld.frame).WaitClose()
+		n, rc := s.Pipe()
+		c, e := Connect(n, ctxHeaders)
+		if e != nil {
+			t.Fatalf("TestLimitsExceeded Expected [nil], got [%v]\n", e)
+		}
+		c.SetReadLimits(ld.rl)
+		sc, e := c.Subscribe(Headers{HK_DESTINATION, "/queue/limit", HK_ID, "limit-sub"})
+		if e != nil {
+			t.Fatalf("TestLimitsExceeded Expected [nil], got [%v]\n", e)
+		}
+		select {
+		case md := <-sc:
+			var fe *FrameTooLargeError
+			if !errors.As(md.Error, &fe) || fe.Limit != ld.limit {
+				t.Fatalf("TestLimitsExceeded Expected [%v], got [%v]\n", ld.limit,
+					md.Error)
+			}
+			if !errors.Is(md.Error, ErrFrameTooLarge) || !errors.Is(md.Error, ECONBAD) {
+				t.Fatalf("TestLimitsExceeded Expected [%v] and [%v], got [%v]\n",
+					ErrFrameTooLarge, ECONBAD, md.Error)
+			}
+		case <-time.After(limitWait):
+			t.Fatalf("TestLimitsExceeded Expected an error, got none\n")
+		}
+		if c.Connected() {
+			t.Fatalf("TestLimitsExceeded Expected a shut down connection\n")
+		}
+		_ = n.Close()
+		if se := <-rc; se != nil {
+			t.Fatalf("TestLimitsExceeded Script error [%v]\n", se)
+		}
+	}
+}
+
+/*
+	Test that a frame exactly at every limit is accepted.
+*/
+func TestLimitsBoundary(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Expect(SUBSCRIBE).Write(limitOKMessage).WaitClose()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestLimitsBoundary Expected [nil], got [%v]\n", e)
+	}
+	c.SetReadLimits(limitOK)
+	sc, e := c.Subscribe(Headers{HK_DESTINATION, "/queue/limit", HK_ID, "limit-sub"})
+	if e != nil {
+		t.Fatalf("TestLimitsBoundary Expected [nil], got [%v]\n", e)
+	}
+	select {
+	case md := <-sc:
+		if md.Error != nil {
+			t.Fatalf("TestLimitsBoundary Expected [nil], got [%v]\n", md.Error)
+		}
+		if b := md.Message.BodyString(); b != "body" {
+			t.Fatalf("TestLimitsBoundary Expected [body], got [%v]\n", b)
+		}
+	case <-time.After(limitWait):
+		t.Fatalf("TestLimitsBoundary Expected a message, got none\n")
+	}
+	_ = n.Close()
+	<-rc
+}
+
+/*
+	Test the default limits on the CONNECT response.
+*/
+func TestLimitsConnectResponse(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("CONNECTED\nversion:1.2\n")
+	for i := 0; i <= DefaultReadLimits.MaxHeaders; i++ {
+		b.WriteString("h" + strconv.Itoa(i) + ":v\n")
+	}
+	b.WriteString("\n\x00")
+	s := stomptest.NewScript().Expect(CONNECT).Write(b.String()).WaitClose()
+	n, rc := s.Pipe()
+	_, e := Connect(n, ctxHeaders)
+	var fe *FrameTooLargeError
+	if !errors.As(e, &fe) || fe.Limit != LimitHeaders {
+		t.Fatalf("TestLimitsConnectResponse Expected [%v], got [%v]\n",
+			LimitHeaders, e)
+	}
+	_ = n.Close()
+	<-rc
+}
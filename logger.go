@@ -0,0 +1,159 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+/*
+	Logging attribute keys.
+*/
+const (
+	LK_SESSION      = "session"
+	LK_SUBSCRIPTION = "subscription"
+	LK_COMMAND      = "command"
+	LK_HEADERS      = "headers"
+	LK_DIRECTION    = "direction"
+	LK_BODYLEN      = "body_len"
+	LK_DATA         = "data"
+	LK_ERROR        = "error"
+)
+
+/*
+	Value logged in place of a redacted header value.
+*/
+const redacted = "********"
+
+/*
+	SetLogger sets the logger used for connection events.  A nil logger
+	disables logging, which is the default.
+
+	Routine events are logged at slog.LevelDebug.  Dropped messages and
+	short reads are logged at slog.LevelWarn, and read errors at
+	slog.LevelError.  Every record carries the broker assigned session id.
+
+	To log the CONNECT handshake, use the DialLogger option.
+*/
+func (c *Connection) SetLogger(l *slog.Logger) {
+	c.lgr.Store(l)
+}
+
+/*
+	Logger returns the current connection logger, possibly nil.
+*/
+func (c *Connection) Logger() *slog.Logger {
+	return c.lgr.Load()
+}
+
+/*
+	SetWireTrace enables or disables a frame level trace of all frames sent and
+	received, heartbeats excepted.  Trace records are logged at
+	slog.LevelDebug, and require a logger.  Frame bodies are never logged, and
+	the value of any passcode header is redacted.
+*/
+func (c *Connection) SetWireTrace(on bool) {
+	c.wtrc.Store(on)
+}
+
+/*
+	Log data if possible.
+*/
+func (c *Connection) log(v ...interface{}) {
+	l := c.lgr.Load()
+	if l == nil || len(v) == 0 ||
+		!l.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	if len(v) == 1 {
+		c.logl(slog.LevelDebug, fmt.Sprint(v[0]))
+		return
+	}
+	c.logl(slog.LevelDebug, fmt.Sprint(v[0]), LK_DATA, strings.TrimSuffix(fmt.Sprintln(v[1:]...), "\n"))
+}
+
+/*
+	Log data if possible (extended / abbreviated) logic).  This is the wire
+	trace, for a single frame going in direction dir.
+*/
+func (c *Connection) logx(dir string, f *Frame) {
+	if !c.wtrc.Load() {
+		return
+	}
+	c.logl(slog.LevelDebug, "WIRE", LK_DIRECTION, dir, LK_COMMAND, f.Command,
+		LK_HEADERS, redactHeaders(f.Headers), LK_BODYLEN, len(f.Body))
+}
+
+/*
+	Log a client command event, with the command's headers.
+*/
+func (c *Connection) logcmd(cmd, ev string, h Headers) {
+	args := []any{LK_COMMAND, cmd}
+	if sid := subscriptionId(cmd, h); sid != "" {
+		args = append(args, LK_SUBSCRIPTION, sid)
+	}
+	c.logl(slog.LevelDebug, cmd+" "+ev, append(args, LK_HEADERS, redactHeaders(h))...)
+}
+
+/*
+	Log a structured record at a given level, if possible.
+*/
+func (c *Connection) logl(lv slog.Level, msg string, args ...any) {
+	l := c.lgr.Load()
+	if l == nil || !l.Enabled(context.Background(), lv) {
+		return
+	}
+	c.sessLock.Lock()
+	s := c.session
+	c.sessLock.Unlock()
+	l.Log(context.Background(), lv, msg, append([]any{LK_SESSION, s}, args...)...)
+}
+
+/*
+	The subscription id, if any, carried by the headers of a client command.
+*/
+func subscriptionId(cmd string, h Headers) string {
+	switch cmd {
+	case SUBSCRIBE, UNSUBSCRIBE:
+		if sid, ok := h.Contains(HK_ID); ok {
+			return sid
+		}
+		return h.Value(HK_DESTINATION)
+	case ACK, NACK:
+		return h.Value(HK_SUBSCRIPTION)
+	}
+	return ""
+}
+
+/*
+	A copy of headers with any passcode value redacted.
+*/
+func redactHeaders(h Headers) Headers {
+	if _, ok := h.Contains(HK_PASSCODE); !ok {
+		return h
+	}
+	r := h.Clone()
+	for i := 0; i < len(r); i += 2 {
+		if r[i] == HK_PASSCODE {
+			r[i+1] = redacted
+		}
+	}
+	return r
+}
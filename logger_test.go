@@ -0,0 +1,80 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+	Test the wire trace, from the CONNECT frame on, with passcode redaction.
+*/
+func TestLoggerWireTrace(t *testing.T) {
+	hap := listenBroker(t, nil, func(pb *pipe_broker) {
+		_, _ = pb.readFrame() // CONNECT
+		_, _ = pb.n.Write([]byte(ctxConnected))
+		_, _ = pb.readFrame() // SEND
+		_, _ = pb.readFrame() // DISCONNECT
+	})
+	lb := &log_buffer{}
+	l := slog.New(slog.NewTextHandler(lb, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ctx, cf := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cf()
+	c, e := Dial(ctx, "stomp://"+hap, lgHeaders, DialLogger(l), DialWireTrace(true))
+	if e != nil {
+		t.Fatalf("TestLoggerWireTrace Expected [nil], got [%v]\n", e)
+	}
+	e = c.Send(Headers{HK_DESTINATION, "/queue/logger.trace"}, tm)
+	if e != nil {
+		t.Fatalf("TestLoggerWireTrace Expected [nil], got [%v]\n", e)
+	}
+	_ = c.Disconnect(NoDiscReceipt)
+	for _, s := range []string{"direction=send command=CONNECT",
+		"direction=recv command=CONNECTED", "direction=send command=SEND",
+		"session=ctx-test", "passcode:" + redacted} {
+		if !lb.waitFor(s) {
+			t.Fatalf("TestLoggerWireTrace Expected [%s], got [%s]\n", s, lb.String())
+		}
+	}
+	if strings.Contains(lb.String(), "lgsecret") {
+		t.Fatalf("TestLoggerWireTrace Expected redacted passcode, got [%s]\n",
+			lb.String())
+	}
+}
+
+/*
+	Test a MESSAGE for an unknown subscription is logged at WARN level.
+*/
+func TestLoggerNoSub(t *testing.T) {
+	c, pb := pipeConnect(t, ctxHeaders, ctxConnected)
+	defer pb.n.Close()
+	lb := &log_buffer{}
+	c.SetLogger(slog.New(slog.NewTextHandler(lb, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	_, _ = pb.n.Write([]byte("MESSAGE\nsubscription:lg-nosub\nmessage-id:1\n" +
+		"destination:/queue/logger.nosub\n\nlost\x00"))
+	if !lb.waitFor("level=WARN msg=RDR_NOSUB session=ctx-test subscription=lg-nosub") {
+		t.Fatalf("TestLoggerNoSub Expected RDR_NOSUB, got [%s]\n", lb.String())
+	}
+	if strings.Contains(lb.String(), "level=DEBUG") {
+		t.Fatalf("TestLoggerNoSub Expected no DEBUG records, got [%s]\n",
+			lb.String())
+	}
+}
@@ -0,0 +1,72 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+/*
+	Package metrics exports stompngo connection metrics to Prometheus, and to
+	an OpenTelemetry meter.
+
+	Each connection is added under a name, which becomes the connection label
+	or attribute.  Counts are taken from Connection.Stats when metrics are
+	collected:
+
+		frames and bytes, by direction (in, out) and STOMP command
+		heart beat failures, by direction (send, receive)
+		subscription MessageData queue depth and capacity
+
+	Read and write latency histograms are fed by a stompngo.LatencyObserver,
+	set with the stompngo.DialLatencyObserver option, using the timing
+	points described for STOMP_TRACKELT.
+
+	Example:
+		col := metrics.NewCollector("")
+		prometheus.MustRegister(col)
+		c, e := stompngo.Dial(ctx, "stomp://localhost:61613", h,
+			stompngo.DialLatencyObserver(col.Observer("orders")))
+		if e != nil {
+			// Do something sane ...
+		}
+		col.Add("orders", c)
+		defer col.Remove("orders")
+*/
+package metrics
+
+import (
+	"github.com/photostorm/stompngo"
+)
+
+/*
+	Source is a connection whose metrics are exported.  *stompngo.Connection
+	is a Source.
+*/
+type Source interface {
+	Stats() stompngo.Stats
+}
+
+/*
+	Label and attribute values.
+*/
+const (
+	dirIn   = "in"
+	dirOut  = "out"
+	dirSend = "send"
+	dirRecv = "receive"
+)
+
+/*
+	Latency histogram bucket bounds, in seconds.
+*/
+var latencyBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001,
+	0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
@@ -0,0 +1,178 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package metrics_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo"
+	"github.com/photostorm/stompngo/metrics"
+	"github.com/photostorm/stompngo/stomptest"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+/*
+	Dial a test broker, with o observing latencies, and exchange one
+	message, which is left unreceived.
+*/
+func exercise(t *testing.T, o stompngo.LatencyObserver) (*stompngo.Connection, func()) {
+	s := stomptest.NewServer()
+	h := stompngo.Headers{stompngo.HK_ACCEPT_VERSION, stompngo.SPL_12,
+		stompngo.HK_HOST, "localhost"}
+	c, e := stompngo.Dial(context.Background(), "tcp://"+s.Addr, h,
+		stompngo.DialLatencyObserver(o))
+	if e != nil {
+		t.Fatalf("exercise Dial Expected [nil], got [%v]\n", e)
+	}
+	d := stompngo.Headers{stompngo.HK_DESTINATION, "/queue/metrics"}
+	if _, e = c.Subscribe(d.Add(stompngo.HK_ID, "metrics")); e != nil {
+		t.Fatalf("exercise Subscribe Expected [nil], got [%v]\n", e)
+	}
+	if e = c.SendWithReceipt(context.Background(), d, "metrics"); e != nil {
+		t.Fatalf("exercise Send Expected [nil], got [%v]\n", e)
+	}
+	for c.Stats().Subscriptions["metrics"].Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	return c, func() {
+		_ = c.Disconnect(stompngo.Headers{})
+		_ = s.Close()
+	}
+}
+
+/*
+	Find a Prometheus metric with the given label values.
+*/
+func promValue(mfs []*dto.MetricFamily, name string, labels ...string) (*dto.Metric, bool) {
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+	metricLoop:
+		for _, m := range mf.GetMetric() {
+			for i := 0; i+1 < len(labels); i += 2 {
+				found := false
+				for _, lp := range m.GetLabel() {
+					if lp.GetName() == labels[i] && lp.GetValue() == labels[i+1] {
+						found = true
+					}
+				}
+				if !found {
+					continue metricLoop
+				}
+			}
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+/*
+	Test the Prometheus Collector.
+*/
+func TestPrometheusCollector(t *testing.T) {
+	col := metrics.NewCollector("")
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(col)
+	c, done := exercise(t, col.Observer("test"))
+	defer done()
+	col.Add("test", c)
+	mfs, e := reg.Gather()
+	if e != nil {
+		t.Fatalf("TestPrometheusCollector Expected [nil], got [%v]\n", e)
+	}
+	m, ok := promValue(mfs, "stompngo_frames_total", "connection", "test",
+		"direction", "out", "command", stompngo.SEND)
+	if !ok || m.GetCounter().GetValue() != 1 {
+		t.Fatalf("TestPrometheusCollector Expected 1 SEND, got [%v]\n", m)
+	}
+	m, ok = promValue(mfs, "stompngo_subscription_queue_depth", "subscription",
+		"metrics")
+	if !ok || m.GetGauge().GetValue() != 1 {
+		t.Fatalf("TestPrometheusCollector Expected depth 1, got [%v]\n", m)
+	}
+	m, ok = promValue(mfs, "stompngo_latency_seconds", "point", "WOV")
+	if !ok || m.GetHistogram().GetSampleCount() == 0 {
+		t.Fatalf("TestPrometheusCollector Expected WOV latencies, got [%v]\n", m)
+	}
+	//
+	col.Remove("test")
+	if mfs, _ = reg.Gather(); len(mfs) != 0 {
+		t.Fatalf("TestPrometheusCollector Expected no metrics, got [%v]\n", mfs)
+	}
+}
+
+/*
+	Test the OpenTelemetry instruments.
+*/
+func TestOTel(t *testing.T) {
+	rdr := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(rdr))
+	defer func() { _ = mp.Shutdown(context.Background()) }()
+	o, e := metrics.NewOTel(mp.Meter("stompngo_test"))
+	if e != nil {
+		t.Fatalf("TestOTel Expected [nil], got [%v]\n", e)
+	}
+	c, done := exercise(t, o.Observer("test"))
+	defer done()
+	reg, e := o.Add("test", c)
+	if e != nil {
+		t.Fatalf("TestOTel Expected [nil], got [%v]\n", e)
+	}
+	defer func() { _ = reg.Unregister() }()
+	var rm metricdata.ResourceMetrics
+	if e = rdr.Collect(context.Background(), &rm); e != nil {
+		t.Fatalf("TestOTel Expected [nil], got [%v]\n", e)
+	}
+	found := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch d := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range d.DataPoints {
+					if v, _ := dp.Attributes.Value("command"); v.AsString() ==
+						stompngo.SEND && m.Name == "stompngo.frames" && dp.Value == 1 {
+						found[m.Name] = true
+					}
+				}
+			case metricdata.Gauge[int64]:
+				for _, dp := range d.DataPoints {
+					if m.Name == "stompngo.subscription.queue.depth" && dp.Value == 1 {
+						found[m.Name] = true
+					}
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range d.DataPoints {
+					if dp.Count > 0 {
+						found[m.Name] = true
+					}
+				}
+			}
+		}
+	}
+	for _, n := range []string{"stompngo.frames", "stompngo.subscription.queue.depth",
+		"stompngo.latency"} {
+		if !found[n] {
+			t.Fatalf("TestOTel Expected [%s], got [%v]\n", n, rm.ScopeMetrics)
+		}
+	}
+}
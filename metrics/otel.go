@@ -0,0 +1,127 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/photostorm/stompngo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+/*
+	OTel holds OpenTelemetry instruments for any number of named
+	connections.
+*/
+type OTel struct {
+	frames metric.Int64ObservableCounter
+	bytes  metric.Int64ObservableCounter
+	hbf    metric.Int64ObservableCounter
+	depth  metric.Int64ObservableGauge
+	scap   metric.Int64ObservableGauge
+	lat    metric.Float64Histogram
+	m      metric.Meter
+}
+
+/*
+	NewOTel creates the stompngo instruments on m.
+*/
+func NewOTel(m metric.Meter) (*OTel, error) {
+	o := &OTel{m: m}
+	var e error
+	if o.frames, e = m.Int64ObservableCounter("stompngo.frames",
+		metric.WithDescription("STOMP frames read and written."),
+		metric.WithUnit("{frame}")); e != nil {
+		return nil, e
+	}
+	if o.bytes, e = m.Int64ObservableCounter("stompngo.bytes",
+		metric.WithDescription("STOMP frame bytes read and written."),
+		metric.WithUnit("By")); e != nil {
+		return nil, e
+	}
+	if o.hbf, e = m.Int64ObservableCounter("stompngo.heartbeat.failures",
+		metric.WithDescription("Heart beat send failures and failed receive checks."),
+		metric.WithUnit("{failure}")); e != nil {
+		return nil, e
+	}
+	if o.depth, e = m.Int64ObservableGauge("stompngo.subscription.queue.depth",
+		metric.WithDescription("MessageData values waiting to be received."),
+		metric.WithUnit("{message}")); e != nil {
+		return nil, e
+	}
+	if o.scap, e = m.Int64ObservableGauge("stompngo.subscription.queue.capacity",
+		metric.WithDescription("Subscription MessageData channel capacity."),
+		metric.WithUnit("{message}")); e != nil {
+		return nil, e
+	}
+	if o.lat, e = m.Float64Histogram("stompngo.latency",
+		metric.WithDescription("Reader and writer elapsed times, by timing point."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(latencyBuckets...)); e != nil {
+		return nil, e
+	}
+	return o, nil
+}
+
+/*
+	Add starts observing s, with connection attribute name.  Unregister the
+	returned Registration when the connection is no longer used.
+*/
+func (o *OTel) Add(name string, s Source) (metric.Registration, error) {
+	return o.m.RegisterCallback(func(_ context.Context, ob metric.Observer) error {
+		st := s.Stats()
+		observeCommands(ob, o, name, dirIn, st.Read)
+		observeCommands(ob, o, name, dirOut, st.Written)
+		ob.ObserveInt64(o.hbf, st.HeartBeatSendFails, metric.WithAttributes(
+			attribute.String("connection", name),
+			attribute.String("direction", dirSend)))
+		ob.ObserveInt64(o.hbf, st.HeartBeatReadFails, metric.WithAttributes(
+			attribute.String("connection", name),
+			attribute.String("direction", dirRecv)))
+		for id, ss := range st.Subscriptions {
+			as := metric.WithAttributes(attribute.String("connection", name),
+				attribute.String("subscription", id))
+			ob.ObserveInt64(o.depth, int64(ss.Queued), as)
+			ob.ObserveInt64(o.scap, int64(ss.Capacity), as)
+		}
+		return nil
+	}, o.frames, o.bytes, o.hbf, o.depth, o.scap)
+}
+
+/*
+	Observer returns a LatencyObserver that records to the latency histogram
+	with connection attribute name.
+*/
+func (o *OTel) Observer(name string) stompngo.LatencyObserver {
+	return func(point string, d time.Duration) {
+		o.lat.Record(context.Background(), d.Seconds(), metric.WithAttributes(
+			attribute.String("connection", name),
+			attribute.String("point", point)))
+	}
+}
+
+func observeCommands(ob metric.Observer, o *OTel, n, dir string,
+	cs map[string]stompngo.CommandStats) {
+	for cmd, v := range cs {
+		as := metric.WithAttributes(attribute.String("connection", n),
+			attribute.String("direction", dir), attribute.String("command", cmd))
+		ob.ObserveInt64(o.frames, v.Frames, as)
+		ob.ObserveInt64(o.bytes, v.Bytes, as)
+	}
+}
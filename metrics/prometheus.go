@@ -0,0 +1,157 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/photostorm/stompngo"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/*
+	Collector is a prometheus.Collector for any number of named connections.
+*/
+type Collector struct {
+	mu    sync.Mutex
+	conns map[string]Source
+	//
+	frames *prometheus.Desc
+	bytes  *prometheus.Desc
+	hbf    *prometheus.Desc
+	depth  *prometheus.Desc
+	scap   *prometheus.Desc
+	lat    *prometheus.HistogramVec
+}
+
+/*
+	NewCollector returns an empty Collector.  Metric names are prefixed with
+	namespace, "stompngo" if namespace is empty.
+*/
+func NewCollector(namespace string) *Collector {
+	if namespace == "" {
+		namespace = "stompngo"
+	}
+	fq := func(n string) string {
+		return prometheus.BuildFQName(namespace, "", n)
+	}
+	return &Collector{conns: make(map[string]Source),
+		frames: prometheus.NewDesc(fq("frames_total"),
+			"STOMP frames read and written.",
+			[]string{"connection", "direction", "command"}, nil),
+		bytes: prometheus.NewDesc(fq("bytes_total"),
+			"STOMP frame bytes read and written.",
+			[]string{"connection", "direction", "command"}, nil),
+		hbf: prometheus.NewDesc(fq("heartbeat_failures_total"),
+			"Heart beat send failures and failed receive checks.",
+			[]string{"connection", "direction"}, nil),
+		depth: prometheus.NewDesc(fq("subscription_queue_depth"),
+			"MessageData values waiting to be received.",
+			[]string{"connection", "subscription"}, nil),
+		scap: prometheus.NewDesc(fq("subscription_queue_capacity"),
+			"Subscription MessageData channel capacity.",
+			[]string{"connection", "subscription"}, nil),
+		lat: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "latency_seconds",
+			Help:      "Reader and writer elapsed times, by timing point.",
+			Buckets:   latencyBuckets,
+		}, []string{"connection", "point"}),
+	}
+}
+
+/*
+	Add starts collecting metrics for s, with connection label name.  A
+	Source already added under name is replaced.
+*/
+func (c *Collector) Add(name string, s Source) {
+	c.mu.Lock()
+	c.conns[name] = s
+	c.mu.Unlock()
+}
+
+/*
+	Remove stops collecting metrics for the connection added under name, and
+	drops its latency histograms.
+*/
+func (c *Collector) Remove(name string) {
+	c.mu.Lock()
+	delete(c.conns, name)
+	c.mu.Unlock()
+	c.lat.DeletePartialMatch(prometheus.Labels{"connection": name})
+}
+
+/*
+	Observer returns a LatencyObserver that feeds the latency histograms for
+	connection label name.
+*/
+func (c *Collector) Observer(name string) stompngo.LatencyObserver {
+	return func(point string, d time.Duration) {
+		c.lat.WithLabelValues(name, point).Observe(d.Seconds())
+	}
+}
+
+/*
+	Describe implements prometheus.Collector.
+*/
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.frames
+	ch <- c.bytes
+	ch <- c.hbf
+	ch <- c.depth
+	ch <- c.scap
+	c.lat.Describe(ch)
+}
+
+/*
+	Collect implements prometheus.Collector.
+*/
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	conns := make(map[string]Source, len(c.conns))
+	for n, s := range c.conns {
+		conns[n] = s
+	}
+	c.mu.Unlock()
+	for n, s := range conns {
+		st := s.Stats()
+		c.collectCommands(ch, n, dirIn, st.Read)
+		c.collectCommands(ch, n, dirOut, st.Written)
+		ch <- prometheus.MustNewConstMetric(c.hbf, prometheus.CounterValue,
+			float64(st.HeartBeatSendFails), n, dirSend)
+		ch <- prometheus.MustNewConstMetric(c.hbf, prometheus.CounterValue,
+			float64(st.HeartBeatReadFails), n, dirRecv)
+		for id, ss := range st.Subscriptions {
+			ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue,
+				float64(ss.Queued), n, id)
+			ch <- prometheus.MustNewConstMetric(c.scap, prometheus.GaugeValue,
+				float64(ss.Capacity), n, id)
+		}
+	}
+	c.lat.Collect(ch)
+}
+
+func (c *Collector) collectCommands(ch chan<- prometheus.Metric, n, dir string,
+	cs map[string]stompngo.CommandStats) {
+	for cmd, v := range cs {
+		ch <- prometheus.MustNewConstMetric(c.frames, prometheus.CounterValue,
+			float64(v.Frames), n, dir, cmd)
+		ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.CounterValue,
+			float64(v.Bytes), n, dir, cmd)
+	}
+}
@@ -17,6 +17,7 @@
 package stompngo
 
 import (
+	"context"
 	"fmt"
 )
 
@@ -47,7 +48,22 @@ var _ = fmt.Println
 
 */
 func (c *Connection) Nack(h Headers) error {
-	c.log(NACK, "start", h, c.Protocol())
+	return c.NackContext(context.Background(), h)
+}
+
+/*
+	NackContext nacks a STOMP 1.1+ message, honoring ctx cancellation and
+	deadlines.  See Nack for details.
+*/
+func (c *Connection) NackContext(ctx context.Context, h Headers) error {
+	return c.nack(ctx, h, false)
+}
+
+/*
+	Nack, waiting for the broker's RECEIPT if rcpt is set.
+*/
+func (c *Connection) nack(ctx context.Context, h Headers, rcpt bool) error {
+	c.logcmd(NACK, "start", h)
 	if !c.isConnected() {
 		return ECONBAD
 	}
@@ -73,7 +89,18 @@ func (c *Connection) Nack(h Headers) error {
 		}
 	}
 
-	e = c.transmitCommon(NACK, h) // transmitCommon Clones() the headers
-	c.log(NACK, "end", h, c.Protocol())
+	e = c.transmitCommon(ctx, NACK, h, rcpt) // transmitCommon Clones() the headers
+	if e == nil {
+		c.settleAck(h)
+	}
+	c.logcmd(NACK, "end", h)
 	return e
 }
+
+/*
+	NackWithReceipt nacks a STOMP 1.1+ message, and waits for the broker's
+	RECEIPT.  See SendWithReceipt and Nack for details.
+*/
+func (c *Connection) NackWithReceipt(ctx context.Context, h Headers) error {
+	return c.nack(ctx, h, true)
+}
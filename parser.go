@@ -0,0 +1,222 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+	Frame parser read kinds, passed to readHooks.
+*/
+const (
+	parseCommand = iota // A command line, or a heart beat
+	parseHeader         // A header line
+	parseNul            // A body read through the NUL
+	parseBody           // A body with a content-length, and the NUL
+)
+
+/*
+	Hooks called around each read made by a frameParser.
+*/
+type readHooks interface {
+	readStart() int64                 // Before a read, returns a start time
+	readEnd(k int, st int64, e error) // After a read of kind k
+	Protocol() string                 // For header checks, used over proto
+}
+
+/*
+	The STOMP frame parser, shared by Connection and FrameReader.  A CR before
+	a line end is ignored, and every body must be followed by a NUL.
+*/
+type frameParser struct {
+	r      *bufio.Reader
+	lim    ReadLimits
+	valid  map[string]bool // Commands accepted, or nil for all
+	proto  string          // For header checks, "" before CONNECT
+	hooks  readHooks       // Or nil
+	stream bool            // Leave MESSAGE bodies unread, see rem
+	rem    int64           // Length of the body left unread, or 0
+}
+
+/*
+	Read the next frame.  A heart beat is returned as a Frame with an empty
+	Command.  Any other command not accepted returns a ProtocolError wrapping
+	EINVBCMD.
+*/
+func (p *frameParser) readFrame() (Frame, error) {
+	f := Frame{"", Headers{}, NULLBUFF}
+	p.rem = 0
+	bx, e := p.readLine(parseCommand, p.lim.MaxCommand, LimitCommand)
+	if e != nil {
+		return f, e
+	}
+	if len(bx) == 0 { // A heartbeat
+		return f, nil
+	}
+	c, ok := stompCmds[string(bx)]
+	if !ok || (p.valid != nil && !p.valid[c]) {
+		f.Command = string(bx)
+		return f, &ProtocolError{EINVBCMD, []byte(f.Command)}
+	}
+	f.Command = c
+	if f.Headers, e = p.readHeaders(); e != nil {
+		return f, e
+	}
+	proto := p.proto
+	if p.hooks != nil {
+		proto = p.hooks.Protocol()
+	}
+	if e = checkHeaders(f.Headers, proto); e != nil {
+		return f, e
+	}
+	f.Body, e = p.readBody(f)
+	return f, e
+}
+
+/*
+	Read frame headers through the blank line.  The Headers returned are
+	allocated once, at their final size, and common keys and values are not
+	allocated at all.
+*/
+func (p *frameParser) readHeaders() (Headers, error) {
+	hs := headersPool.Get().(*headerScratch)
+	defer hs.release()
+	for {
+		bx, e := p.readLine(parseHeader, p.lim.MaxHeaderLine, LimitHeaderLine)
+		if e != nil {
+			return hs.headers(), e
+		}
+		if len(bx) == 0 {
+			return hs.headers(), nil
+		}
+		if p.lim.MaxHeaders > 0 && len(hs.h)/2 >= p.lim.MaxHeaders {
+			return hs.headers(), &FrameTooLargeError{LimitHeaders,
+				int64(p.lim.MaxHeaders)}
+		}
+		i := bytes.IndexByte(bx, ':')
+		if i < 0 {
+			return hs.headers(), &ProtocolError{EUNKHDR, bytes.Clone(bx)}
+		}
+		// Always decode regardless of protocol level. See issue #47.
+		hs.add(bx[:i])
+		hs.add(bx[i+1:])
+	}
+}
+
+/*
+	Read a frame body and its trailing NUL.  Without a content-length, or
+	with a content-length of 0, the body is read through the NUL.
+*/
+func (p *frameParser) readBody(f Frame) ([]uint8, error) {
+	v, ok := f.Headers.Contains(HK_CONTENT_LENGTH)
+	if !ok {
+		return p.readUntilNul()
+	}
+	l, e := strconv.Atoi(strings.TrimSpace(v))
+	if e != nil || l < 0 {
+		return nil, &ProtocolError{EBADCLEN, []byte(v)}
+	}
+	if p.lim.MaxBody > 0 && int64(l) > p.lim.MaxBody {
+		return nil, &FrameTooLargeError{LimitBody, p.lim.MaxBody}
+	}
+	if l == 0 {
+		return p.readUntilNul()
+	}
+	if p.stream && f.Command == MESSAGE {
+		p.rem = int64(l) // The body is read by the consumer
+		return NULLBUFF, nil
+	}
+	b := make([]uint8, l)
+	st := p.start()
+	_, e = io.ReadFull(p.r, b)
+	var n byte
+	if e == nil {
+		n, e = p.r.ReadByte()
+	}
+	p.end(parseBody, st, e)
+	if e != nil {
+		return nil, e
+	}
+	if n != 0 {
+		return nil, &ProtocolError{EBADFRM, []byte{n}}
+	}
+	return b, nil
+}
+
+/*
+	Read a body through the NUL, accepting at most MaxBody bytes before it.
+*/
+func (p *frameParser) readUntilNul() ([]uint8, error) {
+	mx := p.lim.MaxBody
+	if mx > 0 {
+		mx++ // The NUL
+	}
+	st := p.start()
+	b, big, e := readDelim(p.r, 0, mx)
+	p.end(parseNul, st, e)
+	if big {
+		return nil, &FrameTooLargeError{LimitBody, p.lim.MaxBody}
+	}
+	if e != nil {
+		return nil, e
+	}
+	if len(b) == 1 {
+		return NULLBUFF, nil
+	}
+	return bytes.Clone(b[:len(b)-1]), nil
+}
+
+/*
+	Read one line, returning it without the line end.  The line is only valid
+	until the next read.
+*/
+func (p *frameParser) readLine(k int, mx int, lk string) ([]byte, error) {
+	st := p.start()
+	bx, big, e := readDelim(p.r, '\n', lineMax(mx))
+	p.end(k, st, e)
+	if big {
+		return nil, &FrameTooLargeError{lk, int64(mx)}
+	}
+	if e != nil {
+		return nil, e
+	}
+	return bytes.TrimSuffix(bx[:len(bx)-1], []byte{'\r'}), nil
+}
+
+/*
+	Call any readStart hook.
+*/
+func (p *frameParser) start() int64 {
+	if p.hooks == nil {
+		return 0
+	}
+	return p.hooks.readStart()
+}
+
+/*
+	Call any readEnd hook.
+*/
+func (p *frameParser) end(k int, st int64, e error) {
+	if p.hooks != nil {
+		p.hooks.readEnd(k, st, e)
+	}
+}
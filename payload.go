@@ -0,0 +1,175 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+/*
+	Codec marshals and unmarshals MESSAGE bodies for one content-type.  See
+	RegisterCodec.
+*/
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(b []byte, v any) error
+}
+
+/*
+	Registered codecs, by media type.
+*/
+var codecs = struct {
+	sync.RWMutex
+	m map[string]Codec
+}{m: map[string]Codec{CT_JSON: jsonCodec{}, CT_GOB: gobCodec{}}}
+
+type jsonCodec struct{}
+
+type gobCodec struct{}
+
+/*
+	RegisterCodec sets the Codec for a content-type, replacing any already
+	registered.  Parameters such as charset are ignored, and case does not
+	matter.  A nil Codec removes the registration.  Codecs for CT_JSON and
+	CT_GOB are built in.
+
+	Example:
+		stompngo.RegisterCodec("application/cbor", cborCodec{})
+*/
+func RegisterCodec(ct string, c Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	if c == nil {
+		delete(codecs.m, mediaType(ct))
+		return
+	}
+	codecs.m[mediaType(ct)] = c
+}
+
+/*
+	LookupCodec returns the Codec for a content-type, or a *CodecError.  An
+	empty content-type means CT_JSON.
+*/
+func LookupCodec(ct string) (Codec, error) {
+	mt := mediaType(ct)
+	if mt == "" {
+		mt = CT_JSON
+	}
+	codecs.RLock()
+	defer codecs.RUnlock()
+	c, ok := codecs.m[mt]
+	if !ok {
+		return nil, &CodecError{mt}
+	}
+	return c, nil
+}
+
+/*
+	SendTyped marshals v with the Codec for the content-type header, and
+	sends it as a STOMP MESSAGE.  If there is no content-type header, v is
+	sent as JSON, with a content-type of CT_JSON.  A content-type with no
+	registered Codec returns a *CodecError.  See SendBytes.
+
+	Example:
+		h := stompngo.Headers{stompngo.HK_DESTINATION, "/queue/orders"}
+		e := stompngo.SendTyped(c, h, Order{Id: 42, Qty: 7})
+		if e != nil {
+			// Do something sane ...
+		}
+*/
+func SendTyped[T any](c *Connection, h Headers, v T) error {
+	return SendTypedContext(context.Background(), c, h, v)
+}
+
+/*
+	SendTypedContext is SendTyped, honoring ctx cancellation and deadlines as
+	for SendBytesContext.
+*/
+func SendTypedContext[T any](ctx context.Context, c *Connection, h Headers, v T) error {
+	if h == nil {
+		return EHDRNIL
+	}
+	ct, ok := h.Contains(HK_CONTENT_TYPE)
+	if !ok {
+		ct = CT_JSON
+		h = h.Clone().Add(HK_CONTENT_TYPE, ct)
+	}
+	cd, e := LookupCodec(ct)
+	if e != nil {
+		return e
+	}
+	b, e := cd.Marshal(v)
+	if e != nil {
+		return e
+	}
+	return c.SendBytesContext(ctx, h, b)
+}
+
+/*
+	Decode unmarshals a MESSAGE body with the Codec for its content-type
+	header.  A MESSAGE with no content-type is decoded as JSON.  A
+	content-type with no registered Codec returns a *CodecError.  A streamed
+	body (see SetStreamBodies) must be read into Body first.
+
+	Example:
+		o, e := stompngo.Decode[Order](&md.Message)
+		if e != nil {
+			// Do something sane ...
+		}
+*/
+func Decode[T any](m *Message) (T, error) {
+	var v T
+	cd, e := LookupCodec(m.Headers.Value(HK_CONTENT_TYPE))
+	if e != nil {
+		return v, e
+	}
+	e = cd.Unmarshal(m.Body, &v)
+	return v, e
+}
+
+/*
+	The media type of a content-type, lower case, without parameters.
+*/
+func mediaType(ct string) string {
+	mt, _, _ := strings.Cut(ct, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(b []byte, v any) error {
+	return json.Unmarshal(b, v)
+}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var b bytes.Buffer
+	if e := gob.NewEncoder(&b).Encode(v); e != nil {
+		return nil, e
+	}
+	return b.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(b []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
@@ -0,0 +1,89 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+/*
+	Test SendTyped and Decode round trip with the built in codecs.
+*/
+func TestPayloadRoundTrip(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	c, e := Connect(b.Pipe(), ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestPayloadRoundTrip Expected [nil], got [%v]\n", e)
+	}
+	sc, e := c.Subscribe(Headers{HK_DESTINATION, payloadDest})
+	if e != nil {
+		t.Fatalf("TestPayloadRoundTrip Expected [nil], got [%v]\n", e)
+	}
+	for _, ct := range []string{"", CT_JSON + "; charset=UTF-8", CT_GOB} {
+		h := Headers{HK_DESTINATION, payloadDest}
+		if ct != "" {
+			h = h.Add(HK_CONTENT_TYPE, ct)
+		}
+		if e = SendTyped(c, h, payloadValue); e != nil {
+			t.Fatalf("TestPayloadRoundTrip[%s] Expected [nil], got [%v]\n", ct, e)
+		}
+		md := <-sc
+		if md.Error != nil {
+			t.Fatalf("TestPayloadRoundTrip[%s] Expected [nil], got [%v]\n", ct, md.Error)
+		}
+		if ct == "" {
+			ct = CT_JSON
+		}
+		if got := md.Message.Headers.Value(HK_CONTENT_TYPE); got != ct {
+			t.Fatalf("TestPayloadRoundTrip Expected [%s], got [%s]\n", ct, got)
+		}
+		v, e := Decode[payloadOrder](&md.Message)
+		if e != nil || v != payloadValue {
+			t.Fatalf("TestPayloadRoundTrip[%s] Expected [%v], got [%v] [%v]\n", ct,
+				payloadValue, v, e)
+		}
+	}
+	_ = c.Disconnect(empty_headers)
+}
+
+/*
+	Test a content-type with no Codec is a *CodecError, and RegisterCodec.
+*/
+func TestPayloadCodecError(t *testing.T) {
+	m := &Message{MESSAGE, Headers{HK_CONTENT_TYPE, DFLT_CONTENT_TYPE}, []byte("text")}
+	var ce *CodecError
+	if _, e := Decode[string](m); !errors.As(e, &ce) || ce.ContentType != "text/plain" {
+		t.Fatalf("TestPayloadCodecError Expected a CodecError, got [%v]\n", e)
+	}
+	e := SendTyped(nil, Headers{HK_DESTINATION, payloadDest,
+		HK_CONTENT_TYPE, "Text/Plain"}, "text")
+	if !errors.As(e, &ce) {
+		t.Fatalf("TestPayloadCodecError Expected a CodecError, got [%v]\n", e)
+	}
+	RegisterCodec("TEXT/PLAIN", jsonCodec{})
+	if _, e = LookupCodec(DFLT_CONTENT_TYPE); e != nil {
+		t.Fatalf("TestPayloadCodecError Expected [nil], got [%v]\n", e)
+	}
+	RegisterCodec("text/plain", nil)
+	if _, e = LookupCodec(DFLT_CONTENT_TYPE); !errors.As(e, &ce) {
+		t.Fatalf("TestPayloadCodecError Expected a CodecError, got [%v]\n", e)
+	}
+}
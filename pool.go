@@ -0,0 +1,420 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	PoolOption is a function that modifies Pool behavior.
+*/
+type PoolOption func(*poolConfig)
+
+/*
+	Pool configuration, built from PoolOptions.
+*/
+type poolConfig struct {
+	min  int           // Connections kept open
+	max  int           // Connections allowed under load
+	chk  time.Duration // Health check interval
+	evn  int           // Consecutive heart beat failures before eviction
+	evto time.Duration // DISCONNECT timeout for evicted connections
+}
+
+/*
+	Pool is a set of producer connections to one broker.
+
+	Each Connection writes through a single writer goroutine, so one
+	connection can limit a busy producer.  Pool spreads SEND frames over
+	between min and max connections, preferring the least busy.  When every
+	connection is busy another is dialed in the background, up to max.
+
+	A connection is evicted when its reader has exited, when a SEND on it
+	fails with ECONBAD, or when its heart beat send or receive check has
+	failed at several health checks in a row, see PoolEvictAfter.  Evicted
+	connections are disconnected, and replaced in the background so that at
+	least min remain.
+
+	A SEND that fails with ECONBAD wrote nothing, and is retried on another
+	connection, up to max tries in all.  Operations wait for a usable
+	connection, or for their context to be done.
+
+	Pool owns every Connection returned by its ConnectFunc, which should own
+	its network connection, as with Dial and DialConn.
+*/
+type Pool struct {
+	cf     ConnectFunc
+	cfg    poolConfig
+	ctx    context.Context // Canceled on Close
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	conns  []*poolConn   // Healthy connections, as of the latest check
+	next   int           // Round robin start
+	dialng int           // Dials in progress
+	ready  chan struct{} // Closed when a connection is added, then replaced
+	closed bool          // No further use
+	kick   chan struct{} // Requests a health check
+	wg     sync.WaitGroup
+}
+
+/*
+	One pooled connection.
+*/
+type poolConn struct {
+	c    *Connection
+	busy atomic.Int32 // Operations in progress
+	lost atomic.Bool  // A SEND failed with ECONBAD
+	hbf  int          // Consecutive heart beat failures seen, under p.mu
+}
+
+/*
+	PoolSize sets the number of connections kept open, min, and the number
+	allowed under load, max.  The defaults are 1 and 4.
+*/
+func PoolSize(min, max int) PoolOption {
+	return func(pc *poolConfig) {
+		pc.min = min
+		pc.max = max
+	}
+}
+
+/*
+	PoolCheckInterval sets how often connection health is checked.  Failed
+	sends also start a check.  The default is 1 second.
+*/
+func PoolCheckInterval(d time.Duration) PoolOption {
+	return func(pc *poolConfig) {
+		pc.chk = d
+	}
+}
+
+/*
+	PoolEvictAfter sets the number of health checks in a row at which a
+	connection's heart beats must have failed before it is evicted.  Heart
+	beat failures are possibly transient.  The default is 3.
+*/
+func PoolEvictAfter(n int) PoolOption {
+	return func(pc *poolConfig) {
+		pc.evn = n
+	}
+}
+
+/*
+	PoolEvictTimeout bounds the DISCONNECT of an evicted connection.  The
+	default is 5 seconds.
+*/
+func PoolEvictTimeout(d time.Duration) PoolOption {
+	return func(pc *poolConfig) {
+		pc.evto = d
+	}
+}
+
+/*
+	NewPool opens min connections using cf, and returns a Pool that manages
+	them.  If any connection fails, those already open are disconnected, and
+	the error is returned.
+
+	Example:
+		h := stompngo.Headers{stompngo.HK_ACCEPT_VERSION, "1.2",
+			stompngo.HK_HOST, "localhost"}
+		p, e := stompngo.NewPool(ctx,
+			stompngo.DialConnectFunc("stomp://localhost:61613", h),
+			stompngo.PoolSize(2, 8))
+		if e != nil {
+			// Do something sane ...
+		}
+		defer p.Close(context.Background())
+		e = p.Send(stompngo.Headers{stompngo.HK_DESTINATION, "/queue/a"}, "hi")
+*/
+func NewPool(ctx context.Context, cf ConnectFunc, opts ...PoolOption) (*Pool, error) {
+	cfg := poolConfig{min: 1, max: 4, chk: time.Second, evn: 3,
+		evto: 5 * time.Second}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.min < 1 || cfg.max < cfg.min {
+		return nil, EPOOLSIZE
+	}
+	p := &Pool{cf: cf, cfg: cfg,
+		ready: make(chan struct{}),
+		kick:  make(chan struct{}, 1)}
+	for len(p.conns) < cfg.min {
+		c, e := cf(ctx)
+		if e != nil {
+			for _, pc := range p.conns {
+				p.evict(pc.c)
+			}
+			return nil, e
+		}
+		p.conns = append(p.conns, &poolConn{c: c})
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.wg.Add(1)
+	go p.maintain()
+	return p, nil
+}
+
+/*
+	Len returns the number of healthy connections, as of the latest check.
+*/
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.conns)
+}
+
+/*
+	Get returns a usable connection, waiting for one if necessary.  The
+	connection remains owned by the Pool, and must not be disconnected.
+*/
+func (p *Pool) Get(ctx context.Context) (*Connection, error) {
+	pc, e := p.acquire(ctx)
+	if e != nil {
+		return nil, e
+	}
+	pc.busy.Add(-1)
+	return pc.c, nil
+}
+
+/*
+	Send a STOMP MESSAGE on a pooled connection.  See Connection.Send.
+*/
+func (p *Pool) Send(h Headers, b string) error {
+	return p.SendContext(context.Background(), h, b)
+}
+
+/*
+	SendContext sends a STOMP MESSAGE on a pooled connection.  See
+	Connection.SendContext.
+*/
+func (p *Pool) SendContext(ctx context.Context, h Headers, b string) error {
+	return p.SendBytesContext(ctx, h, []uint8(b))
+}
+
+/*
+	SendBytes sends a STOMP MESSAGE on a pooled connection.  See
+	Connection.SendBytes.
+*/
+func (p *Pool) SendBytes(h Headers, b []byte) error {
+	return p.SendBytesContext(context.Background(), h, b)
+}
+
+/*
+	SendBytesContext sends a STOMP MESSAGE on a pooled connection.  See
+	Connection.SendBytesContext.
+*/
+func (p *Pool) SendBytesContext(ctx context.Context, h Headers, b []byte) error {
+	var e error
+	for i := 0; i < p.cfg.max; i++ {
+		var pc *poolConn
+		if pc, e = p.acquire(ctx); e != nil {
+			return e
+		}
+		e = pc.c.SendBytesContext(ctx, h, b)
+		pc.busy.Add(-1)
+		if e != ECONBAD {
+			return e
+		}
+		pc.lost.Store(true) // Lost since acquired, try another
+		p.check()
+	}
+	return e
+}
+
+/*
+	Close stops the Pool, and disconnects every connection, bounded by ctx.
+	Any DISCONNECT errors are joined.
+*/
+func (p *Pool) Close(ctx context.Context) error {
+	p.cancel()
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ECONBAD
+	}
+	p.closed = true
+	cs := p.conns
+	p.conns = nil
+	close(p.ready)
+	p.mu.Unlock()
+	p.wg.Wait()
+	var es []error
+	for _, pc := range cs {
+		if e := pc.c.DisconnectContext(ctx, Headers{}); e != nil && e != ECONBAD {
+			es = append(es, e)
+		}
+	}
+	return errors.Join(es...)
+}
+
+/*
+	Choose the least busy usable connection, waiting for one if there is
+	none.  The caller decrements busy when done.
+*/
+func (p *Pool) acquire(ctx context.Context) (*poolConn, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ECONBAD
+		}
+		var pc *poolConn
+		n := len(p.conns)
+		for i := 0; i < n; i++ {
+			k := p.conns[(p.next+i)%n]
+			if !k.usable() {
+				p.check()
+				continue
+			}
+			if pc == nil || k.busy.Load() < pc.busy.Load() {
+				pc = k
+			}
+		}
+		if pc != nil {
+			p.next++
+			if pc.busy.Add(1) > 1 && n+p.dialng < p.cfg.max {
+				p.grow()
+			}
+			p.mu.Unlock()
+			return pc, nil
+		}
+		rc := p.ready
+		p.mu.Unlock()
+		select {
+		case <-rc:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+/*
+	Request a health check.
+*/
+func (p *Pool) check() {
+	select {
+	case p.kick <- struct{}{}:
+	default:
+	}
+}
+
+/*
+	Run health checks until the Pool is closed.
+*/
+func (p *Pool) maintain() {
+	defer p.wg.Done()
+	t := time.NewTicker(p.cfg.chk)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-p.kick:
+		case <-p.ctx.Done():
+			return
+		}
+		p.sweep()
+	}
+}
+
+/*
+	Check connection health, evict failed connections, and replace them.
+*/
+func (p *Pool) sweep() {
+	p.mu.Lock()
+	k := p.conns[:0]
+	var ev []*Connection
+	for _, pc := range p.conns {
+		if pc.usable() && pc.heartBeatsOK(p.cfg.evn) {
+			k = append(k, pc)
+		} else {
+			ev = append(ev, pc.c)
+		}
+	}
+	clear(p.conns[len(k):])
+	p.conns = k
+	for len(p.conns)+p.dialng < p.cfg.min {
+		p.grow()
+	}
+	p.mu.Unlock()
+	for _, c := range ev {
+		p.wg.Add(1)
+		go func(c *Connection) {
+			defer p.wg.Done()
+			p.evict(c)
+		}(c)
+	}
+}
+
+/*
+	Dial one more connection in the background.  Caller holds p.mu.  A failed
+	dial is retried by the next health check, if still needed.
+*/
+func (p *Pool) grow() {
+	p.dialng++
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		c, e := p.cf(p.ctx)
+		p.mu.Lock()
+		p.dialng--
+		if e != nil || p.closed || len(p.conns) >= p.cfg.max {
+			p.mu.Unlock()
+			if e == nil {
+				p.evict(c)
+			}
+			return
+		}
+		p.conns = append(p.conns, &poolConn{c: c})
+		close(p.ready)
+		p.ready = make(chan struct{})
+		p.mu.Unlock()
+	}()
+}
+
+/*
+	Disconnect a connection leaving the Pool.
+*/
+func (p *Pool) evict(c *Connection) {
+	ctx, cf := context.WithTimeout(context.Background(), p.cfg.evto)
+	defer cf()
+	_ = c.DisconnectContext(ctx, Headers{})
+}
+
+/*
+	Whether a pooled connection may be used.
+*/
+func (pc *poolConn) usable() bool {
+	return pc.c.Connected() && !pc.lost.Load()
+}
+
+/*
+	Count a health check, returning false once heart beats have failed at n
+	checks in a row.  Caller holds p.mu.
+*/
+func (pc *poolConn) heartBeatsOK(n int) bool {
+	if !pc.c.HeartBeatReceiveFailed() && !pc.c.HeartBeatSendFailed() {
+		pc.hbf = 0
+		return true
+	}
+	pc.hbf++
+	return pc.hbf < n
+}
@@ -0,0 +1,196 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+/*
+	A ConnectFunc for a broker, over pipes.
+*/
+func poolConnect(b *stomptest.Broker) ConnectFunc {
+	return func(ctx context.Context) (*Connection, error) {
+		return DialConn(ctx, b.Pipe(), ctxHeaders)
+	}
+}
+
+/*
+	Test the pool grows when busy, hands out the least busy connection, and
+	serves concurrent sends with no more than max connections.
+*/
+func TestPoolSend(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	p, e := NewPool(context.Background(), poolConnect(b), PoolSize(1, poolMax))
+	if e != nil {
+		t.Fatalf("TestPoolSend Expected [nil], got [%v]\n", e)
+	}
+	a1, _ := p.acquire(context.Background())
+	a2, _ := p.acquire(context.Background()) // Busy, so the pool grows
+	for dl := time.Now().Add(poolWait); p.Len() < 2 && time.Now().Before(dl); {
+		time.Sleep(poolCheck) // Growth is in the background
+	}
+	a3, _ := p.acquire(context.Background())
+	if a1 != a2 || a3 == a1 {
+		t.Fatalf("TestPoolSend Expected the least busy connection\n")
+	}
+	for _, a := range []*poolConn{a1, a2, a3} {
+		a.busy.Add(-1)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < poolSenders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < poolSends; j++ {
+				if e := p.Send(Headers{HK_DESTINATION, poolDest}, "pooled"); e != nil {
+					t.Errorf("TestPoolSend Expected [nil], got [%v]\n", e)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := p.Len(); n < 2 || n > poolMax {
+		t.Fatalf("TestPoolSend Expected 2 to [%d] connections, got [%d]\n", poolMax, n)
+	}
+	if e = p.Close(context.Background()); e != nil {
+		t.Fatalf("TestPoolSend Expected [nil], got [%v]\n", e)
+	}
+	if n := b.Pending(poolDest); n != poolSenders*poolSends {
+		t.Fatalf("TestPoolSend Expected [%d] pending, got [%d]\n",
+			poolSenders*poolSends, n)
+	}
+	if e = p.Send(Headers{HK_DESTINATION, poolDest}, "closed"); e != ECONBAD {
+		t.Fatalf("TestPoolSend Expected [%v], got [%v]\n", ECONBAD, e)
+	}
+}
+
+/*
+	Test connections are evicted when their reader exits, or heart beats go
+	dirty, and are replaced to keep min open.
+*/
+func TestPoolEvict(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	p, e := NewPool(context.Background(), poolConnect(b), PoolSize(2, 2),
+		PoolCheckInterval(poolCheck), PoolEvictTimeout(poolCheck))
+	if e != nil {
+		t.Fatalf("TestPoolEvict Expected [nil], got [%v]\n", e)
+	}
+	defer p.Close(context.Background())
+	p.mu.Lock()
+	dead, dirty := p.conns[0].c, p.conns[1].c
+	p.mu.Unlock()
+	_ = dead.netconn.Close()
+	dirty.hbrf.Store(true)
+	ctx, cf := context.WithTimeout(context.Background(), poolWait)
+	defer cf()
+	for {
+		p.mu.Lock()
+		ok := len(p.conns) == 2
+		for _, pc := range p.conns {
+			ok = ok && pc.c != dead && pc.c != dirty
+		}
+		p.mu.Unlock()
+		if ok {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("TestPoolEvict Expected replaced connections\n")
+		case <-time.After(poolCheck):
+		}
+	}
+	if e = p.SendContext(ctx, Headers{HK_DESTINATION, poolDest}, "evict"); e != nil {
+		t.Fatalf("TestPoolEvict Expected [nil], got [%v]\n", e)
+	}
+	if dirty.Connected() {
+		t.Fatalf("TestPoolEvict Expected the dirty connection disconnected\n")
+	}
+}
+
+/*
+	Test a heart beat failure evicts only after PoolEvictAfter checks in a
+	row, and a connection with a lost SEND is not used, and is evicted.
+*/
+func TestPoolEvictAfter(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	p, e := NewPool(context.Background(), poolConnect(b), PoolSize(2, 2),
+		PoolCheckInterval(poolWait), PoolEvictAfter(poolEvictN),
+		PoolEvictTimeout(poolCheck))
+	if e != nil {
+		t.Fatalf("TestPoolEvictAfter Expected [nil], got [%v]\n", e)
+	}
+	defer p.Close(context.Background())
+	p.mu.Lock()
+	dirty, lost := p.conns[0], p.conns[1]
+	p.mu.Unlock()
+	has := func(pc *poolConn) bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for _, k := range p.conns {
+			if k == pc {
+				return true
+			}
+		}
+		return false
+	}
+	dirty.c.hbrf.Store(true)
+	for i := 1; i < poolEvictN; i++ {
+		p.sweep()
+	}
+	dirty.c.hbrf.Store(false) // Transient
+	p.sweep()
+	if !has(dirty) {
+		t.Fatalf("TestPoolEvictAfter Expected a transient failure kept\n")
+	}
+	lost.lost.Store(true)
+	for i := 0; i < 2; i++ {
+		pc, e := p.acquire(context.Background())
+		if e != nil || pc != dirty {
+			t.Fatalf("TestPoolEvictAfter Expected the usable connection, got [%v]\n", e)
+		}
+		pc.busy.Add(-1)
+	}
+	dirty.c.hbrf.Store(true)
+	for i := 0; i < poolEvictN; i++ {
+		p.sweep()
+	}
+	if has(dirty) || has(lost) {
+		t.Fatalf("TestPoolEvictAfter Expected both connections evicted\n")
+	}
+}
+
+/*
+	Test pool size validation.
+*/
+func TestPoolSize(t *testing.T) {
+	for _, sz := range [][2]int{{0, 1}, {2, 1}} {
+		_, e := NewPool(context.Background(), nil, PoolSize(sz[0], sz[1]))
+		if e != EPOOLSIZE {
+			t.Fatalf("TestPoolSize Expected [%v], got [%v]\n", EPOOLSIZE, e)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/photostorm/stompngo/protobuf
+
+go 1.21
+
+require (
+	github.com/photostorm/stompngo v0.0.0-20261018014503-ed5ce6ade001
+	google.golang.org/protobuf v1.36.5
+)
@@ -0,0 +1,96 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+/*
+	Package protobuf is a Protocol Buffers Codec for stompngo.SendTyped and
+	stompngo.Decode.
+
+	Values sent must be proto.Message, usually a pointer to a generated
+	message type, and values decoded must be such a pointer type.
+
+	Example:
+		protobuf.Register()
+		h := stompngo.Headers{stompngo.HK_DESTINATION, "/queue/orders",
+			stompngo.HK_CONTENT_TYPE, protobuf.ContentType}
+		e := stompngo.SendTyped(c, h, &pb.Order{Id: 42})
+		if e != nil {
+			// Do something sane ...
+		}
+		...
+		o, e := stompngo.Decode[*pb.Order](&md.Message)
+*/
+package protobuf
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/photostorm/stompngo"
+	"google.golang.org/protobuf/proto"
+)
+
+/*
+	Protocol Buffers content-types.  Register uses both.
+*/
+const (
+	ContentType    = "application/x-protobuf"
+	ContentTypeStd = "application/protobuf"
+)
+
+/*
+	Codec is a stompngo.Codec for Protocol Buffers messages.
+*/
+type Codec struct{}
+
+/*
+	Register registers Codec for ContentType and ContentTypeStd.
+*/
+func Register() {
+	stompngo.RegisterCodec(ContentType, Codec{})
+	stompngo.RegisterCodec(ContentTypeStd, Codec{})
+}
+
+/*
+	Marshal encodes v, which must be a proto.Message.
+*/
+func (Codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+/*
+	Unmarshal decodes b into v, which must be a proto.Message, or a pointer
+	to one, as stompngo.Decode passes.  A nil message is allocated.
+*/
+func (Codec) Unmarshal(b []byte, v any) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(b, m)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() &&
+		rv.Elem().Kind() == reflect.Pointer {
+		if rv.Elem().IsNil() {
+			rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+		}
+		if m, ok := rv.Elem().Interface().(proto.Message); ok {
+			return proto.Unmarshal(b, m)
+		}
+	}
+	return fmt.Errorf("protobuf: %T is not a proto.Message", v)
+}
@@ -0,0 +1,66 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package protobuf_test
+
+import (
+	"testing"
+
+	"github.com/photostorm/stompngo"
+	"github.com/photostorm/stompngo/protobuf"
+	"github.com/photostorm/stompngo/stomptest"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+/*
+	Test a protobuf message round trip with SendTyped and Decode.
+*/
+func TestProtobufRoundTrip(t *testing.T) {
+	protobuf.Register()
+	b := stomptest.NewBroker()
+	defer b.Close()
+	c, e := stompngo.Connect(b.Pipe(), stompngo.Headers{stompngo.HK_ACCEPT_VERSION,
+		stompngo.SPL_12, stompngo.HK_HOST, "localhost"})
+	if e != nil {
+		t.Fatalf("TestProtobufRoundTrip Expected [nil], got [%v]\n", e)
+	}
+	defer c.Disconnect(stompngo.Headers{})
+	dest := "/queue/protobuf.test"
+	sc, e := c.Subscribe(stompngo.Headers{stompngo.HK_DESTINATION, dest})
+	if e != nil {
+		t.Fatalf("TestProtobufRoundTrip Expected [nil], got [%v]\n", e)
+	}
+	v, _ := structpb.NewStruct(map[string]any{"id": 42.0, "item": "widget"})
+	for _, ct := range []string{protobuf.ContentType, protobuf.ContentTypeStd} {
+		e = stompngo.SendTyped(c, stompngo.Headers{stompngo.HK_DESTINATION, dest,
+			stompngo.HK_CONTENT_TYPE, ct}, v)
+		if e != nil {
+			t.Fatalf("TestProtobufRoundTrip Expected [nil], got [%v]\n", e)
+		}
+		md := <-sc
+		got, e := stompngo.Decode[*structpb.Struct](&md.Message)
+		if e != nil {
+			t.Fatalf("TestProtobufRoundTrip Expected [nil], got [%v]\n", e)
+		}
+		if !proto.Equal(got, v) {
+			t.Fatalf("TestProtobufRoundTrip Expected [%v], got [%v]\n", v, got)
+		}
+	}
+	if _, e = (protobuf.Codec{}).Marshal("not a message"); e == nil {
+		t.Fatalf("TestProtobufRoundTrip Expected an error, got [nil]\n")
+	}
+}
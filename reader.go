@@ -17,11 +17,11 @@
 package stompngo
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
-	"strconv"
-	"strings"
 	"time"
 )
 
@@ -41,90 +41,89 @@ readLoop:
 			// fmt.Println("DEROV", c.eltd.rov.ens)
 			st := time.Now().UnixNano()
 			f, e = c.readFrame()
-			c.eltd.rov.ens += time.Now().UnixNano() - st
-			c.eltd.rov.ec++
+			c.eltd.add(&c.eltd.rov, "ROV", st)
 		} else {
 			f, e = c.readFrame()
 		}
 
 		if e != nil {
 			//debug.PrintStack()
-			f.Headers = append(f.Headers, "connection_read_error", e.Error())
-			md := MessageData{Message(f), e}
-			c.handleReadError(md)
-			if e == io.EOF && !c.isConnected() {
-				c.log("RDR_SHUTDOWN_EOF", e)
-			} else {
-				c.log("RDR_CONN_GENL_ERR", e)
-			}
+			c.readError(f, e)
 			break readLoop
 		}
 
+		c.mets.lr.Store(time.Now().UnixNano())
 		if f.Command == "" {
 			continue readLoop
 		}
 
+		c.logx("recv", &f)
 		m := Message(f)
-		c.mets.tfr += 1 // Total frames read
+		bs := c.pbs // Streamed body, if any
+		c.pbs = nil
 		// Headers already decoded
-		c.mets.tbr += m.Size(false) // Total bytes read
+		sz := m.Size(false)
+		if bs != nil {
+			sz += bs.n
+		}
+		c.mets.read(m.Command, sz)
 
 		//*************************************************************************
 		// Replacement START
-		md := MessageData{m, e}
+		md := MessageData{Message: m, Error: e}
+		if bs != nil {
+			md.Stream = bs
+		}
+		rd := false // RECEIPT taken by a receipt-id waiter
 		switch f.Command {
 		//
 		case MESSAGE:
-			sid, ok := f.Headers.Contains(HK_SUBSCRIPTION)
-			if !ok { // This should *NEVER* happen
-				panic(fmt.Sprintf("stompngo INTERNAL ERROR: command:<%s> headers:<%v>",
-					f.Command, f.Headers))
-			}
-			c.subsLock.RLock()
-			ps, sok := c.subs[sid] // This is a map of pointers .....
-			//
-			if !sok {
-				// The sub can be gone under some timing conditions.  In that case
-				// we log it of possible, and continue (hope for the best).
-				c.log("RDR_NOSUB", sid, m.Command, m.Headers)
-				goto csRUnlock
-			}
-			if ps.cs {
-				// The sub can also already be closed under some conditions.
-				// Again, we log that if possible, and continue
-				c.log("RDR_CLSUB", sid, m.Command, m.Headers)
-				goto csRUnlock
+			end := c.traceMessage(m)
+			e = c.routeMessage(md)
+			end()
+			if e != nil {
+				c.readError(f, e)
+				break readLoop
 			}
-			// Handle subscription draining
-			switch ps.drav {
-			case false:
-				ps.md <- md
-			default:
-				ps.drmc++
-				if ps.drmc <= ps.dra {
-					ps.md <- md
+			if bs != nil {
+				if e = c.finishStream(bs); e == ECONBAD {
+					break readLoop
+				} else if e != nil {
+					c.readError(f, e)
+					break readLoop
 				}
+				// The consumer paced this frame, so a DISCONNECT may already
+				// be written.  Read on, for its RECEIPT.
+				continue readLoop
 			}
-		csRUnlock:
-			c.subsLock.RUnlock()
 		//
 		case ERROR:
 			fallthrough
 		//
 		case RECEIPT:
-			c.input <- md
+			// Callers waiting for a specific receipt-id get it directly
+			if c.rcpts.deliver(md) {
+				rd = true
+			} else {
+				c.input <- md
+			}
 		//
-		default:
-			panic(fmt.Sprintf("Broker SEVERE ERROR, not STOMP? command:<%s> headers:<%v>",
-				f.Command, f.Headers))
+		default: // readFrame validates commands, so this should *NEVER* happen
+			c.readError(f, EINVBCMD)
+			break readLoop
 		}
 		// Replacement END
 		//*************************************************************************
 
 		select {
 		case _ = <-c.ssdc:
-			c.log("RDR_SHUTDOWN detected")
-			break readLoop
+			// The writer shuts down after a DISCONNECT.  A MESSAGE, or a
+			// RECEIPT for another frame, may still come first, so then read on
+			// for any RECEIPT wanted.
+			if !c.dscw.Load() || (f.Command != MESSAGE && !rd) {
+				c.log("RDR_SHUTDOWN detected")
+				break readLoop
+			}
 		default:
 		}
 		c.log("RDR_RELOOP")
@@ -132,9 +131,81 @@ readLoop:
 	close(c.input)
 	c.setConnected(false)
 	c.sysAbort()
+	c.closeNetconn()
 	c.log("RDR_SHUTDOWN", time.Now())
 }
 
+/*
+	Notify all subscribers of a reader error.  The reader then shuts down.
+	The error delivered wraps both ECONBAD and the cause.
+*/
+func (c *Connection) readError(f Frame, e error) {
+	f.Headers = append(f.Headers, "connection_read_error", e.Error())
+	c.handleReadError(MessageData{Message: Message(f),
+		Error: fmt.Errorf("%w: %w", ECONBAD, e)})
+	if (e == io.EOF || errors.Is(e, net.ErrClosed)) && !c.isConnected() {
+		c.logl(slog.LevelInfo, "RDR_SHUTDOWN_EOF", LK_ERROR, e)
+	} else {
+		c.logl(slog.LevelError, "RDR_CONN_GENL_ERR", LK_ERROR, e)
+	}
+}
+
+/*
+	Route a MESSAGE frame to its subscription.  A non-nil return shuts the
+	reader down.
+*/
+func (c *Connection) routeMessage(md MessageData) error {
+	sid, ok := md.Message.Headers.Contains(HK_SUBSCRIPTION)
+	if !ok {
+		return EMSGNOSUB
+	}
+	c.subsLock.RLock()
+	defer c.subsLock.RUnlock()
+	ps, sok := c.subs[sid] // This is a map of pointers .....
+	//
+	if !sok {
+		// The sub can be gone under some timing conditions, or the broker may
+		// be confused.  The orphan policy decides.
+		c.logl(slog.LevelWarn, "RDR_NOSUB", LK_SUBSCRIPTION, sid,
+			LK_COMMAND, md.Message.Command, LK_HEADERS, md.Message.Headers)
+		switch OrphanPolicy(c.orph.Load()) {
+		case OrphanDeliver:
+			select {
+			case c.input <- md:
+			case <-c.ssdc:
+				closeStream(md)
+			}
+		case OrphanAbort:
+			return EORPHMSG
+		default:
+			closeStream(md)
+		}
+		return nil
+	}
+	if ps.cs {
+		// The sub can also already be closed under some conditions.
+		// We log that if possible, and continue
+		c.logl(slog.LevelWarn, "RDR_CLSUB", LK_SUBSCRIPTION, sid,
+			LK_COMMAND, md.Message.Command, LK_HEADERS, md.Message.Headers)
+		closeStream(md)
+		return nil
+	}
+	// Handle subscription draining
+	switch ps.drav {
+	case false:
+		c.trackAck(ps, md.Message.Headers)
+		ps.deliver(md)
+	default:
+		ps.drmc++
+		if ps.drmc <= ps.dra {
+			ps.deliver(md)
+		} else {
+			closeStream(md)
+		}
+	}
+	return nil
+}
+
 /*
 	Physical frame reader.
 
@@ -144,111 +215,112 @@ readLoop:
 	Note: this functionality could hang or exhibit other erroneous behavior
 	if running against a non-compliant STOMP server.
 */
-func (c *Connection) readFrame() (f Frame, e error) {
-	var s string
-	var bx []byte
-	f = Frame{"", Headers{}, NULLBUFF}
+func (c *Connection) readFrame() (Frame, error) {
+	p := frameParser{r: c.rdr, lim: c.readLimits(), valid: validCmds,
+		hooks: c, stream: c.sbdy.Load()}
+	f, e := p.readFrame()
+	if e != nil || f.Command == "" {
+		return f, e
+	}
+	if p.rem > 0 {
+		// The body is read by the consumer, see finishStream.
+		c.pbs = newBodyStream(c, p.rem)
+		return f, nil
+	}
+	// End of read loop - set no deadline
+	if c.dld.rde {
+		_ = c.netconn.SetReadDeadline(c.dld.t0)
+	}
+	return f, nil
+}
 
-	// Read f.Command or line ends (maybe heartbeats)
+/*
+	Set any read deadline before a frame parser read, and start its elapsed
+	time.
+*/
+func (c *Connection) readStart() int64 {
 	c.setReadDeadline()
-
-	if c.eltd != nil {
-		st := time.Now().UnixNano()
-		// s, e = c.rdr.ReadString('\n')
-		bx, e = c.rdr.ReadBytes('\n')
-		s = string(bx)
-		c.eltd.rcmd.ens += time.Now().UnixNano() - st
-		c.eltd.rcmd.ec++
-		// fmt.Println("DERCMD", s)
-	} else {
-		// s, e = c.rdr.ReadString('\n')
-		bx, e = c.rdr.ReadBytes('\n')
-		s = string(bx)
+	if c.eltd == nil {
+		return 0
 	}
+	return time.Now().UnixNano()
+}
 
-	if c.checkReadError(e) != nil {
-		return f, e
-	}
-	if s == "" {
-		return f, e
+/*
+	Record a frame parser read, and note a good read for heart beats.
+*/
+func (c *Connection) readEnd(k int, st int64, e error) {
+	if c.eltd != nil {
+		switch k {
+		case parseCommand:
+			c.eltd.add(&c.eltd.rcmd, "RCMD", st)
+		case parseHeader:
+			c.eltd.add(&c.eltd.rivh, "RIVH", st)
+		case parseNul:
+			c.eltd.add(&c.eltd.run, "RUN", st)
+		default:
+			c.eltd.add(&c.eltd.rbdy, "RBDY", st)
+		}
 	}
-	if c.hbd != nil {
+	if c.checkReadError(e) == nil && c.hbd != nil {
 		c.updateHBReads()
 	}
-	f.Command = s[0 : len(s)-1]
-	if s == "\n" {
-		return f, e
-	}
-	// fmt.Println("DERCMD2", f.Command)
-	// Validate the command
-	if _, ok := validCmds[f.Command]; !ok {
-		ev := fmt.Errorf("%s\n%s", EINVBCMD, HexData([]byte(f.Command)))
-		return f, ev
-	}
-	// Read f.Headers
-	for {
-		c.setReadDeadline()
-
-		if c.eltd != nil {
-			st := time.Now().UnixNano()
-			s, e = c.rdr.ReadString('\n')
-			c.eltd.rivh.ens += time.Now().UnixNano() - st
-			c.eltd.rivh.ec++
+}
 
-		} else {
-			s, e = c.rdr.ReadString('\n')
-		}
+/*
+	Scratch space for the frame parser, reused across frames.  Header strings
+	that are not interned are decoded into buf, and become substrings of one
+	string when the headers are complete.
+*/
+type headerScratch struct {
+	h   Headers // Interned strings, "" if in buf
+	pos []int   // Start and end in buf of each string, -1 if interned
+	buf []byte  // Decoded strings
+}
 
-		if c.checkReadError(e) != nil {
-			return f, e
-		}
-		if c.hbd != nil {
-			c.updateHBReads()
-		}
-		if s == "\n" {
-			break
-		}
-		s = s[0 : len(s)-1]
-		p := strings.SplitN(s, ":", 2)
-		if len(p) != 2 {
-			return f, EUNKHDR
-		}
-		// Always decode regardless of protocol level. See issue #47.
-		p[0] = decode(p[0])
-		p[1] = decode(p[1])
-		f.Headers = append(f.Headers, p[0], p[1])
-	}
-	//
-	e = checkHeaders(f.Headers, c.Protocol())
-	if e != nil {
-		return f, e
+/*
+	Add a header key or value from the wire.
+*/
+func (hs *headerScratch) add(p []byte) {
+	if s, ok := internStrings[string(p)]; ok {
+		hs.h = append(hs.h, s)
+		hs.pos = append(hs.pos, -1, -1)
+		return
 	}
-	// Read f.Body
-	if v, ok := f.Headers.Contains(HK_CONTENT_LENGTH); ok {
-		l, e := strconv.Atoi(strings.TrimSpace(v))
-		if e != nil {
-			return f, e
-		}
-		if l == 0 {
-			f.Body, e = readUntilNul(c)
+	st := len(hs.buf)
+	hs.buf = appendDecoded(hs.buf, p)
+	hs.h = append(hs.h, "")
+	hs.pos = append(hs.pos, st, len(hs.buf))
+}
+
+/*
+	The Headers added so far, with at most two allocations.
+*/
+func (hs *headerScratch) headers() Headers {
+	r := make(Headers, len(hs.h))
+	all := string(hs.buf)
+	for i := range r {
+		if hs.pos[2*i] < 0 {
+			r[i] = hs.h[i]
 		} else {
-			f.Body, e = readBody(c, l)
+			r[i] = all[hs.pos[2*i]:hs.pos[2*i+1]]
 		}
-	} else {
-		// content-length not present
-		f.Body, e = readUntilNul(c)
 	}
-	if c.checkReadError(e) != nil {
-		return f, e
-	}
-	if c.hbd != nil {
-		c.updateHBReads()
-	}
-	// End of read loop - set no deadline
-	if c.dld.rde {
-		_ = c.netconn.SetReadDeadline(c.dld.t0)
+	return r
+}
+
+/*
+	Return the scratch space to the pool, unless it has grown too large.
+*/
+func (hs *headerScratch) release() {
+	if cap(hs.h) > headersPoolMax || cap(hs.buf) > headersPoolMax*64 {
+		return
 	}
-	return f, e
+	clear(hs.h)
+	hs.h = hs.h[:0]
+	hs.pos = hs.pos[:0]
+	hs.buf = hs.buf[:0]
+	headersPool.Put(hs)
 }
 
 func (c *Connection) updateHBReads() {
@@ -0,0 +1,117 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"sync"
+)
+
+/*
+	Receipt manager.  Callers waiting for a RECEIPT, keyed by receipt-id.  A
+	caller that gives up leaves its waiter in place, until the RECEIPT arrives
+	or the connection ends.
+*/
+type receiptManager struct {
+	mu      sync.Mutex
+	waiters map[string]chan MessageData
+}
+
+/*
+	Register a waiter for a receipt-id.
+*/
+func (rm *receiptManager) add(rid string) chan MessageData {
+	r := make(chan MessageData, 1)
+	rm.mu.Lock()
+	rm.waiters[rid] = r
+	rm.mu.Unlock()
+	return r
+}
+
+/*
+	Remove a waiter for a receipt-id.
+*/
+func (rm *receiptManager) remove(rid string) {
+	rm.mu.Lock()
+	delete(rm.waiters, rid)
+	rm.mu.Unlock()
+}
+
+/*
+	Hand a RECEIPT or ERROR frame to any waiter for its receipt-id.  Returns
+	false if there is no waiter.
+*/
+func (rm *receiptManager) deliver(md MessageData) bool {
+	rid, ok := md.Message.Headers.Contains(HK_RECEIPT_ID)
+	if !ok {
+		return false
+	}
+	rm.mu.Lock()
+	r, ok := rm.waiters[rid]
+	delete(rm.waiters, rid)
+	rm.mu.Unlock()
+	if ok {
+		r <- md // Never blocks
+	}
+	return ok
+}
+
+/*
+	Transmit a frame with a receipt request, and wait for the broker's
+	RECEIPT, a matching ERROR, ctx to be done, or the connection to fail.
+*/
+func (c *Connection) transmitReceipt(ctx context.Context, f Frame, bs *sendStream) error {
+	rid, ok := f.Headers.Contains(HK_RECEIPT)
+	if !ok {
+		rid = Uuid()
+		f.Headers = f.Headers.Add(HK_RECEIPT, rid)
+	}
+	r := c.rcpts.add(rid)
+	if e := c.transmitWire(ctx, f, bs); e != nil {
+		if ctx.Err() == nil { // Otherwise the frame may be on the wire
+			c.rcpts.remove(rid)
+		}
+		return e
+	}
+	select {
+	case md := <-r:
+		return receiptResult(md)
+	case <-ctx.Done():
+		// The receipt-id stays registered, so that a late RECEIPT is
+		// discarded, and not taken for one wanted elsewhere.
+		return ctx.Err()
+	case <-c.ssdc:
+		c.rcpts.remove(rid)
+		select {
+		case md := <-r: // Arrived just before shutdown
+			return receiptResult(md)
+		default:
+		}
+		return ECONBAD
+	}
+}
+
+/*
+	The result of a receipt request: nil for a RECEIPT, a *BrokerError for
+	an ERROR.
+*/
+func receiptResult(md MessageData) error {
+	if md.Message.Command == ERROR {
+		return &BrokerError{Message: md.Message}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+/*
+	Test SendWithReceipt, RECEIPT and ERROR responses.
+*/
+func TestReceiptSend(t *testing.T) {
+	c, pb := pipeConnect(t, ctxHeaders, ctxConnected)
+	defer pb.n.Close()
+	go func() {
+		for _, rd := range receiptResponses {
+			f, e := pb.readFrame()
+			if e != nil {
+				return
+			}
+			_, _ = pb.n.Write([]byte(rd.cmd + "\n" + HK_RECEIPT_ID + ":" +
+				rawHeader(f, HK_RECEIPT) + "\n" + rd.hdrs + "\n\x00"))
+		}
+	}()
+	ctx, cf := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cf()
+	for _, rd := range receiptResponses {
+		e = c.SendWithReceipt(ctx, Headers{HK_DESTINATION, "/queue/rcpt"}, tm)
+		if rd.cmd == RECEIPT {
+			if e != nil {
+				t.Fatalf("TestReceiptSend Expected [nil], got [%v]\n", e)
+			}
+			continue
+		}
+		be, ok := e.(*BrokerError)
+		if !ok {
+			t.Fatalf("TestReceiptSend Expected *BrokerError, got [%v]\n", e)
+		}
+		if be.Message.Headers.Value(HK_MESSAGE) != "denied" {
+			t.Fatalf("TestReceiptSend Expected [denied], got [%v]\n", be)
+		}
+	}
+	// Nothing should be queued for the connection level channel
+	checkReceived(t, c, false)
+}
+
+/*
+	Test SubscribeWithReceipt when the broker does not answer in time, and
+	that the late RECEIPT is discarded.
+*/
+func TestReceiptSubscribeTimeout(t *testing.T) {
+	c, pb := pipeConnect(t, ctxHeaders, ctxConnected)
+	defer pb.n.Close()
+	fc := make(chan string, 1)
+	go func() {
+		f, _ := pb.readFrame()
+		fc <- f
+	}()
+	ctx, cf := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cf()
+	_, e = c.SubscribeWithReceipt(ctx, Headers{HK_DESTINATION, "/queue/rcpt",
+		HK_ID, "rcpt-sub"})
+	if e != context.DeadlineExceeded {
+		t.Fatalf("TestReceiptSubscribeTimeout Expected [%v], got [%v]\n",
+			context.DeadlineExceeded, e)
+	}
+	c.subsLock.RLock()
+	_, ok := c.subs["rcpt-sub"]
+	c.subsLock.RUnlock()
+	if ok {
+		t.Fatalf("TestReceiptSubscribeTimeout Expected subscription removed\n")
+	}
+	// The late RECEIPT was abandoned
+	f := <-fc
+	_, _ = pb.n.Write([]byte(RECEIPT + "\n" + HK_RECEIPT_ID + ":" +
+		rawHeader(f, HK_RECEIPT) + "\n\n\x00"))
+	select {
+	case md := <-c.MessageData:
+		t.Fatalf("TestReceiptSubscribeTimeout Expected nothing, got [%v]\n", md)
+	case <-time.After(receiptLatePause):
+	}
+}
+
+/*
+	Test a RECEIPT arriving after SendWithReceipt gave up, followed by a
+	Disconnect.  The late RECEIPT must not be taken for the DISCONNECT one.
+*/
+func TestReceiptLateDisconnect(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Expect(SEND).Pause(receiptLatePause).Receipt().
+		Expect(DISCONNECT).Receipt().WaitClose()
+	n, rc := s.Pipe()
+	c, e := DialConn(context.Background(), n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestReceiptLateDisconnect CONNECT expected [nil], got [%v]\n", e)
+	}
+	ctx, cf := context.WithTimeout(context.Background(), receiptLatePause/4)
+	defer cf()
+	e = c.SendWithReceipt(ctx, Headers{HK_DESTINATION, "/queue/rcpt.late"}, tm)
+	if e != context.DeadlineExceeded {
+		t.Fatalf("TestReceiptLateDisconnect Expected [%v], got [%v]\n",
+			context.DeadlineExceeded, e)
+	}
+	if e = c.Disconnect(empty_headers); e != nil {
+		t.Fatalf("TestReceiptLateDisconnect Expected [nil], got [%v]\n", e)
+	}
+	if se := <-rc; se != nil {
+		t.Fatalf("TestReceiptLateDisconnect Script error [%v]\n", se)
+	}
+}
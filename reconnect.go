@@ -0,0 +1,797 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+/*
+	ConnectFunc is a function that establishes a new, fully connected STOMP
+	Connection.  It is called once initially, and again for each reconnect.
+*/
+type ConnectFunc func(ctx context.Context) (*Connection, error)
+
+/*
+	ReconnectOption is a function that modifies Reconnector behavior.
+*/
+type ReconnectOption func(*reconnectConfig)
+
+/*
+	ReconnectEvent describes a change in the state of a Reconnector.
+*/
+type ReconnectEvent struct {
+	Type    string // One of the RE_* constants
+	Attempt int    // Reconnect attempt number, 0 if not applicable
+	Error   error  // Cause, possibly nil
+}
+
+/*
+	ReconnectEvent types.
+*/
+const (
+	RE_DISCONNECTED    = "disconnected"     // Connection lost
+	RE_RECONNECTED     = "reconnected"      // Connection reestablished
+	RE_RECONNECTFAILED = "reconnect_failed" // A single attempt failed
+	RE_CLOSED          = "closed"           // No further reconnects
+)
+
+/*
+	Reconnector configuration, built from ReconnectOptions.
+*/
+type reconnectConfig struct {
+	minb time.Duration // Initial backoff
+	maxb time.Duration // Maximum backoff
+	mxat int           // Maximum attempts per outage, 0 means no limit
+	evcc int           // Event channel capacity
+}
+
+/*
+	Reconnector is an opt-in resilient wrapper around a Connection.
+
+	When the underlying connection fails, Reconnector calls its ConnectFunc
+	again, with exponential backoff.  After a successful reconnect every
+	active subscription is reissued with its original headers, including the
+	id and ack mode, and every open transaction is begun again, with the SEND
+	frames sent in that transaction replayed.  ACK and NACK frames are not
+	replayed, the broker redelivers unacknowledged messages.
+
+	MESSAGE frames continue to arrive on the channel originally returned by
+	Subscribe.  RECEIPT and ERROR frames arrive on MessageData.
+
+	Reconnector owns the network connection of every Connection returned by
+	its ConnectFunc, and closes it when that connection is lost.  Operations
+	that fail because the connection is lost are not retried.  Operations
+	attempted while a reconnect is in progress wait for it to complete, or
+	for their context to be done.
+*/
+type Reconnector struct {
+	MessageData <-chan MessageData // Inbound RECEIPT and ERROR frames
+	cf          ConnectFunc
+	cfg         reconnectConfig
+	input       chan MessageData
+	events      chan ReconnectEvent
+	ctx         context.Context // Canceled on Disconnect
+	cancel      context.CancelFunc
+	mu          sync.Mutex
+	gen         *reconnectGen            // Current connection, nil if down
+	ready       chan struct{}            // Closed when gen is set, or when closed
+	closed      bool                     // No further use
+	scc         int                      // Subscribe channel capacity
+	subs        map[string]*reconnectSub // Active subscriptions, by id
+	txs         map[string][]Frame       // Open transactions, by id
+	fwg         sync.WaitGroup           // Forwarder goroutines
+}
+
+/*
+	One physical connection generation.
+*/
+type reconnectGen struct {
+	c    *Connection
+	done chan struct{} // Closed when c is lost
+	smon chan struct{} // Closed to stop the monitor
+	mwg  sync.WaitGroup
+}
+
+/*
+	One client subscription, which outlives physical connections.
+*/
+type reconnectSub struct {
+	h    Headers          // Original SUBSCRIBE headers, with id
+	out  chan MessageData // Client facing channel
+	stop chan struct{}    // Closed on Unsubscribe
+}
+
+/*
+	ReconnectBackoff sets the initial and maximum delay between reconnect
+	attempts.  The delay doubles after each failed attempt.  The defaults are
+	100 milliseconds and 30 seconds.
+*/
+func ReconnectBackoff(min, max time.Duration) ReconnectOption {
+	return func(rc *reconnectConfig) {
+		rc.minb = min
+		rc.maxb = max
+	}
+}
+
+/*
+	ReconnectMaxAttempts limits the number of reconnect attempts made for a
+	single outage.  The default, 0, means no limit.
+*/
+func ReconnectMaxAttempts(n int) ReconnectOption {
+	return func(rc *reconnectConfig) {
+		rc.mxat = n
+	}
+}
+
+/*
+	ReconnectEventCap sets the capacity of the Events channel.  Events are
+	dropped if the channel is full.  The default is 16.
+*/
+func ReconnectEventCap(n int) ReconnectOption {
+	return func(rc *reconnectConfig) {
+		rc.evcc = n
+	}
+}
+
+/*
+	DialConnectFunc returns a ConnectFunc that uses Dial, with a copy of the
+	supplied headers, for every connect.
+*/
+func DialConnectFunc(rawurl string, h Headers, opts ...DialOption) ConnectFunc {
+	ch := h.Clone()
+	return func(ctx context.Context) (*Connection, error) {
+		return Dial(ctx, rawurl, ch.Clone(), opts...)
+	}
+}
+
+/*
+	NewReconnector makes the initial connection using cf, and returns a
+	Reconnector that manages it.
+
+	Example:
+		h := stompngo.Headers{stompngo.HK_ACCEPT_VERSION, "1.2",
+			stompngo.HK_HOST, "localhost"}
+		r, e := stompngo.NewReconnector(ctx,
+			stompngo.DialConnectFunc("stomp://localhost:61613", h),
+			stompngo.ReconnectBackoff(time.Second, time.Minute))
+		if e != nil {
+			// Do something sane ...
+		}
+		go func() {
+			for ev := range r.Events() {
+				// Log ev ...
+			}
+		}()
+		// Use r
+*/
+func NewReconnector(ctx context.Context, cf ConnectFunc,
+	opts ...ReconnectOption) (*Reconnector, error) {
+	cfg := reconnectConfig{minb: 100 * time.Millisecond, maxb: 30 * time.Second,
+		evcc: 16}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	c, e := cf(ctx)
+	if e != nil {
+		return nil, e
+	}
+	r := &Reconnector{cf: cf, cfg: cfg,
+		input:  make(chan MessageData, 1),
+		events: make(chan ReconnectEvent, cfg.evcc),
+		ready:  make(chan struct{}),
+		scc:    c.SubChanCap(),
+		subs:   make(map[string]*reconnectSub),
+		txs:    make(map[string][]Frame)}
+	r.MessageData = r.input
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	r.mu.Lock()
+	r.install(c)
+	r.mu.Unlock()
+	return r, nil
+}
+
+/*
+	Events returns the channel of ReconnectEvents.  It is closed after
+	Disconnect, or after reconnect attempts are exhausted.
+*/
+func (r *Reconnector) Events() <-chan ReconnectEvent {
+	return r.events
+}
+
+/*
+	Connection returns the current physical connection, or nil if a reconnect
+	is in progress.
+*/
+func (r *Reconnector) Connection() *Connection {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.gen == nil {
+		return nil
+	}
+	return r.gen.c
+}
+
+/*
+	SubChanCap returns the subscribe channel capacity.
+*/
+func (r *Reconnector) SubChanCap() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.scc
+}
+
+/*
+	SetSubChanCap sets the subscribe channel capacity, to be used during future
+	SUBSCRIBE operations, and on all future physical connections.
+*/
+func (r *Reconnector) SetSubChanCap(nc int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.scc = nc
+	if r.gen != nil {
+		r.gen.c.SetSubChanCap(nc)
+	}
+}
+
+/*
+	Send a STOMP MESSAGE.  See Connection.Send.
+*/
+func (r *Reconnector) Send(h Headers, b string) error {
+	return r.SendContext(context.Background(), h, b)
+}
+
+/*
+	SendContext sends a STOMP MESSAGE.  See Connection.SendContext.
+*/
+func (r *Reconnector) SendContext(ctx context.Context, h Headers, b string) error {
+	return r.SendBytesContext(ctx, h, []uint8(b))
+}
+
+/*
+	SendBytes sends a STOMP MESSAGE.  See Connection.SendBytes.
+*/
+func (r *Reconnector) SendBytes(h Headers, b []byte) error {
+	return r.SendBytesContext(context.Background(), h, b)
+}
+
+/*
+	SendBytesContext sends a STOMP MESSAGE.  See Connection.SendBytesContext.
+*/
+func (r *Reconnector) SendBytesContext(ctx context.Context, h Headers, b []byte) error {
+	return r.do(ctx, func(c *Connection) error {
+		e := c.SendBytesContext(ctx, h, b)
+		if e == nil {
+			if tx, ok := h.Contains(HK_TRANSACTION); ok {
+				r.txRecord(tx, Frame{SEND, h.Clone(), b})
+			}
+		}
+		return e
+	})
+}
+
+/*
+	Subscribe to a STOMP subscription.  See Connection.Subscribe.
+
+	If the headers do not contain an id, one is generated, so that the
+	subscription can be reissued after a reconnect.  The returned channel is
+	closed only by Disconnect, or when reconnect attempts are exhausted.
+*/
+func (r *Reconnector) Subscribe(h Headers) (<-chan MessageData, error) {
+	return r.SubscribeContext(context.Background(), h)
+}
+
+/*
+	SubscribeContext subscribes to a STOMP subscription.  See Subscribe and
+	Connection.SubscribeContext.
+*/
+func (r *Reconnector) SubscribeContext(ctx context.Context, h Headers) (<-chan MessageData, error) {
+	ch := h.Clone()
+	if _, ok := ch.Contains(HK_ID); !ok {
+		ch = ch.Add(HK_ID, Uuid())
+	}
+	if _, ok := ch.Contains(HK_ACK); !ok {
+		ch = ch.Add(HK_ACK, AckModeAuto)
+	}
+	id := ch.Value(HK_ID)
+	for {
+		c, e := r.current(ctx)
+		if e != nil {
+			return nil, e
+		}
+		r.mu.Lock()
+		if r.gen == nil || r.gen.c != c { // Lost in the meantime
+			r.mu.Unlock()
+			continue
+		}
+		if _, ok := r.subs[id]; ok {
+			r.mu.Unlock()
+			return nil, EDUPSID
+		}
+		rs := &reconnectSub{h: ch, out: make(chan MessageData, r.scc),
+			stop: make(chan struct{})}
+		r.subs[id] = rs // From here on a reconnect restores it
+		r.mu.Unlock()
+		md, e := c.SubscribeContext(ctx, ch)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.gen == nil || r.gen.c != c { // Lost, the reconnect has it
+			return rs.out, nil
+		}
+		if e != nil {
+			delete(r.subs, id)
+			return nil, e
+		}
+		r.forward(r.gen, rs, md)
+		return rs.out, nil
+	}
+}
+
+/*
+	Unsubscribe from a STOMP subscription.  See Connection.Unsubscribe.
+*/
+func (r *Reconnector) Unsubscribe(h Headers) error {
+	return r.UnsubscribeContext(context.Background(), h)
+}
+
+/*
+	UnsubscribeContext unsubscribes from a STOMP subscription.  See
+	Connection.UnsubscribeContext.
+*/
+func (r *Reconnector) UnsubscribeContext(ctx context.Context, h Headers) error {
+	return r.do(ctx, func(c *Connection) error {
+		e := c.UnsubscribeContext(ctx, h)
+		if e == nil {
+			r.mu.Lock()
+			for id, rs := range r.subs {
+				if id == h.Value(HK_ID) || (h.Value(HK_ID) == "" &&
+					rs.h.Value(HK_DESTINATION) == h.Value(HK_DESTINATION)) {
+					close(rs.stop)
+					delete(r.subs, id)
+					break
+				}
+			}
+			r.mu.Unlock()
+		}
+		return e
+	})
+}
+
+/*
+	Ack a STOMP MESSAGE.  See Connection.Ack.
+*/
+func (r *Reconnector) Ack(h Headers) error {
+	return r.AckContext(context.Background(), h)
+}
+
+/*
+	AckContext acks a STOMP MESSAGE.  See Connection.AckContext.
+*/
+func (r *Reconnector) AckContext(ctx context.Context, h Headers) error {
+	return r.do(ctx, func(c *Connection) error {
+		return c.AckContext(ctx, h)
+	})
+}
+
+/*
+	Nack a STOMP MESSAGE.  See Connection.Nack.
+*/
+func (r *Reconnector) Nack(h Headers) error {
+	return r.NackContext(context.Background(), h)
+}
+
+/*
+	NackContext nacks a STOMP MESSAGE.  See Connection.NackContext.
+*/
+func (r *Reconnector) NackContext(ctx context.Context, h Headers) error {
+	return r.do(ctx, func(c *Connection) error {
+		return c.NackContext(ctx, h)
+	})
+}
+
+/*
+	Begin a STOMP transaction.  See Connection.Begin.
+*/
+func (r *Reconnector) Begin(h Headers) error {
+	return r.BeginContext(context.Background(), h)
+}
+
+/*
+	BeginContext begins a STOMP transaction.  See Connection.BeginContext.
+*/
+func (r *Reconnector) BeginContext(ctx context.Context, h Headers) error {
+	return r.do(ctx, func(c *Connection) error {
+		e := c.BeginContext(ctx, h)
+		if e == nil {
+			r.txRecord(h.Value(HK_TRANSACTION), Frame{BEGIN, h.Clone(), NULLBUFF})
+		}
+		return e
+	})
+}
+
+/*
+	Commit a STOMP transaction.  See Connection.Commit.
+*/
+func (r *Reconnector) Commit(h Headers) error {
+	return r.CommitContext(context.Background(), h)
+}
+
+/*
+	CommitContext commits a STOMP transaction.  See Connection.CommitContext.
+*/
+func (r *Reconnector) CommitContext(ctx context.Context, h Headers) error {
+	return r.do(ctx, func(c *Connection) error {
+		e := c.CommitContext(ctx, h)
+		if e == nil {
+			r.txEnd(h.Value(HK_TRANSACTION))
+		}
+		return e
+	})
+}
+
+/*
+	Abort a STOMP transaction.  See Connection.Abort.
+*/
+func (r *Reconnector) Abort(h Headers) error {
+	return r.AbortContext(context.Background(), h)
+}
+
+/*
+	AbortContext aborts a STOMP transaction.  See Connection.AbortContext.
+*/
+func (r *Reconnector) AbortContext(ctx context.Context, h Headers) error {
+	return r.do(ctx, func(c *Connection) error {
+		e := c.AbortContext(ctx, h)
+		if e == nil {
+			r.txEnd(h.Value(HK_TRANSACTION))
+		}
+		return e
+	})
+}
+
+/*
+	Disconnect from a STOMP broker, and stop reconnecting.  See
+	Connection.Disconnect.
+*/
+func (r *Reconnector) Disconnect(h Headers) error {
+	return r.DisconnectContext(context.Background(), h)
+}
+
+/*
+	DisconnectContext disconnects from a STOMP broker, and stops reconnecting.
+	All subscription channels, MessageData, and the Events channel are closed.
+	See Connection.DisconnectContext.
+*/
+func (r *Reconnector) DisconnectContext(ctx context.Context, h Headers) error {
+	r.cancel() // Stop any reconnect in progress
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ECONBAD
+	}
+	r.closed = true
+	g := r.gen
+	r.mu.Unlock()
+	var e error = ECONBAD
+	if g != nil {
+		// The monitor must not consume the DISCONNECT RECEIPT
+		close(g.smon)
+		g.mwg.Wait()
+		e = g.c.DisconnectContext(ctx, h)
+	}
+	r.finish()
+	return e
+}
+
+/*
+	Run an operation on the current physical connection.
+*/
+func (r *Reconnector) do(ctx context.Context, op func(c *Connection) error) error {
+	c, e := r.current(ctx)
+	if e != nil {
+		return e
+	}
+	return op(c)
+}
+
+/*
+	Get the current physical connection, waiting for any reconnect in progress.
+*/
+func (r *Reconnector) current(ctx context.Context) (*Connection, error) {
+	for {
+		r.mu.Lock()
+		g, rc, cl := r.gen, r.ready, r.closed
+		r.mu.Unlock()
+		if cl {
+			return nil, ECONBAD
+		}
+		if g != nil {
+			return g.c, nil
+		}
+		select {
+		case <-rc:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+/*
+	Record a frame for an open transaction.
+*/
+func (r *Reconnector) txRecord(tx string, f Frame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f.Command == BEGIN {
+		r.txs[tx] = []Frame{f}
+		return
+	}
+	if fl, ok := r.txs[tx]; ok {
+		r.txs[tx] = append(fl, f)
+	}
+}
+
+/*
+	Forget a transaction.
+*/
+func (r *Reconnector) txEnd(tx string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.txs, tx)
+}
+
+/*
+	Install a new physical connection.  Caller holds r.mu.
+*/
+func (r *Reconnector) install(c *Connection) {
+	g := &reconnectGen{c: c, done: make(chan struct{}),
+		smon: make(chan struct{})}
+	r.gen = g
+	close(r.ready)
+	g.mwg.Add(1)
+	go r.monitor(g)
+}
+
+/*
+	Start forwarding one subscription for one connection generation.  Caller
+	holds r.mu.
+*/
+func (r *Reconnector) forward(g *reconnectGen, rs *reconnectSub, md <-chan MessageData) {
+	r.fwg.Add(1)
+	go func() {
+		defer r.fwg.Done()
+		for {
+			select {
+			case d, ok := <-md:
+				if !ok || !r.deliver(rs, d) {
+					return
+				}
+			case <-rs.stop:
+				return
+			case <-g.done:
+				// Hand over anything already buffered, then quit
+				for {
+					select {
+					case d, ok := <-md:
+						if !ok || !r.deliver(rs, d) {
+							return
+						}
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+/*
+	Deliver one MessageData to a client subscription channel.  Connection
+	errors are not delivered, they are reported as events.  Returns false if
+	forwarding should stop.
+*/
+func (r *Reconnector) deliver(rs *reconnectSub, d MessageData) bool {
+	if d.Error != nil || d.Message.Command == "" {
+		return true
+	}
+	select {
+	case rs.out <- d:
+		return true
+	case <-rs.stop:
+		return false
+	case <-r.ctx.Done():
+		return false
+	}
+}
+
+/*
+	Watch one physical connection.  Forward RECEIPT and ERROR frames, and
+	start reconnecting when the connection is lost.
+*/
+func (r *Reconnector) monitor(g *reconnectGen) {
+	defer g.mwg.Done()
+	var le error
+monLoop:
+	for {
+		select {
+		case d, ok := <-g.c.MessageData:
+			if !ok {
+				break monLoop
+			}
+			if d.Error != nil {
+				le = d.Error
+				break monLoop
+			}
+			select {
+			case r.input <- d:
+			case <-g.smon:
+				return
+			}
+		case <-g.c.ssdc:
+			break monLoop
+		case <-g.smon:
+			return
+		}
+	}
+	// Make sure the reader is gone, so that anything it had already read has
+	// been handed to the forwarders.
+	_ = g.c.netconn.Close()
+	for range g.c.MessageData {
+	}
+	r.mu.Lock()
+	close(g.done)
+	r.gen = nil
+	r.ready = make(chan struct{})
+	cl := r.closed
+	r.mu.Unlock()
+	if cl {
+		return
+	}
+	r.event(ReconnectEvent{RE_DISCONNECTED, 0, le})
+	go r.reconnect()
+}
+
+/*
+	Reconnect, with backoff, and restore subscriptions and transactions.
+*/
+func (r *Reconnector) reconnect() {
+	d := r.cfg.minb
+	for at := 1; r.cfg.mxat == 0 || at <= r.cfg.mxat; at++ {
+		select {
+		case <-time.After(d):
+		case <-r.ctx.Done():
+			return
+		}
+		if d *= 2; d > r.cfg.maxb {
+			d = r.cfg.maxb
+		}
+		c, e := r.cf(r.ctx)
+		if e == nil {
+			e = r.restore(c)
+		}
+		if e == nil {
+			r.event(ReconnectEvent{RE_RECONNECTED, at, nil})
+			return
+		}
+		if r.ctx.Err() != nil {
+			return
+		}
+		r.event(ReconnectEvent{RE_RECONNECTFAILED, at, e})
+	}
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	r.mu.Unlock()
+	r.cancel()
+	r.finish()
+}
+
+/*
+	Restore subscriptions and transactions on a new physical connection, and
+	install it.
+*/
+func (r *Reconnector) restore(c *Connection) error {
+	// Operations wait for the new connection, so only what is copied here
+	// needs restoring.
+	r.mu.Lock()
+	cl, scc := r.closed, r.scc
+	subs := make(map[string]*reconnectSub, len(r.subs))
+	for id, rs := range r.subs {
+		subs[id] = rs
+	}
+	var txs [][]Frame
+	for _, fl := range r.txs {
+		txs = append(txs, append([]Frame(nil), fl...))
+	}
+	r.mu.Unlock()
+	if cl {
+		_ = c.Disconnect(NoDiscReceipt)
+		return ECONBAD
+	}
+	c.SetSubChanCap(scc)
+	mds := make(map[string]<-chan MessageData, len(subs))
+	for id, rs := range subs {
+		md, e := c.SubscribeContext(r.ctx, rs.h)
+		if e != nil {
+			_ = c.Disconnect(NoDiscReceipt)
+			return e
+		}
+		mds[id] = md
+	}
+	for _, fl := range txs {
+		for _, f := range fl {
+			if e := c.transmitFrame(r.ctx, Frame{f.Command, f.Headers.Clone(),
+				f.Body}, false); e != nil {
+				_ = c.Disconnect(NoDiscReceipt)
+				return e
+			}
+		}
+	}
+	r.mu.Lock()
+	if r.closed { // Disconnect in the meantime
+		r.mu.Unlock()
+		_ = c.Disconnect(NoDiscReceipt)
+		return ECONBAD
+	}
+	r.install(c)
+	for id, rs := range subs {
+		if r.subs[id] == rs {
+			r.forward(r.gen, rs, mds[id])
+		}
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+/*
+	Send an event, dropping it if the channel is full.
+*/
+func (r *Reconnector) event(ev ReconnectEvent) {
+	select {
+	case r.events <- ev:
+	default:
+	}
+}
+
+/*
+	Final shutdown.  Close all client facing channels.
+*/
+func (r *Reconnector) finish() {
+	r.mu.Lock()
+	if g := r.gen; g != nil {
+		close(g.done)
+		r.gen = nil
+	} else {
+		close(r.ready) // Release any waiters, they will see closed
+	}
+	r.mu.Unlock()
+	r.fwg.Wait()
+	r.mu.Lock()
+	for id, rs := range r.subs {
+		close(rs.out)
+		delete(r.subs, id)
+	}
+	r.mu.Unlock()
+	r.event(ReconnectEvent{RE_CLOSED, 0, nil})
+	close(r.events)
+	close(r.input)
+}
@@ -0,0 +1,187 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+/*
+	Test Reconnector: subscriptions and open transactions are restored after
+	the broker drops the connection, and MESSAGE frames continue to arrive on
+	the original channel.
+*/
+func TestReconnectReplay(t *testing.T) {
+	var nc int32
+	fc := make(chan []string, 2) // Frames seen per broker connection
+	hap := listenBroker(t, nil, func(pb *pipe_broker) {
+		cn := atomic.AddInt32(&nc, 1)
+		var fl []string
+		for i := 0; i < 4; i++ { // CONNECT, SUBSCRIBE, BEGIN, SEND
+			f, e := pb.readFrame()
+			if e != nil {
+				return
+			}
+			fl = append(fl, f)
+			if i == 0 {
+				_, _ = pb.n.Write([]byte(ctxConnected))
+			}
+		}
+		fc <- fl
+		id := rawHeader(fl[1], HK_ID)
+		mf := MESSAGE + "\n" + HK_SUBSCRIPTION + ":" + id + "\n" +
+			HK_MESSAGE_ID + ":m" + string('0'+cn) + "\n\nbody\x00"
+		_, _ = pb.n.Write([]byte(mf))
+		if cn == 2 {
+			_, _ = pb.readFrame() // DISCONNECT
+		}
+	})
+	ctx, cf := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cf()
+	r, e := NewReconnector(ctx, DialConnectFunc("stomp://"+hap, ctxHeaders),
+		ReconnectBackoff(10*time.Millisecond, 50*time.Millisecond))
+	if e != nil {
+		t.Fatalf("TestReconnectReplay Expected [nil], got [%v]\n", e)
+	}
+	sc, e := r.Subscribe(Headers{HK_DESTINATION, "/queue/reconnect",
+		HK_ACK, AckModeClientIndividual})
+	if e != nil {
+		t.Fatalf("TestReconnectReplay Subscribe expected [nil], got [%v]\n", e)
+	}
+	if e = r.Begin(Headers{HK_TRANSACTION, "tx1"}); e != nil {
+		t.Fatalf("TestReconnectReplay Begin expected [nil], got [%v]\n", e)
+	}
+	e = r.Send(Headers{HK_DESTINATION, "/queue/reconnect.out",
+		HK_TRANSACTION, "tx1"}, tm)
+	if e != nil {
+		t.Fatalf("TestReconnectReplay Send expected [nil], got [%v]\n", e)
+	}
+	//
+	for _, wid := range []string{"m1", "m2"} {
+		select {
+		case md := <-sc:
+			if md.Message.Headers.Value(HK_MESSAGE_ID) != wid {
+				t.Fatalf("TestReconnectReplay Expected [%s], got [%v]\n", wid, md)
+			}
+		case <-ctx.Done():
+			t.Fatalf("TestReconnectReplay Timeout waiting for [%s]\n", wid)
+		}
+	}
+	f1, f2 := <-fc, <-fc
+	for i := 1; i < 4; i++ {
+		if f1[i] != f2[i] {
+			t.Fatalf("TestReconnectReplay Expected replay [%q], got [%q]\n",
+				f1[i], f2[i])
+		}
+	}
+	if !strings.HasPrefix(f2[2], BEGIN+"\n") ||
+		rawHeader(f2[3], HK_TRANSACTION) != "tx1" {
+		t.Fatalf("TestReconnectReplay Expected transaction replay, got [%q]\n", f2)
+	}
+	if ev := <-r.Events(); ev.Type != RE_DISCONNECTED {
+		t.Fatalf("TestReconnectReplay Expected [%s], got [%v]\n",
+			RE_DISCONNECTED, ev)
+	}
+	if ev := <-r.Events(); ev.Type != RE_RECONNECTED {
+		t.Fatalf("TestReconnectReplay Expected [%s], got [%v]\n",
+			RE_RECONNECTED, ev)
+	}
+	//
+	if e = r.Disconnect(NoDiscReceipt); e != nil {
+		t.Fatalf("TestReconnectReplay Disconnect expected [nil], got [%v]\n", e)
+	}
+	if _, ok := <-sc; ok {
+		t.Fatalf("TestReconnectReplay Expected closed subscription channel\n")
+	}
+}
+
+/*
+	Test Reconnector when reconnect attempts are exhausted.
+*/
+func TestReconnectGiveUp(t *testing.T) {
+	var nc int32
+	hap := listenBroker(t, nil, func(pb *pipe_broker) {
+		if atomic.AddInt32(&nc, 1) > 1 {
+			return // Refuse all reconnects
+		}
+		_, _ = pb.readFrame()
+		_, _ = pb.n.Write([]byte(ctxConnected))
+	})
+	r, e := NewReconnector(context.Background(),
+		DialConnectFunc("stomp://"+hap, ctxHeaders),
+		ReconnectBackoff(time.Millisecond, time.Millisecond),
+		ReconnectMaxAttempts(2))
+	if e != nil {
+		t.Fatalf("TestReconnectGiveUp Expected [nil], got [%v]\n", e)
+	}
+	var tl []string
+	for ev := range r.Events() {
+		tl = append(tl, ev.Type)
+	}
+	wl := []string{RE_DISCONNECTED, RE_RECONNECTFAILED, RE_RECONNECTFAILED,
+		RE_CLOSED}
+	if strings.Join(tl, ",") != strings.Join(wl, ",") {
+		t.Fatalf("TestReconnectGiveUp Expected [%v], got [%v]\n", wl, tl)
+	}
+	if e = r.Send(Headers{HK_DESTINATION, "/queue/gone"}, tm); e != ECONBAD {
+		t.Fatalf("TestReconnectGiveUp Expected [%v], got [%v]\n", ECONBAD, e)
+	}
+}
+
+/*
+	Test Reconnector calls are not blocked by a SUBSCRIBE in progress.
+*/
+func TestReconnectSubscribeUnlocked(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Pause(closePause).Expect(SUBSCRIBE).
+		Expect(DISCONNECT).Receipt().WaitClose()
+	n, rc := s.Pipe()
+	r, e := NewReconnector(context.Background(),
+		func(ctx context.Context) (*Connection, error) {
+			return Connect(n, ctxHeaders)
+		})
+	if e != nil {
+		t.Fatalf("TestReconnectSubscribeUnlocked Expected [nil], got [%v]\n", e)
+	}
+	sr := make(chan error, 1)
+	go func() {
+		_, e := r.Subscribe(Headers{HK_DESTINATION, "/queue/slow"})
+		sr <- e
+	}()
+	time.Sleep(closePause / 4) // SUBSCRIBE write in progress
+	st := time.Now()
+	_ = r.SubChanCap()
+	if d := time.Since(st); d > closePause/4 {
+		t.Fatalf("TestReconnectSubscribeUnlocked Expected no wait, got [%v]\n", d)
+	}
+	if e = <-sr; e != nil {
+		t.Fatalf("TestReconnectSubscribeUnlocked Expected [nil], got [%v]\n", e)
+	}
+	if e = r.Disconnect(empty_headers); e != nil {
+		t.Fatalf("TestReconnectSubscribeUnlocked Expected [nil], got [%v]\n", e)
+	}
+	_ = n.Close()
+	if se := <-rc; se != nil {
+		t.Fatalf("TestReconnectSubscribeUnlocked Script error [%v]\n", se)
+	}
+}
@@ -0,0 +1,385 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+)
+
+/*
+	ReplyStyle selects how Request receives replies, by broker convention.
+*/
+type ReplyStyle int32
+
+const (
+	// Choose from the CONNECTED server header: ReplyRabbitMQ for RabbitMQ,
+	// ReplyArtemis for ActiveMQ Artemis, and ReplyActiveMQ otherwise.  The
+	// default.
+	ReplyAuto ReplyStyle = iota
+
+	// SUBSCRIBE to a /temp-queue/ destination, which the broker maps to a
+	// temporary queue, and rewrites in the reply-to header.  ActiveMQ Classic.
+	ReplyActiveMQ
+
+	// Send a /temp-queue/ reply-to with no SUBSCRIBE.  The broker creates and
+	// subscribes to a temporary queue, rewrites the reply-to header, and
+	// delivers replies with the /temp-queue/ destination as the subscription.
+	ReplyRabbitMQ
+
+	// SUBSCRIBE to a uniquely named ANYCAST queue, which the broker creates,
+	// and deletes once unused.  ActiveMQ Artemis.
+	ReplyArtemis
+)
+
+/*
+	Reply destination prefixes, by ReplyStyle.
+*/
+const (
+	replyTempQueue = "/temp-queue/stompngo-"
+	replyArtemis   = "stompngo.reply."
+)
+
+/*
+	ReplyFunc processes a single request for Respond.  It returns the headers
+	and body of the reply, or an error, in which case no reply is sent.
+*/
+type ReplyFunc func(ctx context.Context, m *Message) (Headers, []byte, error)
+
+/*
+	Responder is a subscription created by Respond.
+*/
+type Responder struct {
+	*FuncSubscription
+}
+
+/*
+	The shared reply subscription for a Connection's Requests.
+*/
+type replier struct {
+	c    *Connection
+	sd   *subscription
+	dest string                   // reply-to
+	lock sync.Mutex               // wtrs and err lock
+	wtrs map[string]chan *Message // Waiting Requests, by correlation-id
+	err  error                    // Why replies stopped, nil while running
+}
+
+/*
+	SetReplyStyle sets the reply destination convention used by future
+	Requests.  The default is ReplyAuto.  See ReplyStyle.
+*/
+func (c *Connection) SetReplyStyle(s ReplyStyle) {
+	c.rsty.Store(int32(s))
+}
+
+/*
+	Request sends a STOMP MESSAGE, and waits for the reply.
+
+	Headers MUST contain a "destination" header key, and must not contain
+	correlation-id or reply-to, which Request sets.  The message body is a
+	string, as with Send.
+
+	All Requests on a Connection share one reply subscription, created by
+	the first Request according to the ReplyStyle, and kept until the
+	connection shuts down.  Replies are matched to Requests by correlation-id.
+	A reply that arrives after its Request has returned is discarded.
+
+	If ctx is done first, ctx.Err() is returned.  If the reply subscription
+	fails, the connection error is returned.
+
+	Example:
+		h := stompngo.Headers{stompngo.HK_DESTINATION, "/queue/quote"}
+		ctx, cf := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cf()
+		r, e := c.Request(ctx, h, "GOOG")
+		if e != nil {
+			// Do something sane ...
+		}
+		// Use r.Body
+*/
+func (c *Connection) Request(ctx context.Context, h Headers, b string) (*Message, error) {
+	if h == nil {
+		return nil, EHDRNIL
+	}
+	if !c.isConnected() {
+		return nil, ECONBAD
+	}
+	_, cok := h.Contains(HK_CORRELATION_ID)
+	_, rok := h.Contains(HK_REPLY_TO)
+	if cok || rok {
+		return nil, ERPCHDR
+	}
+	r, e := c.replier(ctx)
+	if e != nil {
+		return nil, e
+	}
+	id := Uuid()
+	rc, e := r.wait(id)
+	if e != nil {
+		return nil, e
+	}
+	defer r.cancel(id)
+	ch := h.Clone().Add(HK_CORRELATION_ID, id).Add(HK_REPLY_TO, r.dest)
+	if e = c.SendContext(ctx, ch, b); e != nil {
+		return nil, e
+	}
+	select {
+	case m, ok := <-rc:
+		if !ok {
+			return nil, r.stopped()
+		}
+		return m, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+/*
+	Respond subscribes to a STOMP subscription, and serves each MESSAGE
+	received as a request.  Headers and options are as for SubscribeFunc.
+
+	f is called for each request.  Unless f returns an error, its reply is
+	sent to the request reply-to destination, as given by the broker, with
+	the request correlation-id, or the request message-id if there is none.
+	A request without a reply-to header gets no reply.  An error returned by
+	f, or by the reply SEND, is handled as a SubscribeFunc handler error.
+
+	Example:
+		h := stompngo.Headers{stompngo.HK_DESTINATION, "/queue/quote"}
+		r, e := c.Respond(h, func(ctx context.Context, m *stompngo.Message) (stompngo.Headers, []byte, error) {
+			return nil, quote(m.Body), nil
+		}, stompngo.SubWorkers(4))
+		if e != nil {
+			// Do something sane ...
+		}
+		...
+		e = r.Unsubscribe(ctx)
+*/
+func (c *Connection) Respond(h Headers, f ReplyFunc,
+	opts ...SubscribeFuncOption) (*Responder, error) {
+	fs, e := c.SubscribeFunc(h, func(ctx context.Context, m *Message) error {
+		rh, rb, e := f(ctx, m)
+		if e != nil {
+			return e
+		}
+		rt, ok := m.Headers.Contains(HK_REPLY_TO)
+		if !ok {
+			return nil
+		}
+		id, ok := m.Headers.Contains(HK_CORRELATION_ID)
+		if !ok {
+			id = m.Headers.Value(HK_MESSAGE_ID)
+		}
+		rh = rh.Delete(HK_DESTINATION).Delete(HK_CORRELATION_ID).
+			Add(HK_DESTINATION, rt).Add(HK_CORRELATION_ID, id)
+		return c.SendBytesContext(ctx, rh, rb)
+	}, opts...)
+	if e != nil {
+		return nil, e
+	}
+	return &Responder{fs}, nil
+}
+
+/*
+	The reply destination convention in use.
+*/
+func (c *Connection) replyStyle() ReplyStyle {
+	if s := ReplyStyle(c.rsty.Load()); s != ReplyAuto {
+		return s
+	}
+	sv := ""
+	if c.ConnectResponse != nil {
+		sv = c.ConnectResponse.Headers.Value(HK_SERVER)
+	}
+	switch {
+	case strings.HasPrefix(sv, "RabbitMQ"):
+		return ReplyRabbitMQ
+	case strings.Contains(sv, "Artemis"):
+		return ReplyArtemis
+	}
+	return ReplyActiveMQ
+}
+
+/*
+	The Connection replier, set up by the first Request, and again by the
+	next Request after it stops.  The reply subscription is made without
+	holding rpcLock, and concurrent Requests wait for it, or for ctx.
+*/
+func (c *Connection) replier(ctx context.Context) (*replier, error) {
+	for {
+		c.rpcLock.Lock()
+		if r := c.rpc; r != nil {
+			c.rpcLock.Unlock()
+			return r, nil
+		}
+		if w := c.rpcw; w != nil { // Being set up
+			c.rpcLock.Unlock()
+			select {
+			case <-w:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		w := make(chan struct{})
+		c.rpcw = w
+		c.rpcLock.Unlock()
+		r, e := c.newReplier(ctx)
+		c.rpcLock.Lock()
+		c.rpc, c.rpcw = r, nil
+		c.rpcLock.Unlock()
+		close(w)
+		if e != nil {
+			return nil, e
+		}
+		go r.run()
+		return r, nil
+	}
+}
+
+/*
+	Subscribe to a new reply destination.
+*/
+func (c *Connection) newReplier(ctx context.Context) (*replier, error) {
+	var sd *subscription
+	var dest string
+	var e error
+	switch c.replyStyle() {
+	case ReplyRabbitMQ:
+		dest = replyTempQueue + Uuid()
+		sd, e, _ = c.establishSubscription(Headers{HK_DESTINATION, dest,
+			HK_ID, dest, HK_ACK, AckModeAuto})
+		if e == nil {
+			c.subsLock.Lock()
+			sd.brkr = true
+			c.subsLock.Unlock()
+		}
+	case ReplyArtemis:
+		dest = replyArtemis + Uuid()
+		sd, _, e = c.subscribe(ctx, Headers{HK_DESTINATION, dest,
+			"subscription-type", "ANYCAST"}, false)
+	default:
+		dest = replyTempQueue + Uuid()
+		sd, _, e = c.subscribe(ctx, Headers{HK_DESTINATION, dest}, false)
+	}
+	if e != nil {
+		return nil, e
+	}
+	return &replier{c: c, sd: sd, dest: dest, wtrs: make(map[string]chan *Message)}, nil
+}
+
+/*
+	Pass replies to the waiting Requests, until the reply subscription ends.
+*/
+func (r *replier) run() {
+	for {
+		select {
+		case md, ok := <-r.sd.md:
+			if !ok { // Connection shutdown
+				r.stop(ECONBAD)
+				return
+			}
+			if md.Error != nil { // Connection failure
+				r.stop(md.Error)
+				return
+			}
+			if md.Stream != nil { // Requests see the whole body
+				b, e := io.ReadAll(md.Stream)
+				_ = md.Stream.Close()
+				if e != nil {
+					r.c.log("REQUEST", "reply body", e)
+					continue
+				}
+				md.Message.Body = b
+			}
+			r.deliver(&md.Message)
+		case <-r.sd.uc: // Unsubscribed
+			r.stop(ECONBAD)
+			return
+		}
+	}
+}
+
+/*
+	Register a Request waiting for a reply.
+*/
+func (r *replier) wait(id string) (<-chan *Message, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	rc := make(chan *Message, 1)
+	r.wtrs[id] = rc
+	return rc, nil
+}
+
+/*
+	Forget a Request.
+*/
+func (r *replier) cancel(id string) {
+	r.lock.Lock()
+	delete(r.wtrs, id)
+	r.lock.Unlock()
+}
+
+/*
+	Pass a reply to its Request, if still waiting.
+*/
+func (r *replier) deliver(m *Message) {
+	id := m.Headers.Value(HK_CORRELATION_ID)
+	r.lock.Lock()
+	rc, ok := r.wtrs[id]
+	delete(r.wtrs, id)
+	r.lock.Unlock()
+	if !ok {
+		r.c.log("REQUEST", "reply discarded", id)
+		return
+	}
+	rc <- m
+}
+
+/*
+	Stop replies, failing every waiting Request, and detach from the
+	Connection.
+*/
+func (r *replier) stop(e error) {
+	r.c.rpcLock.Lock()
+	if r.c.rpc == r { // The next Request sets up another
+		r.c.rpc = nil
+	}
+	r.c.rpcLock.Unlock()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.err = e
+	for id, rc := range r.wtrs {
+		close(rc)
+		delete(r.wtrs, id)
+	}
+}
+
+/*
+	Why replies stopped.
+*/
+func (r *replier) stopped() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
@@ -0,0 +1,283 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+/*
+	Test concurrent Requests share one reply subscription, and each gets its
+	own reply from a Responder.
+*/
+func TestRequestRespond(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	cr, e := Connect(b.Pipe(), ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestRequestRespond Expected [nil], got [%v]\n", e)
+	}
+	r, e := cr.Respond(Headers{HK_DESTINATION, rpcDest},
+		func(ctx context.Context, m *Message) (Headers, []byte, error) {
+			return Headers{HK_CONTENT_TYPE, "text/plain"},
+				[]byte(strings.ToUpper(m.BodyString())), nil
+		}, SubWorkers(2))
+	if e != nil {
+		t.Fatalf("TestRequestRespond Expected [nil], got [%v]\n", e)
+	}
+	c, e := Connect(b.Pipe(), ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestRequestRespond Expected [nil], got [%v]\n", e)
+	}
+	if _, e = c.Request(context.Background(), Headers{HK_DESTINATION, rpcDest,
+		HK_REPLY_TO, rpcDest}, "x"); e != ERPCHDR {
+		t.Fatalf("TestRequestRespond Expected [%v], got [%v]\n", ERPCHDR, e)
+	}
+	ctx, cf := context.WithTimeout(context.Background(), rpcWait)
+	defer cf()
+	var wg sync.WaitGroup
+	errs := make(chan error, rpcRequests)
+	for i := 0; i < rpcRequests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b := fmt.Sprintf("request %d", i)
+			m, e := c.Request(ctx, Headers{HK_DESTINATION, rpcDest}, b)
+			if e != nil {
+				errs <- e
+				return
+			}
+			if m.BodyString() != strings.ToUpper(b) {
+				errs <- fmt.Errorf("reply [%s] for [%s]", m.BodyString(), b)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Fatalf("TestRequestRespond Expected [nil], got [%v]\n", e)
+	}
+	if n := len(c.subs); n != 1 {
+		t.Fatalf("TestRequestRespond Expected [1] subscription, got [%d]\n", n)
+	}
+	if e = r.Unsubscribe(ctx); e != nil {
+		t.Fatalf("TestRequestRespond Expected [nil], got [%v]\n", e)
+	}
+	_ = c.Disconnect(empty_headers)
+	_ = cr.Disconnect(empty_headers)
+}
+
+/*
+	Test a RabbitMQ style Request sends no SUBSCRIBE, and takes the reply
+	delivered with the /temp-queue/ destination as the subscription.
+*/
+func TestRequestRabbitMQ(t *testing.T) {
+	n, bn := net.Pipe()
+	bc := make(chan error, 1)
+	go func() {
+		bc <- rpcRabbitBroker(bn)
+	}()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestRequestRabbitMQ Expected [nil], got [%v]\n", e)
+	}
+	ctx, cf := context.WithTimeout(context.Background(), rpcWait)
+	defer cf()
+	m, e := c.Request(ctx, Headers{HK_DESTINATION, rpcDest}, "ping")
+	if e != nil {
+		t.Fatalf("TestRequestRabbitMQ Expected [nil], got [%v]\n", e)
+	}
+	if m.BodyString() != "pong" {
+		t.Fatalf("TestRequestRabbitMQ Expected [pong], got [%s]\n", m.BodyString())
+	}
+	if e = c.Close(ctx, CloseUnsubscribe()); e != nil {
+		t.Fatalf("TestRequestRabbitMQ Expected [nil], got [%v]\n", e)
+	}
+	_ = n.Close()
+	if e = <-bc; e != nil {
+		t.Fatalf("TestRequestRabbitMQ Broker error [%v]\n", e)
+	}
+}
+
+/*
+	Test a Request waiting for another to set up the replier honors its ctx,
+	rather than waiting for the reply SUBSCRIBE.
+*/
+func TestRequestReplierUnlocked(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Pause(closePause).Expect(SUBSCRIBE).Expect(SEND).
+		Expect(DISCONNECT).Receipt().WaitClose()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestRequestReplierUnlocked Expected [nil], got [%v]\n", e)
+	}
+	ra := make(chan error, 1)
+	go func() {
+		ctx, cf := context.WithTimeout(context.Background(), 2*closePause)
+		defer cf()
+		_, e := c.Request(ctx, Headers{HK_DESTINATION, rpcDest}, "slow")
+		ra <- e
+	}()
+	time.Sleep(closePause / 4) // SUBSCRIBE write in progress
+	ctx, cf := context.WithTimeout(context.Background(), closePause/4)
+	defer cf()
+	st := time.Now()
+	_, e = c.Request(ctx, Headers{HK_DESTINATION, rpcDest}, "fast")
+	if e != context.DeadlineExceeded {
+		t.Fatalf("TestRequestReplierUnlocked Expected [%v], got [%v]\n",
+			context.DeadlineExceeded, e)
+	}
+	if d := time.Since(st); d > closePause/2 {
+		t.Fatalf("TestRequestReplierUnlocked Expected no wait, got [%v]\n", d)
+	}
+	if e = <-ra; e != context.DeadlineExceeded {
+		t.Fatalf("TestRequestReplierUnlocked Expected [%v], got [%v]\n",
+			context.DeadlineExceeded, e)
+	}
+	if e = c.Disconnect(empty_headers); e != nil {
+		t.Fatalf("TestRequestReplierUnlocked Expected [nil], got [%v]\n", e)
+	}
+	_ = n.Close()
+	if se := <-rc; se != nil {
+		t.Fatalf("TestRequestReplierUnlocked Script error [%v]\n", se)
+	}
+}
+
+/*
+	Test a Request after the reply subscription ends sets up a new one.
+*/
+func TestRequestReplierRestart(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	cr, e := Connect(b.Pipe(), ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestRequestReplierRestart Expected [nil], got [%v]\n", e)
+	}
+	_, e = cr.Respond(Headers{HK_DESTINATION, rpcDest},
+		func(ctx context.Context, m *Message) (Headers, []byte, error) {
+			return nil, m.Body, nil
+		})
+	if e != nil {
+		t.Fatalf("TestRequestReplierRestart Expected [nil], got [%v]\n", e)
+	}
+	c, e := Connect(b.Pipe(), ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestRequestReplierRestart Expected [nil], got [%v]\n", e)
+	}
+	ctx, cf := context.WithTimeout(context.Background(), rpcWait)
+	defer cf()
+	var last *replier
+	for i := 0; i < 2; i++ {
+		if _, e = c.Request(ctx, Headers{HK_DESTINATION, rpcDest}, "again"); e != nil {
+			t.Fatalf("TestRequestReplierRestart Expected [nil], got [%v]\n", e)
+		}
+		c.rpcLock.Lock()
+		r := c.rpc
+		c.rpcLock.Unlock()
+		if r == nil || r == last {
+			t.Fatalf("TestRequestReplierRestart Expected a new replier\n")
+		}
+		last = r
+		if e = c.Unsubscribe(Headers{HK_ID, r.sd.id}); e != nil {
+			t.Fatalf("TestRequestReplierRestart Expected [nil], got [%v]\n", e)
+		}
+		for r.stopped() == nil {
+			select {
+			case <-ctx.Done():
+				t.Fatalf("TestRequestReplierRestart Expected the replier stopped\n")
+			case <-time.After(poolCheck):
+			}
+		}
+	}
+	_ = c.Disconnect(empty_headers)
+	_ = cr.Disconnect(empty_headers)
+}
+
+/*
+	Test the ReplyStyle chosen from the CONNECTED server header.
+*/
+func TestReplyStyle(t *testing.T) {
+	for _, tc := range []struct {
+		server string
+		want   ReplyStyle
+	}{
+		{rpcRabbit, ReplyRabbitMQ},
+		{"ActiveMQ-Artemis/2.37.0 ActiveMQ Artemis Messaging Engine", ReplyArtemis},
+		{"ActiveMQ/6.1.2", ReplyActiveMQ},
+		{"", ReplyActiveMQ},
+	} {
+		c := &Connection{ConnectResponse: &Message{CONNECTED,
+			Headers{HK_SERVER, tc.server}, NULLBUFF}}
+		if s := c.replyStyle(); s != tc.want {
+			t.Fatalf("TestReplyStyle [%s] Expected [%d], got [%d]\n",
+				tc.server, tc.want, s)
+		}
+		c.SetReplyStyle(ReplyArtemis)
+		if s := c.replyStyle(); s != ReplyArtemis {
+			t.Fatalf("TestReplyStyle Expected [%d], got [%d]\n", ReplyArtemis, s)
+		}
+	}
+}
+
+/*
+	A broker that answers one request as RabbitMQ does, then a DISCONNECT.
+*/
+func rpcRabbitBroker(n net.Conn) error {
+	defer n.Close()
+	fr, fw := NewFrameReader(n, DefaultReadLimits), NewFrameWriter(n)
+	if _, e := fr.ReadFrame(); e != nil {
+		return e
+	}
+	fr.SetProtocol(SPL_12)
+	fw.SetProtocol(SPL_12)
+	e := fw.WriteFrame(Frame{CONNECTED, Headers{HK_VERSION, SPL_12,
+		HK_SERVER, rpcRabbit}, NULLBUFF})
+	if e != nil {
+		return e
+	}
+	f, e := fr.ReadFrame()
+	if e != nil {
+		return e
+	}
+	rt := f.Headers.Value(HK_REPLY_TO)
+	if f.Command != SEND || !strings.HasPrefix(rt, "/temp-queue/") {
+		return fmt.Errorf("unexpected %s, reply-to [%s]", f.Command, rt)
+	}
+	e = fw.WriteFrame(Frame{MESSAGE, Headers{HK_DESTINATION, "/reply-queue/amq.gen-rpc",
+		HK_SUBSCRIPTION, rt, HK_MESSAGE_ID, "T_" + rt,
+		HK_CORRELATION_ID, f.Headers.Value(HK_CORRELATION_ID)}, []byte("pong")})
+	if e != nil {
+		return e
+	}
+	if f, e = fr.ReadFrame(); e != nil {
+		return e
+	}
+	if f.Command != DISCONNECT {
+		return fmt.Errorf("unexpected %s", f.Command)
+	}
+	return fw.WriteFrame(Frame{RECEIPT, Headers{HK_RECEIPT_ID,
+		f.Headers.Value(HK_RECEIPT)}, NULLBUFF})
+}
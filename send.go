@@ -16,6 +16,10 @@
 
 package stompngo
 
+import (
+	"context"
+)
+
 /*
 	Send a STOMP MESSAGE.
 
@@ -33,7 +37,25 @@ package stompngo
 
 */
 func (c *Connection) Send(h Headers, b string) error {
-	c.log(SEND, "start", h)
+	return c.SendContext(context.Background(), h, b)
+}
+
+/*
+	SendContext sends a STOMP MESSAGE, honoring ctx cancellation and deadlines
+	while the frame is handed to the writer and put on the wire.
+
+	If ctx is done first, ctx.Err() is returned.  See Send for details.
+*/
+func (c *Connection) SendContext(ctx context.Context, h Headers, b string) error {
+	return c.send(ctx, h, b, false)
+}
+
+/*
+	Send, waiting for the broker's RECEIPT if rcpt is set.
+*/
+func (c *Connection) send(ctx context.Context, h Headers, b string,
+	rcpt bool) error {
+	c.logcmd(SEND, "start", h)
 	if !c.isConnected() {
 		return ECONBAD
 	}
@@ -44,13 +66,21 @@ func (c *Connection) Send(h Headers, b string) error {
 	if _, ok := h.Contains(HK_DESTINATION); !ok {
 		return EREQDSTSND
 	}
-	ch := h.Clone()
+	ch, end := c.traceSend(ctx, h.Clone())
 	f := Frame{SEND, ch, []uint8(b)}
-	r := make(chan error)
-	if e = c.writeWireData(wiredata{f, r}); e != nil {
-		return e
-	}
-	e = <-r
-	c.log(SEND, "end", ch)
+	e = c.transmitFrame(ctx, f, rcpt)
+	end(e)
+	c.logcmd(SEND, "end", ch)
 	return e // nil or not
 }
+
+/*
+	SendWithReceipt sends a STOMP MESSAGE, and waits for the broker's RECEIPT.
+
+	A receipt header is added unless the caller supplies one.  A matching
+	ERROR frame is returned as a *BrokerError.  If ctx is done first,
+	ctx.Err() is returned.  See Send for details.
+*/
+func (c *Connection) SendWithReceipt(ctx context.Context, h Headers, b string) error {
+	return c.send(ctx, h, b, true)
+}
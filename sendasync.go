@@ -0,0 +1,125 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+)
+
+/*
+	SendFuture is the pending result of SendAsync.
+*/
+type SendFuture struct {
+	done chan struct{}
+	err  error
+	end  func(error) // Tracer end
+}
+
+/*
+	Done returns a channel closed when the frame has been written, or has
+	failed.
+*/
+func (sf *SendFuture) Done() <-chan struct{} {
+	return sf.done
+}
+
+/*
+	Err waits for the frame to be written, and returns the result.
+*/
+func (sf *SendFuture) Err() error {
+	<-sf.done
+	return sf.err
+}
+
+/*
+	Wait waits for the frame to be written, or ctx to be done, and returns
+	the result.  The send is not canceled if ctx is done first.
+*/
+func (sf *SendFuture) Wait(ctx context.Context) error {
+	select {
+	case <-sf.done:
+		return sf.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+	Set the result.
+*/
+func (sf *SendFuture) complete(e error) {
+	sf.err = e
+	sf.end(e)
+	close(sf.done)
+}
+
+/*
+	SendAsync sends a STOMP MESSAGE without waiting for the wire write.  It
+	returns once the writer accepts the frame, and the returned SendFuture
+	reports the result.  Frames are written in the order accepted.
+
+	Use SetWriteBatch to let the writer put many frames on the wire with one
+	flush.  ctx applies until the frame is written; if it is done first, the
+	result is ctx.Err().  Receipts are not waited for.  See Send for details.
+
+	Example:
+		fs := make([]*stompngo.SendFuture, 0, len(msgs))
+		for _, m := range msgs {
+			fs = append(fs, c.SendAsync(ctx, h, m))
+		}
+		for _, f := range fs {
+			if e := f.Err(); e != nil {
+				// Do something sane ...
+			}
+		}
+
+*/
+func (c *Connection) SendAsync(ctx context.Context, h Headers, b string) *SendFuture {
+	sf := &SendFuture{done: make(chan struct{}), end: func(error) {}}
+	c.logcmd(SEND, "start", h)
+	if !c.isConnected() {
+		sf.complete(ECONBAD)
+		return sf
+	}
+	if e := checkHeaders(h, c.Protocol()); e != nil {
+		sf.complete(e)
+		return sf
+	}
+	if _, ok := h.Contains(HK_DESTINATION); !ok {
+		sf.complete(EREQDSTSND)
+		return sf
+	}
+	if e := c.opBegin(ctx, SEND); e != nil {
+		sf.complete(e)
+		return sf
+	}
+	ch, end := c.traceSend(ctx, h.Clone())
+	sf.end = func(e error) {
+		end(e)
+		c.opEnd()
+	}
+	f := Frame{SEND, ch, []uint8(b)}
+	select {
+	case c.output <- wiredata{f, nil, ctx, nil, sf}:
+	case <-c.ssdc:
+		sf.complete(ECONBAD)
+	case <-ctx.Done():
+		sf.complete(ctx.Err())
+	}
+	c.logcmd(SEND, "end", ch)
+	return sf
+}
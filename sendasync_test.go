@@ -0,0 +1,119 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+/*
+	A net.Conn counting network writes.
+*/
+type writeCountConn struct {
+	net.Conn
+	w atomic.Int32
+}
+
+func (c *writeCountConn) Write(b []byte) (int, error) {
+	c.w.Add(1)
+	return c.Conn.Write(b)
+}
+
+/*
+	Test that SendAsync frames are written in order, with batched flushes.
+*/
+func TestSendAsyncBatch(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected)
+	for i := 0; i < sendAsyncCount; i++ {
+		s = s.Expect(SEND, HK_DESTINATION, sendAsyncDest+strconv.Itoa(i))
+	}
+	s = s.Expect(DISCONNECT).Receipt().WaitClose()
+	n, rc := s.Pipe()
+	wc := &writeCountConn{Conn: n}
+	c, e := Connect(wc, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestSendAsyncBatch Expected [nil], got [%v]\n", e)
+	}
+	c.SetWriteBatch(sendAsyncBatch, sendAsyncLinger)
+	wc.w.Store(0)
+	fs := make([]*SendFuture, 0, sendAsyncCount)
+	for i := 0; i < sendAsyncCount; i++ {
+		h := Headers{HK_DESTINATION, sendAsyncDest + strconv.Itoa(i)}
+		fs = append(fs, c.SendAsync(context.Background(), h, "async"))
+	}
+	for i, f := range fs {
+		if e = f.Err(); e != nil {
+			t.Fatalf("TestSendAsyncBatch[%d] Expected [nil], got [%v]\n", i, e)
+		}
+	}
+	if w := wc.w.Load(); w >= sendAsyncCount/2 {
+		t.Fatalf("TestSendAsyncBatch Expected fewer than [%d] writes, got [%d]\n",
+			sendAsyncCount/2, w)
+	}
+	if e = c.Disconnect(empty_headers); e != nil {
+		t.Fatalf("TestSendAsyncBatch Expected [nil], got [%v]\n", e)
+	}
+	f := c.SendAsync(context.Background(), Headers{HK_DESTINATION, sendAsyncDest}, "late")
+	if e = f.Err(); e != ECONBAD {
+		t.Fatalf("TestSendAsyncBatch Expected [%v], got [%v]\n", ECONBAD, e)
+	}
+	_ = n.Close()
+	if se := <-rc; se != nil {
+		t.Fatalf("TestSendAsyncBatch Script error [%v]\n", se)
+	}
+}
+
+/*
+	Test that a canceled frame in a batch is not written, and does not fail
+	the rest of the batch.
+*/
+func TestSendAsyncCanceled(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Expect(SEND, HK_DESTINATION, sendAsyncDest+"a").
+		Expect(SEND, HK_DESTINATION, sendAsyncDest+"c").WaitClose()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestSendAsyncCanceled Expected [nil], got [%v]\n", e)
+	}
+	c.SetWriteBatch(sendAsyncBatch, sendAsyncLinger)
+	cx, cf := context.WithCancel(context.Background())
+	cf()
+	fa := c.SendAsync(context.Background(), Headers{HK_DESTINATION, sendAsyncDest + "a"}, "a")
+	fb := c.SendAsync(cx, Headers{HK_DESTINATION, sendAsyncDest + "b"}, "b")
+	fc := c.SendAsync(context.Background(), Headers{HK_DESTINATION, sendAsyncDest + "c"}, "c")
+	if e = fb.Wait(context.Background()); !errors.Is(e, context.Canceled) {
+		t.Fatalf("TestSendAsyncCanceled Expected [%v], got [%v]\n", context.Canceled, e)
+	}
+	for _, f := range []*SendFuture{fa, fc} {
+		<-f.Done()
+		if e = f.Err(); e != nil {
+			t.Fatalf("TestSendAsyncCanceled Expected [nil], got [%v]\n", e)
+		}
+	}
+	_ = n.Close()
+	if se := <-rc; se != nil {
+		t.Fatalf("TestSendAsyncCanceled Script error [%v]\n", se)
+	}
+}
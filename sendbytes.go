@@ -16,6 +16,10 @@
 
 package stompngo
 
+import (
+	"context"
+)
+
 /*
 	Send a STOMP MESSAGE.
 
@@ -33,7 +37,25 @@ package stompngo
 
 */
 func (c *Connection) SendBytes(h Headers, b []byte) error {
-	c.log(SEND, "start", h)
+	return c.SendBytesContext(context.Background(), h, b)
+}
+
+/*
+	SendBytesContext sends a STOMP MESSAGE with a byte slice payload, honoring
+	ctx cancellation and deadlines.
+
+	If ctx is done first, ctx.Err() is returned.  See SendBytes for details.
+*/
+func (c *Connection) SendBytesContext(ctx context.Context, h Headers, b []byte) error {
+	return c.sendBytes(ctx, h, b, false)
+}
+
+/*
+	SendBytes, waiting for the broker's RECEIPT if rcpt is set.
+*/
+func (c *Connection) sendBytes(ctx context.Context, h Headers, b []byte,
+	rcpt bool) error {
+	c.logcmd(SEND, "start", h)
 	if !c.isConnected() {
 		return ECONBAD
 	}
@@ -44,13 +66,19 @@ func (c *Connection) SendBytes(h Headers, b []byte) error {
 	if _, ok := h.Contains(HK_DESTINATION); !ok {
 		return EREQDSTSND
 	}
-	ch := h.Clone()
+	ch, end := c.traceSend(ctx, h.Clone())
 	f := Frame{SEND, ch, b}
-	r := make(chan error)
-	if e = c.writeWireData(wiredata{f, r}); e != nil {
-		return e
-	}
-	e = <-r
-	c.log(SEND, "end", ch)
+	e = c.transmitFrame(ctx, f, rcpt)
+	end(e)
+	c.logcmd(SEND, "end", ch)
 	return e // nil or not
 }
+
+/*
+	SendBytesWithReceipt sends a STOMP MESSAGE with a byte slice payload, and
+	waits for the broker's RECEIPT.  See SendWithReceipt and SendBytes for
+	details.
+*/
+func (c *Connection) SendBytesWithReceipt(ctx context.Context, h Headers, b []byte) error {
+	return c.sendBytes(ctx, h, b, true)
+}
@@ -0,0 +1,155 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"time"
+)
+
+/*
+	Stats is a point in time snapshot of connection metrics.  Totals include
+	heart beats.  Counts by command do not.
+*/
+type Stats struct {
+	Start              time.Time               // Connection start
+	FramesRead         int64                   // Total frames read
+	BytesRead          int64                   // Total bytes read
+	FramesWritten      int64                   // Total frames written
+	BytesWritten       int64                   // Total bytes written
+	Read               map[string]CommandStats // Frames read, by command
+	Written            map[string]CommandStats // Frames written, by command
+	Acks               int64                   // ACK frames written
+	Nacks              int64                   // NACK frames written
+	Errors             int64                   // ERROR frames read
+	HeartBeatsSent     int64                   // Heart beats sent
+	HeartBeatsReceived int64                   // Good heart beat receive checks
+	HeartBeatSendFails int64                   // Heart beat send failures
+	HeartBeatReadFails int64                   // Failed heart beat receive checks
+	LastRead           time.Time               // Latest read, zero if none
+	LastWrite          time.Time               // Latest write, zero if none
+	Subscriptions      map[string]SubStats     // By subscription id
+}
+
+/*
+	CommandStats holds frame and byte counts for a single STOMP command.
+*/
+type CommandStats struct {
+	Frames int64
+	Bytes  int64
+}
+
+/*
+	SubStats holds the MessageData channel and flow control state for a single
+	subscription.
+*/
+type SubStats struct {
+	Queued   int   // MessageData values waiting to be received
+	Capacity int   // Channel capacity, see SetSubChanCap
+	Overflow int   // MessageData values in the overflow buffer (FlowOverflow)
+	Dropped  int64 // MESSAGE frames discarded by flow control
+}
+
+/*
+	Index into the by command metrics.
+*/
+var statCmdIndex = func() map[string]int {
+	m := make(map[string]int, len(statCmds))
+	for i, c := range statCmds {
+		m[c] = i
+	}
+	return m
+}()
+
+/*
+	Stats returns a snapshot of the connection metrics.  It is safe to call
+	at any time, from any goroutine.
+
+	Example:
+		s := c.Stats()
+		fmt.Println("MESSAGE frames read:", s.Read[stompngo.MESSAGE].Frames)
+*/
+func (c *Connection) Stats() Stats {
+	m := c.mets
+	s := Stats{Start: m.st,
+		FramesRead:         m.tfr.Load(),
+		BytesRead:          m.tbr.Load(),
+		FramesWritten:      m.tfw.Load(),
+		BytesWritten:       m.tbw.Load(),
+		Read:               make(map[string]CommandStats),
+		Written:            make(map[string]CommandStats),
+		HeartBeatsSent:     c.SendTickerCount(),
+		HeartBeatsReceived: c.ReceiveTickerCount(),
+		HeartBeatSendFails: m.hbsm.Load(),
+		HeartBeatReadFails: m.hbrm.Load(),
+		LastRead:           nsTime(m.lr.Load()),
+		LastWrite:          nsTime(m.lw.Load())}
+	for i, cmd := range statCmds {
+		if f := m.cr[i].f.Load(); f > 0 {
+			s.Read[cmd] = CommandStats{f, m.cr[i].b.Load()}
+		}
+		if f := m.cw[i].f.Load(); f > 0 {
+			s.Written[cmd] = CommandStats{f, m.cw[i].b.Load()}
+		}
+	}
+	s.Subscriptions = make(map[string]SubStats)
+	c.subsLock.RLock()
+	for id, sd := range c.subs {
+		s.Subscriptions[id] = SubStats{len(sd.md), cap(sd.md), len(sd.ovch),
+			sd.drps.Load()}
+	}
+	c.subsLock.RUnlock()
+	s.Acks = s.Written[ACK].Frames
+	s.Nacks = s.Written[NACK].Frames
+	s.Errors = s.Read[ERROR].Frames
+	return s
+}
+
+/*
+	Count a frame read.
+*/
+func (m *metrics) read(cmd string, l int64) {
+	m.tfr.Add(1)
+	m.tbr.Add(l)
+	m.lr.Store(time.Now().UnixNano())
+	if i, ok := statCmdIndex[cmd]; ok {
+		m.cr[i].f.Add(1)
+		m.cr[i].b.Add(l)
+	}
+}
+
+/*
+	Count a frame written.
+*/
+func (m *metrics) write(cmd string, l int64) {
+	m.tfw.Add(1)
+	m.tbw.Add(l)
+	m.lw.Store(time.Now().UnixNano())
+	if i, ok := statCmdIndex[cmd]; ok {
+		m.cw[i].f.Add(1)
+		m.cw[i].b.Add(l)
+	}
+}
+
+/*
+	Time from Unix nanoseconds, zero for none.
+*/
+func nsTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
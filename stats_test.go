@@ -0,0 +1,104 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+/*
+	Test Stats counts by command, while Stats is read concurrently.
+*/
+func TestStatsSnapshot(t *testing.T) {
+	n, _ = openConn(t)
+	ch := headersProtocol(login_headers, SPL_12)
+	conn, e = Connect(n, ch)
+	if e != nil {
+		t.Fatalf("TestStatsSnapshot CONNECT expected nil, got %v\n", e)
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				_ = conn.Stats()
+			}
+		}
+	}()
+	//
+	d := tdest("/queue/stats.snapshot")
+	for _, ms := range statsMessages {
+		e = conn.Send(Headers{HK_DESTINATION, d}, ms)
+		if e != nil {
+			t.Fatalf("TestStatsSnapshot Expected nil error, got [%v]\n", e)
+		}
+	}
+	sc, e = conn.Subscribe(Headers{HK_DESTINATION, d, HK_ID, d,
+		HK_ACK, AckModeClientIndividual})
+	if e != nil {
+		t.Fatalf("TestStatsSnapshot Expected no subscribe error, got [%v]\n", e)
+	}
+	md = getMessageData(sc, conn, t)
+	e = conn.Ack(Headers{HK_ID, md.Message.Headers.Value(HK_ACK)})
+	if e != nil {
+		t.Fatalf("TestStatsSnapshot Expected no ACK error, got [%v]\n", e)
+	}
+	md = getMessageData(sc, conn, t)
+	e = conn.NackWithReceipt(context.Background(),
+		Headers{HK_ID, md.Message.Headers.Value(HK_ACK)})
+	if e != nil {
+		t.Fatalf("TestStatsSnapshot Expected no NACK error, got [%v]\n", e)
+	}
+	close(done)
+	wg.Wait()
+	//
+	s := conn.Stats()
+	if s.Written[SEND].Frames != int64(len(statsMessages)) {
+		t.Fatalf("TestStatsSnapshot Expected [%d] SEND frames, got [%d]\n",
+			len(statsMessages), s.Written[SEND].Frames)
+	}
+	if s.Read[MESSAGE].Frames != int64(len(statsMessages)) {
+		t.Fatalf("TestStatsSnapshot Expected [%d] MESSAGE frames, got [%d]\n",
+			len(statsMessages), s.Read[MESSAGE].Frames)
+	}
+	if s.Acks != 1 || s.Nacks != 1 || s.Errors != 0 {
+		t.Fatalf("TestStatsSnapshot Expected [1 1 0], got [%d %d %d]\n", s.Acks,
+			s.Nacks, s.Errors)
+	}
+	if s.Read[CONNECTED].Frames != 1 || s.Read[RECEIPT].Frames != 1 {
+		t.Fatalf("TestStatsSnapshot Expected CONNECTED and RECEIPT, got [%v]\n",
+			s.Read)
+	}
+	if s.FramesRead != conn.FramesRead() || s.BytesWritten != conn.BytesWritten() {
+		t.Fatalf("TestStatsSnapshot Expected matching totals, got [%v]\n", s)
+	}
+	if s.LastRead.IsZero() || s.LastWrite.IsZero() {
+		t.Fatalf("TestStatsSnapshot Expected last read and write times\n")
+	}
+	//
+	checkReceived(t, conn, false)
+	e = conn.Disconnect(empty_headers)
+	checkDisconnectError(t, e)
+	_ = closeConn(t, n)
+}
@@ -0,0 +1,77 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompserver
+
+import (
+	"github.com/photostorm/stompngo"
+)
+
+/*
+	Handler receives the frames of each client session.
+
+	Connect is called with the CONNECT or STOMP frame, after the protocol
+	version is negotiated, and before CONNECTED is sent.  The other methods,
+	except Disconnect, are called with the client frame of the same name,
+	from the session's read goroutine, one at a time.  A RECEIPT requested by
+	the frame is sent after the method returns nil.  A non-nil error is
+	reported with an ERROR frame, and the session is closed.
+
+	Disconnect is called once when a session that Connect accepted ends, for
+	any reason.
+*/
+type Handler interface {
+	Connect(s *Session, f stompngo.Frame) error
+	Send(s *Session, f stompngo.Frame) error
+	Subscribe(s *Session, f stompngo.Frame) error
+	Unsubscribe(s *Session, f stompngo.Frame) error
+	Ack(s *Session, f stompngo.Frame) error
+	Nack(s *Session, f stompngo.Frame) error
+	Begin(s *Session, f stompngo.Frame) error
+	Commit(s *Session, f stompngo.Frame) error
+	Abort(s *Session, f stompngo.Frame) error
+	Disconnect(s *Session)
+}
+
+/*
+	BaseHandler accepts every frame and does nothing.  Embed it in a Handler
+	to implement only the methods needed.
+*/
+type BaseHandler struct{}
+
+func (BaseHandler) Connect(*Session, stompngo.Frame) error     { return nil }
+func (BaseHandler) Send(*Session, stompngo.Frame) error        { return nil }
+func (BaseHandler) Subscribe(*Session, stompngo.Frame) error   { return nil }
+func (BaseHandler) Unsubscribe(*Session, stompngo.Frame) error { return nil }
+func (BaseHandler) Ack(*Session, stompngo.Frame) error         { return nil }
+func (BaseHandler) Nack(*Session, stompngo.Frame) error        { return nil }
+func (BaseHandler) Begin(*Session, stompngo.Frame) error       { return nil }
+func (BaseHandler) Commit(*Session, stompngo.Frame) error      { return nil }
+func (BaseHandler) Abort(*Session, stompngo.Frame) error       { return nil }
+func (BaseHandler) Disconnect(*Session)                        {}
+
+/*
+	Error is a Handler error with an ERROR frame body.  Other errors are sent
+	with their text as the message header, and no body.
+*/
+type Error struct {
+	Message string // ERROR message header
+	Detail  string // ERROR body
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
@@ -0,0 +1,209 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+/*
+	Package stompserver is a framework for the server side of STOMP 1.0, 1.1
+	and 1.2 connections, for lightweight brokers, gateways, and test doubles.
+
+	The Server handles RECEIPT replies, DISCONNECT, and heart beats.  The
+	Handler decides everything else, and sends MESSAGE frames with
+	Session.Send.  A Handler error is reported to the client with an ERROR
+	frame, and the connection is closed.
+
+	Example:
+		srv := stompserver.NewServer(h, stompserver.WithServerName("gw/1.0"))
+		l, e := net.Listen("tcp", ":61613")
+		if e != nil {
+			// Do something sane ...
+		}
+		e = srv.Serve(l)
+*/
+package stompserver
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/photostorm/stompngo"
+)
+
+/*
+	Option is a function that modifies Server behavior.
+*/
+type Option func(*Server)
+
+/*
+	Server serves STOMP connections, dispatching client frames to a Handler.
+*/
+type Server struct {
+	h     Handler               // Frame handler
+	mu    sync.Mutex            // Protects sess, lsnrs and done
+	sess  map[*Session]bool     // Active sessions
+	lsnrs map[net.Listener]bool // Active listeners
+	wg    sync.WaitGroup        // Active serve goroutines
+	done  bool                  // Close called
+	//
+	svrname string              // CONNECTED server header, "" for none
+	hbsx    time.Duration       // Smallest server heart beat send interval
+	hbsy    time.Duration       // Desired client heart beat interval
+	hbgrace float64             // Client heart beat interval factor
+	lim     stompngo.ReadLimits // Client frame limits
+	sessid  atomic.Int64        // Session id sequence
+}
+
+var (
+	// ErrServerClosed is returned by Serve after Close.
+	ErrServerClosed = errors.New("stompserver: server closed")
+
+	// ErrSessionClosed is returned by Session.Send after the session ends.
+	ErrSessionClosed = errors.New("stompserver: session closed")
+)
+
+/*
+	WithServerName sets the server header returned in CONNECTED frames.
+*/
+func WithServerName(s string) Option {
+	return func(sv *Server) {
+		sv.svrname = s
+	}
+}
+
+/*
+	WithHeartBeat sets the server's heart-beat header values: sx, the
+	smallest interval at which the server can send heart beats, and sy, the
+	desired interval between client heart beats.  Zero means none, the
+	default.
+*/
+func WithHeartBeat(sx, sy time.Duration) Option {
+	return func(sv *Server) {
+		sv.hbsx, sv.hbsy = sx, sy
+	}
+}
+
+/*
+	WithHeartBeatGrace sets the factor applied to the negotiated client heart
+	beat interval before a silent connection is dropped.  The default is 2.
+*/
+func WithHeartBeatGrace(f float64) Option {
+	return func(sv *Server) {
+		sv.hbgrace = f
+	}
+}
+
+/*
+	WithReadLimits sets the limits on frames received from clients.  The
+	default is stompngo.DefaultReadLimits.
+*/
+func WithReadLimits(l stompngo.ReadLimits) Option {
+	return func(sv *Server) {
+		sv.lim = l
+	}
+}
+
+/*
+	NewServer returns a new Server dispatching to h.
+*/
+func NewServer(h Handler, opts ...Option) *Server {
+	sv := &Server{h: h,
+		sess:    make(map[*Session]bool),
+		lsnrs:   make(map[net.Listener]bool),
+		hbgrace: 2,
+		lim:     stompngo.DefaultReadLimits}
+	for _, o := range opts {
+		o(sv)
+	}
+	return sv
+}
+
+/*
+	Serve accepts connections on l, and serves each in its own goroutine.  It
+	returns when l fails, or after Close, which also closes l.
+*/
+func (sv *Server) Serve(l net.Listener) error {
+	sv.mu.Lock()
+	if sv.done {
+		sv.mu.Unlock()
+		return ErrServerClosed
+	}
+	sv.lsnrs[l] = true
+	sv.wg.Add(1)
+	sv.mu.Unlock()
+	defer sv.wg.Done()
+	for {
+		n, e := l.Accept()
+		if e != nil {
+			sv.mu.Lock()
+			delete(sv.lsnrs, l)
+			done := sv.done
+			sv.mu.Unlock()
+			if done {
+				return ErrServerClosed
+			}
+			return e
+		}
+		go sv.ServeConn(n)
+	}
+}
+
+/*
+	ServeConn serves a single client connection, and returns when that
+	connection ends.  n is always closed.
+*/
+func (sv *Server) ServeConn(n net.Conn) {
+	s := newSession(sv, n)
+	sv.mu.Lock()
+	if sv.done {
+		sv.mu.Unlock()
+		_ = n.Close()
+		return
+	}
+	sv.sess[s] = true
+	sv.wg.Add(1)
+	sv.mu.Unlock()
+	defer sv.wg.Done()
+	defer sv.removeSession(s)
+	s.serve()
+}
+
+/*
+	Close stops all listeners, drops all client connections, and waits for
+	all serving goroutines to finish.
+*/
+func (sv *Server) Close() error {
+	sv.mu.Lock()
+	sv.done = true
+	for l := range sv.lsnrs {
+		_ = l.Close()
+	}
+	for s := range sv.sess {
+		_ = s.Close()
+	}
+	sv.mu.Unlock()
+	sv.wg.Wait()
+	return nil
+}
+
+/*
+	Forget a finished session.
+*/
+func (sv *Server) removeSession(s *Session) {
+	sv.mu.Lock()
+	delete(sv.sess, s)
+	sv.mu.Unlock()
+}
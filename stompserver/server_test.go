@@ -0,0 +1,296 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompserver_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo"
+	"github.com/photostorm/stompngo/stompserver"
+)
+
+const testWait = 2 * time.Second
+
+/*
+	A minimal topic broker: every SEND goes to current subscribers.
+*/
+type testHandler struct {
+	stompserver.BaseHandler
+	mu    sync.Mutex
+	subs  map[string][]testSub // Subscriptions by destination
+	msgid int
+	login string      // Required login, "" for any
+	disc  chan string // Ended session ids
+}
+
+type testSub struct {
+	s  *stompserver.Session
+	id string
+}
+
+func newTestHandler(login string) *testHandler {
+	return &testHandler{subs: make(map[string][]testSub), login: login,
+		disc: make(chan string, 8)}
+}
+
+func (h *testHandler) Connect(s *stompserver.Session, f stompngo.Frame) error {
+	if h.login != "" && f.Headers.Value(stompngo.HK_LOGIN) != h.login {
+		return &stompserver.Error{Message: "access refused", Detail: "bad login"}
+	}
+	return nil
+}
+
+func (h *testHandler) Subscribe(s *stompserver.Session, f stompngo.Frame) error {
+	d := f.Headers.Value(stompngo.HK_DESTINATION)
+	id, ok := f.Headers.Contains(stompngo.HK_ID)
+	if !ok {
+		id = d
+	}
+	h.mu.Lock()
+	h.subs[d] = append(h.subs[d], testSub{s, id})
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *testHandler) Send(s *stompserver.Session, f stompngo.Frame) error {
+	d := f.Headers.Value(stompngo.HK_DESTINATION)
+	if d == testReject {
+		return errors.New("rejected")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, sb := range h.subs[d] {
+		h.msgid++
+		id := "msg-" + strconv.Itoa(h.msgid)
+		mh := f.Headers.Delete(stompngo.HK_RECEIPT).Delete(stompngo.HK_CONTENT_LENGTH).
+			Add(stompngo.HK_SUBSCRIPTION, sb.id).Add(stompngo.HK_MESSAGE_ID, id)
+		if sb.s.Protocol() == stompngo.SPL_12 {
+			mh = mh.Add(stompngo.HK_ACK, id)
+		}
+		_ = sb.s.Send(stompngo.Frame{Command: stompngo.MESSAGE, Headers: mh,
+			Body: f.Body})
+	}
+	return nil
+}
+
+func (h *testHandler) Disconnect(s *stompserver.Session) {
+	h.mu.Lock()
+	for d, sl := range h.subs {
+		k := sl[:0]
+		for _, sb := range sl {
+			if sb.s != s {
+				k = append(k, sb)
+			}
+		}
+		h.subs[d] = k
+	}
+	h.mu.Unlock()
+	h.disc <- s.ID()
+}
+
+/*
+	Connect headers for a protocol level.
+*/
+func connHeaders(p string) stompngo.Headers {
+	return stompngo.Headers{stompngo.HK_ACCEPT_VERSION, p,
+		stompngo.HK_HOST, "localhost"}
+}
+
+/*
+	Connect to a server over a pipe.
+*/
+func pipeConnect(sv *stompserver.Server, h stompngo.Headers) (*stompngo.Connection, error) {
+	cn, sn := net.Pipe()
+	go sv.ServeConn(sn)
+	return stompngo.Connect(cn, h)
+}
+
+/*
+	Test a round trip over TCP, at all protocol levels, with encoded header
+	values, receipts, and Disconnect notification.
+*/
+func TestServerRoundTrip(t *testing.T) {
+	h := newTestHandler("")
+	sv := stompserver.NewServer(h, stompserver.WithServerName("stompserver/test"))
+	l, e := net.Listen(stompngo.NetProtoTCP, "127.0.0.1:0")
+	if e != nil {
+		t.Fatalf("TestServerRoundTrip Listen Expected [nil], got [%v]\n", e)
+	}
+	sd := make(chan error, 1)
+	go func() { sd <- sv.Serve(l) }()
+	for _, p := range stompngo.Protocols() {
+		n, e := net.Dial(stompngo.NetProtoTCP, l.Addr().String())
+		if e != nil {
+			t.Fatalf("TestServerRoundTrip Dial Expected [nil], got [%v]\n", e)
+		}
+		c, e := stompngo.Connect(n, connHeaders(p))
+		if e != nil {
+			t.Fatalf("TestServerRoundTrip Expected [nil], got [%v]\n", e)
+		}
+		if c.Protocol() != p {
+			t.Fatalf("TestServerRoundTrip Expected [%s], got [%s]\n", p, c.Protocol())
+		}
+		if v := c.ConnectResponse.Headers.Value(stompngo.HK_SERVER); v != "stompserver/test" {
+			t.Fatalf("TestServerRoundTrip Expected a server header, got [%s]\n", v)
+		}
+		d := "/topic/stompserver.rt." + p
+		sc, e := c.Subscribe(stompngo.Headers{stompngo.HK_DESTINATION, d,
+			stompngo.HK_ID, "rt"})
+		if e != nil {
+			t.Fatalf("TestServerRoundTrip Expected [nil], got [%v]\n", e)
+		}
+		e = c.SendWithReceipt(context.Background(), stompngo.Headers{
+			stompngo.HK_DESTINATION, d, "odd", "a:b"}, "round trip "+p)
+		if e != nil {
+			t.Fatalf("TestServerRoundTrip Expected [nil], got [%v]\n", e)
+		}
+		select {
+		case md := <-sc:
+			if md.Error != nil || md.Message.BodyString() != "round trip "+p {
+				t.Fatalf("TestServerRoundTrip Expected a message, got [%v] [%v]\n",
+					md.Message, md.Error)
+			}
+			if v := md.Message.Headers.Value("odd"); v != "a:b" {
+				t.Fatalf("TestServerRoundTrip Expected [a:b], got [%s]\n", v)
+			}
+		case <-time.After(testWait):
+			t.Fatalf("TestServerRoundTrip Expected a message, got none\n")
+		}
+		if e = c.Disconnect(stompngo.Headers{}); e != nil {
+			t.Fatalf("TestServerRoundTrip Expected [nil], got [%v]\n", e)
+		}
+		_ = n.Close()
+		select {
+		case <-h.disc:
+		case <-time.After(testWait):
+			t.Fatalf("TestServerRoundTrip Expected a Disconnect call\n")
+		}
+	}
+	if e = sv.Close(); e != nil {
+		t.Fatalf("TestServerRoundTrip Close Expected [nil], got [%v]\n", e)
+	}
+	if e = <-sd; e != stompserver.ErrServerClosed {
+		t.Fatalf("TestServerRoundTrip Expected [%v], got [%v]\n",
+			stompserver.ErrServerClosed, e)
+	}
+}
+
+/*
+	Test Handler errors: a refused CONNECT, and a refused SEND reported with
+	an ERROR frame carrying the receipt-id.
+*/
+func TestServerHandlerError(t *testing.T) {
+	h := newTestHandler("user")
+	sv := stompserver.NewServer(h)
+	defer sv.Close()
+	_, e := pipeConnect(sv, connHeaders(stompngo.SPL_12))
+	var be *stompngo.BrokerError
+	if !errors.As(e, &be) || be.Message.Headers.Value(stompngo.HK_MESSAGE) != "access refused" {
+		t.Fatalf("TestServerHandlerError Expected a BrokerError, got [%v]\n", e)
+	}
+	c, e := pipeConnect(sv, connHeaders(stompngo.SPL_12).Add(stompngo.HK_LOGIN, "user"))
+	if e != nil {
+		t.Fatalf("TestServerHandlerError Expected [nil], got [%v]\n", e)
+	}
+	e = c.SendWithReceipt(context.Background(), stompngo.Headers{
+		stompngo.HK_DESTINATION, testReject}, "no")
+	if !errors.As(e, &be) || be.Message.Headers.Value(stompngo.HK_MESSAGE) != "rejected" {
+		t.Fatalf("TestServerHandlerError Expected a BrokerError, got [%v]\n", e)
+	}
+	select {
+	case <-h.disc:
+	case <-time.After(testWait):
+		t.Fatalf("TestServerHandlerError Expected a Disconnect call\n")
+	}
+}
+
+/*
+	Test heart beats: a connection with heart beats in both directions stays
+	up, and a silent client is dropped.
+*/
+func TestServerHeartBeats(t *testing.T) {
+	sv := stompserver.NewServer(newTestHandler(""),
+		stompserver.WithHeartBeat(testBeat, testBeat))
+	defer sv.Close()
+	hb := strconv.FormatInt(testBeat.Milliseconds(), 10)
+	c, e := pipeConnect(sv, connHeaders(stompngo.SPL_12).Add(stompngo.HK_HEART_BEAT,
+		hb+","+hb))
+	if e != nil {
+		t.Fatalf("TestServerHeartBeats Expected [nil], got [%v]\n", e)
+	}
+	if c.SendTickerInterval() != testBeat.Milliseconds() ||
+		c.ReceiveTickerInterval() != testBeat.Milliseconds() {
+		t.Fatalf("TestServerHeartBeats Expected [%v], got [%d] [%d]\n", testBeat,
+			c.SendTickerInterval(), c.ReceiveTickerInterval())
+	}
+	time.Sleep(6 * testBeat)
+	e = c.SendWithReceipt(context.Background(), stompngo.Headers{
+		stompngo.HK_DESTINATION, "/topic/stompserver.hb"}, "alive")
+	if e != nil {
+		t.Fatalf("TestServerHeartBeats Expected [nil], got [%v]\n", e)
+	}
+	_ = c.Disconnect(stompngo.Headers{})
+	//
+	cn, sn := net.Pipe()
+	go sv.ServeConn(sn)
+	_, _ = cn.Write([]byte("CONNECT\naccept-version:1.2\nhost:localhost\n" +
+		"heart-beat:" + hb + ",0\n\n\x00"))
+	_ = cn.SetReadDeadline(time.Now().Add(testWait))
+	bf := make([]byte, 512)
+	for {
+		if _, e := cn.Read(bf); e != nil {
+			if ne, ok := e.(net.Error); ok && ne.Timeout() {
+				t.Fatalf("TestServerHeartBeats Expected a dropped connection\n")
+			}
+			break
+		}
+	}
+}
+
+/*
+	Test version negotiation failure, and a frame before CONNECT.
+*/
+func TestServerBadConnect(t *testing.T) {
+	sv := stompserver.NewServer(newTestHandler(""))
+	defer sv.Close()
+	for _, raw := range []string{
+		"CONNECT\naccept-version:9.9\nhost:localhost\n\n\x00",
+		"SEND\ndestination:/queue/x\n\n\x00",
+	} {
+		cn, sn := net.Pipe()
+		go sv.ServeConn(sn)
+		go func() { _, _ = cn.Write([]byte(raw)) }()
+		fr := stompngo.NewFrameReader(cn, stompngo.DefaultReadLimits)
+		_ = cn.SetReadDeadline(time.Now().Add(testWait))
+		f, e := fr.ReadFrame()
+		if e != nil || f.Command != stompngo.ERROR {
+			t.Fatalf("TestServerBadConnect Expected an ERROR, got [%v] [%v]\n", f, e)
+		}
+		_ = cn.Close()
+	}
+}
+
+const (
+	testReject = "/queue/stompserver.reject"
+	testBeat   = 50 * time.Millisecond
+)
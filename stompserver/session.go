@@ -0,0 +1,400 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompserver
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/photostorm/stompngo"
+)
+
+/*
+	Session is a single client connection.
+*/
+type Session struct {
+	sv    *Server
+	n     net.Conn
+	r     *stompngo.FrameReader
+	id    string
+	proto string
+	ch    stompngo.Headers // CONNECT headers
+	//
+	wmu    sync.Mutex // Protects w and wdone
+	w      *stompngo.FrameWriter
+	wdone  bool // No further frames are written
+	closed chan struct{}
+	once   sync.Once
+	//
+	hbs time.Duration // Negotiated heart beat send interval
+	hbr time.Duration // Negotiated heart beat receive interval
+	lr  atomic.Int64  // Time of the latest read, UnixNano
+}
+
+func newSession(sv *Server, n net.Conn) *Session {
+	s := &Session{sv: sv, n: n,
+		w:      stompngo.NewFrameWriter(n),
+		id:     "stompserver-sess-" + strconv.FormatInt(sv.sessid.Add(1), 10),
+		proto:  stompngo.SPL_10,
+		closed: make(chan struct{})}
+	s.r = stompngo.NewFrameReader(touchReader{n, s}, sv.lim)
+	return s
+}
+
+/*
+	ID returns the session id sent in CONNECTED.
+*/
+func (s *Session) ID() string {
+	return s.id
+}
+
+/*
+	Protocol returns the negotiated protocol level.
+*/
+func (s *Session) Protocol() string {
+	return s.proto
+}
+
+/*
+	ConnectHeaders returns the headers of the client's CONNECT or STOMP frame.
+*/
+func (s *Session) ConnectHeaders() stompngo.Headers {
+	return s.ch
+}
+
+/*
+	RemoteAddr returns the client's network address.
+*/
+func (s *Session) RemoteAddr() net.Addr {
+	return s.n.RemoteAddr()
+}
+
+/*
+	Done returns a channel that is closed when the session ends.
+*/
+func (s *Session) Done() <-chan struct{} {
+	return s.closed
+}
+
+/*
+	Send writes a frame to the client, usually a MESSAGE.  It is safe for
+	concurrent use, and returns ErrSessionClosed after the session ends.
+*/
+func (s *Session) Send(f stompngo.Frame) error {
+	return s.write(f, false)
+}
+
+/*
+	Close ends the session, without an ERROR frame.
+*/
+func (s *Session) Close() error {
+	s.once.Do(func() {
+		close(s.closed)
+		_ = s.n.Close()
+	})
+	return nil
+}
+
+/*
+	Serve the connection: handshake, then frames until DISCONNECT, an error,
+	or Close.
+*/
+func (s *Session) serve() {
+	defer s.Close()
+	s.touch()
+	f, e := s.readFrame()
+	if e == nil {
+		e = s.connect(f)
+	}
+	if e != nil {
+		if !errors.Is(e, io.EOF) {
+			s.sendError(f, e)
+		}
+		return
+	}
+	defer s.sv.h.Disconnect(s)
+	if s.hbs > 0 {
+		go s.heartBeats()
+	}
+	if s.hbr > 0 {
+		go s.monitor()
+	}
+	for {
+		f, e = s.readFrame()
+		if e != nil {
+			select {
+			case <-s.closed:
+			default:
+				if !errors.Is(e, io.EOF) {
+					s.sendError(f, e)
+				}
+			}
+			return
+		}
+		if f.Command == stompngo.DISCONNECT {
+			if r, ok := f.Headers.Contains(stompngo.HK_RECEIPT); ok {
+				_ = s.write(stompngo.Frame{Command: stompngo.RECEIPT,
+					Headers: stompngo.Headers{stompngo.HK_RECEIPT_ID, r}}, true)
+			}
+			return
+		}
+		if e = s.dispatch(f); e != nil {
+			s.sendError(f, e)
+			return
+		}
+		if r, ok := f.Headers.Contains(stompngo.HK_RECEIPT); ok {
+			_ = s.Send(stompngo.Frame{Command: stompngo.RECEIPT,
+				Headers: stompngo.Headers{stompngo.HK_RECEIPT_ID, r}})
+		}
+	}
+}
+
+/*
+	Read the next frame, skipping heart beats.
+*/
+func (s *Session) readFrame() (stompngo.Frame, error) {
+	for {
+		f, e := s.r.ReadFrame()
+		if e != nil || f.Command != "" {
+			return f, e
+		}
+	}
+}
+
+/*
+	Handle CONNECT or STOMP, and send CONNECTED.
+*/
+func (s *Session) connect(f stompngo.Frame) error {
+	if f.Command != stompngo.CONNECT && f.Command != stompngo.STOMP {
+		return &Error{"connection not established", "expected CONNECT, got " +
+			f.Command}
+	}
+	s.ch = f.Headers
+	s.proto = ""
+	av, ok := f.Headers.Contains(stompngo.HK_ACCEPT_VERSION)
+	if !ok {
+		av = stompngo.SPL_10
+	}
+	for _, cv := range strings.Split(av, ",") {
+		cv = strings.TrimSpace(cv)
+		if stompngo.Supported(cv) && cv > s.proto {
+			s.proto = cv
+		}
+	}
+	if s.proto == "" {
+		s.proto = stompngo.SPL_10
+		return &Error{"supported protocol versions are " +
+			strings.Join(stompngo.Protocols(), ","), ""}
+	}
+	s.r.SetProtocol(s.proto)
+	s.w.SetProtocol(s.proto)
+	c := stompngo.Frame{Command: stompngo.CONNECTED, Headers: stompngo.Headers{}}
+	if s.proto != stompngo.SPL_10 {
+		hb, e := s.negotiate(f.Headers)
+		if e != nil {
+			return e
+		}
+		c.Headers = c.Headers.Add(stompngo.HK_VERSION, s.proto).
+			Add(stompngo.HK_HEART_BEAT, hb)
+	}
+	if e := s.sv.h.Connect(s, f); e != nil {
+		return e
+	}
+	c.Headers = c.Headers.Add(stompngo.HK_SESSION, s.id)
+	if s.sv.svrname != "" {
+		c.Headers = c.Headers.Add(stompngo.HK_SERVER, s.sv.svrname)
+	}
+	return s.Send(c)
+}
+
+/*
+	Negotiate heart beats.  Returns the CONNECTED heart-beat value.
+*/
+func (s *Session) negotiate(h stompngo.Headers) (string, error) {
+	var cx, cy int64
+	if v, ok := h.Contains(stompngo.HK_HEART_BEAT); ok {
+		p := strings.Split(v, ",")
+		var e1, e2 error
+		if len(p) == 2 {
+			cx, e1 = strconv.ParseInt(strings.TrimSpace(p[0]), 10, 64)
+			cy, e2 = strconv.ParseInt(strings.TrimSpace(p[1]), 10, 64)
+		}
+		if len(p) != 2 || e1 != nil || e2 != nil || cx < 0 || cy < 0 {
+			return "", &Error{"invalid heart-beat header", v}
+		}
+	}
+	sx, sy := s.sv.hbsx.Milliseconds(), s.sv.hbsy.Milliseconds()
+	if sx > 0 && cy > 0 {
+		s.hbs = time.Duration(max(sx, cy)) * time.Millisecond
+	}
+	if cx > 0 && sy > 0 {
+		s.hbr = time.Duration(max(cx, sy)) * time.Millisecond
+	}
+	return strconv.FormatInt(sx, 10) + "," + strconv.FormatInt(sy, 10), nil
+}
+
+/*
+	Check a client frame, and pass it to the Handler.
+*/
+func (s *Session) dispatch(f stompngo.Frame) error {
+	h := s.sv.h
+	switch f.Command {
+	case stompngo.SEND:
+		if _, ok := f.Headers.Contains(stompngo.HK_DESTINATION); !ok {
+			return &Error{"destination header missing", f.Command}
+		}
+		return h.Send(s, f)
+	case stompngo.SUBSCRIBE:
+		if _, ok := f.Headers.Contains(stompngo.HK_DESTINATION); !ok {
+			return &Error{"destination header missing", f.Command}
+		}
+		return h.Subscribe(s, f)
+	case stompngo.UNSUBSCRIBE:
+		return h.Unsubscribe(s, f)
+	case stompngo.ACK:
+		return h.Ack(s, f)
+	case stompngo.NACK:
+		if s.proto == stompngo.SPL_10 {
+			break
+		}
+		return h.Nack(s, f)
+	case stompngo.BEGIN, stompngo.COMMIT, stompngo.ABORT:
+		if _, ok := f.Headers.Contains(stompngo.HK_TRANSACTION); !ok {
+			return &Error{"transaction header missing", f.Command}
+		}
+		switch f.Command {
+		case stompngo.BEGIN:
+			return h.Begin(s, f)
+		case stompngo.COMMIT:
+			return h.Commit(s, f)
+		}
+		return h.Abort(s, f)
+	}
+	return &Error{"unexpected command", f.Command}
+}
+
+/*
+	Send an ERROR frame for a rejected client frame.  The session ends after
+	it is written.
+*/
+func (s *Session) sendError(f stompngo.Frame, e error) {
+	ef := stompngo.Frame{Command: stompngo.ERROR, Headers: stompngo.Headers{}}
+	var se *Error
+	if errors.As(e, &se) {
+		ef.Headers = ef.Headers.Add(stompngo.HK_MESSAGE, se.Message)
+		ef.Body = []byte(se.Detail)
+	} else {
+		ef.Headers = ef.Headers.Add(stompngo.HK_MESSAGE, e.Error())
+	}
+	if r, ok := f.Headers.Contains(stompngo.HK_RECEIPT); ok {
+		ef.Headers = ef.Headers.Add(stompngo.HK_RECEIPT_ID, r)
+	}
+	ef.Headers = ef.Headers.Add(stompngo.HK_CONTENT_TYPE, "text/plain")
+	_ = s.write(ef, true)
+}
+
+/*
+	Write a frame.  If last is set nothing more is written.
+*/
+func (s *Session) write(f stompngo.Frame, last bool) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	if s.wdone {
+		return ErrSessionClosed
+	}
+	s.wdone = last
+	if e := s.w.WriteFrame(f); e != nil {
+		s.wdone = true
+		_ = s.Close()
+		return e
+	}
+	return nil
+}
+
+/*
+	Send heart beats.
+*/
+func (s *Session) heartBeats() {
+	t := time.NewTicker(s.hbs)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.wmu.Lock()
+			e := ErrSessionClosed
+			if !s.wdone {
+				e = s.w.WriteHeartBeat()
+			}
+			s.wmu.Unlock()
+			if e != nil {
+				_ = s.Close()
+				return
+			}
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+/*
+	Drop the connection if the client stops sending heart beats.
+*/
+func (s *Session) monitor() {
+	lim := time.Duration(float64(s.hbr) * s.sv.hbgrace)
+	t := time.NewTicker(s.hbr)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if time.Since(time.Unix(0, s.lr.Load())) > lim {
+				_ = s.Close()
+				return
+			}
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+/*
+	Note a read, for heart beat monitoring.
+*/
+func (s *Session) touch() {
+	s.lr.Store(time.Now().UnixNano())
+}
+
+/*
+	A reader noting each read from the client.
+*/
+type touchReader struct {
+	r io.Reader
+	s *Session
+}
+
+func (t touchReader) Read(p []byte) (int, error) {
+	n, e := t.r.Read(p)
+	if n > 0 {
+		t.s.touch()
+	}
+	return n, e
+}
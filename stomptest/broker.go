@@ -0,0 +1,456 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+/*
+	Package stomptest provides an in-memory STOMP 1.0, 1.1 and 1.2 broker, for
+	hermetic tests of stompngo and of applications built on it.
+
+	The broker supports queues and topics, the auto, client and
+	client-individual ack modes, NACK, transactions, receipts, and heart
+	beats.  Destinations beginning with /topic/ are topics, where each SEND is
+	delivered to all current subscribers and then discarded.  All other
+	destinations are queues, where each SEND is held until it is delivered to
+	exactly one subscriber, in round robin order.  A queue message that is not
+	acknowledged when its subscription ends is redelivered, with a
+	redelivered:true header.  A NACKed message is discarded.
+
+	State is held in memory only, and is shared by all connections served by
+	the same Broker.
+
+	For protocol edge cases a well behaved broker never produces, see Script.
+
+	Example:
+		s := stomptest.NewServer()
+		defer s.Close()
+		n, e := net.Dial("tcp", s.Addr)
+		if e != nil {
+			// Do something sane ...
+		}
+		c, e := stompngo.Connect(n, h)
+		// Use c
+
+	Example:
+		b := stomptest.NewBroker()
+		defer b.Close()
+		c, e := stompngo.Connect(b.Pipe(), h)
+		// Use c
+*/
+package stomptest
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	BrokerOption is a function that modifies Broker behavior.
+*/
+type BrokerOption func(*Broker)
+
+/*
+	Broker is an in-memory STOMP broker.
+*/
+type Broker struct {
+	mu    sync.Mutex              // Protects all destination and subscription state
+	dests map[string]*destination // Known destinations
+	sess  map[*session]bool       // Active sessions
+	lsnrs map[net.Listener]bool   // Active listeners
+	wg    sync.WaitGroup          // Active serve goroutines
+	done  bool                    // Close called
+	//
+	login    string        // Required login, "" for any
+	passcode string        // Required passcode
+	hbset    bool          // Server heart beats are configured
+	hbsx     time.Duration // Server heart beat send interval
+	hbsy     time.Duration // Server heart beat receive interval
+	hbgrace  float64       // Heart beat receive tolerance factor
+	svrname  string        // server header value
+	msgid    atomic.Int64  // message-id source
+	sessid   atomic.Int64  // session source
+}
+
+/*
+	A destination.  Queues hold undelivered messages, topics never do.
+*/
+type destination struct {
+	name  string
+	topic bool
+	msgs  []*message // Undelivered queue messages
+	subs  []*sub     // Current subscriptions
+	next  int        // Round robin index
+}
+
+/*
+	A message, as sent by a client.
+*/
+type message struct {
+	id      string   // message-id
+	dest    string   // destination
+	headers []string // SEND headers, less those the broker owns
+	body    []byte
+	redlv   bool // Redelivery
+}
+
+/*
+	A subscription.
+*/
+type sub struct {
+	s      *session
+	id     string
+	d      *destination
+	ack    string     // auto, client, or client-individual
+	unackd []*pending // Delivered, not yet acknowledged, in delivery order
+}
+
+/*
+	A delivered, unacknowledged message.
+*/
+type pending struct {
+	ackid string
+	m     *message
+}
+
+var (
+	// ErrBrokerClosed is returned by Serve after Close.
+	ErrBrokerClosed = errors.New("stomptest: broker closed")
+)
+
+/*
+	WithCredentials requires every CONNECT to carry this login and passcode.
+	By default any credentials are accepted.
+*/
+func WithCredentials(login, passcode string) BrokerOption {
+	return func(b *Broker) {
+		b.login, b.passcode = login, passcode
+	}
+}
+
+/*
+	WithHeartBeat sets the broker's heart-beat header values: sx, the
+	smallest interval at which the broker can send heart beats, and sy, the
+	desired interval between client heart beats.  Zero means none.  By
+	default the broker mirrors the client's request.
+*/
+func WithHeartBeat(sx, sy time.Duration) BrokerOption {
+	return func(b *Broker) {
+		b.hbset, b.hbsx, b.hbsy = true, sx, sy
+	}
+}
+
+/*
+	WithHeartBeatGrace sets the factor applied to the negotiated client heart
+	beat interval before a silent connection is dropped.  The default is 2.
+*/
+func WithHeartBeatGrace(f float64) BrokerOption {
+	return func(b *Broker) {
+		b.hbgrace = f
+	}
+}
+
+/*
+	WithServerName sets the server header returned in CONNECTED frames.
+*/
+func WithServerName(s string) BrokerOption {
+	return func(b *Broker) {
+		b.svrname = s
+	}
+}
+
+/*
+	NewBroker returns a new, empty, Broker.
+*/
+func NewBroker(opts ...BrokerOption) *Broker {
+	b := &Broker{dests: make(map[string]*destination),
+		sess:    make(map[*session]bool),
+		lsnrs:   make(map[net.Listener]bool),
+		hbgrace: 2,
+		svrname: "stomptest/1.0"}
+	for _, o := range opts {
+		o(b)
+	}
+	return b
+}
+
+/*
+	Serve accepts connections on l, and serves each in its own goroutine.  It
+	returns when l fails, or after Close, which also closes l.
+*/
+func (b *Broker) Serve(l net.Listener) error {
+	b.mu.Lock()
+	if b.done {
+		b.mu.Unlock()
+		return ErrBrokerClosed
+	}
+	b.lsnrs[l] = true
+	b.wg.Add(1)
+	b.mu.Unlock()
+	defer b.wg.Done()
+	for {
+		n, e := l.Accept()
+		if e != nil {
+			b.mu.Lock()
+			delete(b.lsnrs, l)
+			done := b.done
+			b.mu.Unlock()
+			if done {
+				return ErrBrokerClosed
+			}
+			return e
+		}
+		go b.ServeConn(n)
+	}
+}
+
+/*
+	ServeConn serves a single client connection, and returns when that
+	connection ends.  n is always closed.
+*/
+func (b *Broker) ServeConn(n net.Conn) {
+	s := newSession(b, n)
+	b.mu.Lock()
+	if b.done {
+		b.mu.Unlock()
+		_ = n.Close()
+		return
+	}
+	b.sess[s] = true
+	b.wg.Add(1)
+	b.mu.Unlock()
+	defer b.wg.Done()
+	s.serve()
+}
+
+/*
+	Pipe returns the client side of an in-memory net.Pipe, whose broker side
+	is served by b.
+*/
+func (b *Broker) Pipe() net.Conn {
+	cn, sn := net.Pipe()
+	go b.ServeConn(sn)
+	return cn
+}
+
+/*
+	Close stops all listeners, drops all client connections, and waits for
+	all serving goroutines to finish.
+*/
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	b.done = true
+	for l := range b.lsnrs {
+		_ = l.Close()
+	}
+	for s := range b.sess {
+		s.close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+	return nil
+}
+
+/*
+	Pending returns the number of undelivered messages held for a queue.
+*/
+func (b *Broker) Pending(dest string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if d, ok := b.dests[dest]; ok {
+		return len(d.msgs)
+	}
+	return 0
+}
+
+/*
+	Purge discards all undelivered messages held for a queue.
+*/
+func (b *Broker) Purge(dest string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if d, ok := b.dests[dest]; ok {
+		d.msgs = nil
+	}
+}
+
+/*
+	Get or create a destination.  Caller holds b.mu.
+*/
+func (b *Broker) destination(name string) *destination {
+	d, ok := b.dests[name]
+	if !ok {
+		d = &destination{name: name, topic: strings.HasPrefix(name, "/topic/")}
+		b.dests[name] = d
+	}
+	return d
+}
+
+/*
+	Accept a message for a destination.
+*/
+func (b *Broker) publish(m *message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m.id = "stomptest-msg-" + strconv.FormatInt(b.msgid.Add(1), 10)
+	d := b.destination(m.dest)
+	if d.topic {
+		for _, sb := range d.subs {
+			sb.deliver(m)
+		}
+		return
+	}
+	d.msgs = append(d.msgs, m)
+	b.dispatch(d)
+}
+
+/*
+	Deliver held queue messages to subscribers, in round robin order.  Caller
+	holds b.mu.
+*/
+func (b *Broker) dispatch(d *destination) {
+	for len(d.msgs) > 0 && len(d.subs) > 0 {
+		m := d.msgs[0]
+		d.msgs = d.msgs[1:]
+		d.next %= len(d.subs)
+		d.subs[d.next].deliver(m)
+		d.next++
+	}
+}
+
+/*
+	Add a subscription.  Returns false if the subscription id is already in
+	use by the session.
+*/
+func (b *Broker) subscribe(sb *sub, dest string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := sb.s.subs[sb.id]; ok {
+		return false
+	}
+	sb.s.subs[sb.id] = sb
+	sb.d = b.destination(dest)
+	sb.d.subs = append(sb.d.subs, sb)
+	b.dispatch(sb.d)
+	return true
+}
+
+/*
+	Remove a subscription.  Unacknowledged queue messages are held for
+	redelivery, ahead of any messages not yet delivered.
+*/
+func (b *Broker) unsubscribe(sb *sub) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(sb.s.subs, sb.id)
+	d := sb.d
+	for i, x := range d.subs {
+		if x == sb {
+			d.subs = append(d.subs[:i:i], d.subs[i+1:]...)
+			break
+		}
+	}
+	if !d.topic && len(sb.unackd) > 0 {
+		rm := make([]*message, 0, len(sb.unackd)+len(d.msgs))
+		for _, p := range sb.unackd {
+			p.m.redlv = true
+			rm = append(rm, p.m)
+		}
+		d.msgs = append(rm, d.msgs...)
+	}
+	sb.unackd = nil
+	b.dispatch(d)
+}
+
+/*
+	Settle an ACK or NACK.  With cumulative (client mode) acknowledgment,
+	all earlier messages on the subscription are also settled.  Returns false
+	if the ack id is unknown.
+*/
+func (b *Broker) settle(sb *sub, ackid string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, p := range sb.unackd {
+		if p.ackid != ackid {
+			continue
+		}
+		if sb.ack == "client" {
+			sb.unackd = sb.unackd[i+1:]
+		} else {
+			sb.unackd = append(sb.unackd[:i:i], sb.unackd[i+1:]...)
+		}
+		return true
+	}
+	return false
+}
+
+/*
+	Find the subscription holding an unacknowledged message.  Caller does not
+	hold b.mu.
+*/
+func (b *Broker) findAck(s *session, ackid, sid string) *sub {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, sb := range s.subs {
+		if sid != "" && sb.id != sid {
+			continue
+		}
+		for _, p := range sb.unackd {
+			if p.ackid == ackid {
+				return sb
+			}
+		}
+	}
+	return nil
+}
+
+/*
+	Forget a session.
+*/
+func (b *Broker) removeSession(s *session) {
+	b.mu.Lock()
+	delete(b.sess, s)
+	b.mu.Unlock()
+}
+
+/*
+	Deliver a message on a subscription.  Caller holds b.mu.
+*/
+func (sb *sub) deliver(m *message) {
+	f := frame{command: "MESSAGE"}
+	f.add("destination", m.dest)
+	f.add("message-id", m.id)
+	f.add("subscription", sb.id)
+	if m.redlv {
+		f.add("redelivered", "true")
+	}
+	ackid := m.id
+	if sb.s.proto == "1.2" {
+		ackid = sb.s.id + "-" + m.id
+		if sb.ack != "auto" {
+			f.add("ack", ackid)
+		}
+	}
+	f.headers = append(f.headers, m.headers...)
+	f.add("content-length", strconv.Itoa(len(m.body)))
+	f.body = m.body
+	if sb.ack != "auto" {
+		sb.unackd = append(sb.unackd, &pending{ackid, m})
+	}
+	sb.s.enqueue(f)
+}
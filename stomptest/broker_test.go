@@ -0,0 +1,297 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stomptest_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo"
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+const testWait = 2 * time.Second
+
+/*
+	Connect headers for a protocol level.
+*/
+func connHeaders(p string) stompngo.Headers {
+	return stompngo.Headers{stompngo.HK_ACCEPT_VERSION, p,
+		stompngo.HK_HOST, "localhost"}
+}
+
+/*
+	Connect to a broker over a pipe.
+*/
+func pipeConnect(t *testing.T, b *stomptest.Broker, h stompngo.Headers) *stompngo.Connection {
+	c, e := stompngo.Connect(b.Pipe(), h)
+	if e != nil {
+		t.Fatalf("Connect Expected [nil], got [%v]\n", e)
+	}
+	return c
+}
+
+/*
+	Receive a message, or fail.
+*/
+func receive(t *testing.T, sc <-chan stompngo.MessageData) stompngo.Message {
+	select {
+	case md := <-sc:
+		if md.Error != nil {
+			t.Fatalf("receive Expected [nil], got [%v]\n", md.Error)
+		}
+		return md.Message
+	case <-time.After(testWait):
+		t.Fatalf("receive Expected a message, got none\n")
+	}
+	return stompngo.Message{}
+}
+
+/*
+	Check that no message arrives.
+*/
+func receiveNone(t *testing.T, sc <-chan stompngo.MessageData) {
+	select {
+	case md := <-sc:
+		t.Fatalf("receiveNone Expected no message, got [%v]\n", md.Message)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+/*
+	Test a queue round trip over TCP, at all protocol levels, with encoded
+	header values.
+*/
+func TestBrokerQueueRoundTrip(t *testing.T) {
+	s := stomptest.NewServer()
+	defer s.Close()
+	for _, p := range stompngo.Protocols() {
+		n, e := net.Dial(stompngo.NetProtoTCP, s.Addr)
+		if e != nil {
+			t.Fatalf("TestBrokerQueueRoundTrip Dial Expected [nil], got [%v]\n", e)
+		}
+		c, e := stompngo.Connect(n, connHeaders(p))
+		if e != nil {
+			t.Fatalf("TestBrokerQueueRoundTrip Expected [nil], got [%v]\n", e)
+		}
+		if c.Protocol() != p {
+			t.Fatalf("TestBrokerQueueRoundTrip Expected [%s], got [%s]\n", p,
+				c.Protocol())
+		}
+		d := "/queue/stomptest.rt." + p
+		e = c.SendWithReceipt(context.Background(), stompngo.Headers{
+			stompngo.HK_DESTINATION, d, "odd", "a:b"}, "round trip "+p)
+		if e != nil {
+			t.Fatalf("TestBrokerQueueRoundTrip Expected [nil], got [%v]\n", e)
+		}
+		if s.Broker.Pending(d) != 1 {
+			t.Fatalf("TestBrokerQueueRoundTrip Expected 1 pending, got [%d]\n",
+				s.Broker.Pending(d))
+		}
+		sc, e := c.Subscribe(stompngo.Headers{stompngo.HK_DESTINATION, d,
+			stompngo.HK_ID, "rt"})
+		if e != nil {
+			t.Fatalf("TestBrokerQueueRoundTrip Expected [nil], got [%v]\n", e)
+		}
+		m := receive(t, sc)
+		if m.BodyString() != "round trip "+p {
+			t.Fatalf("TestBrokerQueueRoundTrip Expected body, got [%s]\n",
+				m.BodyString())
+		}
+		if v := m.Headers.Value("odd"); v != "a:b" {
+			t.Fatalf("TestBrokerQueueRoundTrip Expected [a:b], got [%s]\n", v)
+		}
+		if v := m.Headers.Value(stompngo.HK_SUBSCRIPTION); v != "rt" {
+			t.Fatalf("TestBrokerQueueRoundTrip Expected [rt], got [%s]\n", v)
+		}
+		if e = c.Disconnect(stompngo.Headers{}); e != nil {
+			t.Fatalf("TestBrokerQueueRoundTrip Expected [nil], got [%v]\n", e)
+		}
+		_ = n.Close()
+	}
+}
+
+/*
+	Test queue round robin delivery, and topic fan out.
+*/
+func TestBrokerQueueTopic(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	c1 := pipeConnect(t, b, connHeaders(stompngo.SPL_12))
+	c2 := pipeConnect(t, b, connHeaders(stompngo.SPL_12))
+	ctx := context.Background()
+	var qc, tc [2]<-chan stompngo.MessageData
+	for i, c := range []*stompngo.Connection{c1, c2} {
+		var e error
+		qc[i], e = c.SubscribeWithReceipt(ctx, stompngo.Headers{
+			stompngo.HK_DESTINATION, "/queue/stomptest.rr", stompngo.HK_ID, "q"})
+		if e != nil {
+			t.Fatalf("TestBrokerQueueTopic Expected [nil], got [%v]\n", e)
+		}
+		tc[i], e = c.SubscribeWithReceipt(ctx, stompngo.Headers{
+			stompngo.HK_DESTINATION, "/topic/stomptest.fan", stompngo.HK_ID, "t"})
+		if e != nil {
+			t.Fatalf("TestBrokerQueueTopic Expected [nil], got [%v]\n", e)
+		}
+	}
+	for _, m := range []string{"q1", "q2"} {
+		_ = c1.Send(stompngo.Headers{stompngo.HK_DESTINATION, "/queue/stomptest.rr"}, m)
+	}
+	_ = c1.Send(stompngo.Headers{stompngo.HK_DESTINATION, "/topic/stomptest.fan"}, "t1")
+	for i := range qc {
+		receive(t, qc[i])
+		if m := receive(t, tc[i]); m.BodyString() != "t1" {
+			t.Fatalf("TestBrokerQueueTopic Expected [t1], got [%s]\n", m.BodyString())
+		}
+	}
+	receiveNone(t, qc[0])
+	_ = c1.Disconnect(stompngo.Headers{})
+	_ = c2.Disconnect(stompngo.Headers{})
+}
+
+/*
+	Test client ack mode: a cumulative ACK, and redelivery of unacknowledged
+	messages after a disconnect.
+*/
+func TestBrokerClientAck(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	d := "/queue/stomptest.ack"
+	c := pipeConnect(t, b, connHeaders(stompngo.SPL_12))
+	for _, m := range []string{"a1", "a2", "a3"} {
+		_ = c.Send(stompngo.Headers{stompngo.HK_DESTINATION, d}, m)
+	}
+	sh := stompngo.Headers{stompngo.HK_DESTINATION, d, stompngo.HK_ID, "ack",
+		stompngo.HK_ACK, stompngo.AckModeClient}
+	sc, _ := c.Subscribe(sh)
+	receive(t, sc)
+	m := receive(t, sc)
+	e := c.AckWithReceipt(context.Background(), stompngo.Headers{stompngo.HK_ID,
+		m.Headers.Value(stompngo.HK_ACK)})
+	if e != nil {
+		t.Fatalf("TestBrokerClientAck Expected [nil], got [%v]\n", e)
+	}
+	receive(t, sc) // a3, never acknowledged
+	_ = c.Disconnect(stompngo.Headers{})
+	//
+	c = pipeConnect(t, b, connHeaders(stompngo.SPL_11))
+	sc, _ = c.Subscribe(sh)
+	m = receive(t, sc)
+	if m.BodyString() != "a3" || m.Headers.Value("redelivered") != "true" {
+		t.Fatalf("TestBrokerClientAck Expected redelivered [a3], got [%v]\n", m)
+	}
+	receiveNone(t, sc)
+	_ = c.Disconnect(stompngo.Headers{})
+}
+
+/*
+	Test transactions: an aborted SEND is discarded, a committed SEND is
+	delivered.
+*/
+func TestBrokerTransaction(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	d := "/queue/stomptest.tx"
+	c := pipeConnect(t, b, connHeaders(stompngo.SPL_12))
+	sc, _ := c.Subscribe(stompngo.Headers{stompngo.HK_DESTINATION, d,
+		stompngo.HK_ID, "tx"})
+	for _, tx := range []string{"tx-abort", "tx-commit"} {
+		th := stompngo.Headers{stompngo.HK_TRANSACTION, tx}
+		_ = c.Begin(th)
+		_ = c.Send(th.Add(stompngo.HK_DESTINATION, d), tx)
+		receiveNone(t, sc)
+		if tx == "tx-abort" {
+			_ = c.Abort(th)
+		} else {
+			_ = c.Commit(th)
+		}
+	}
+	if m := receive(t, sc); m.BodyString() != "tx-commit" {
+		t.Fatalf("TestBrokerTransaction Expected [tx-commit], got [%s]\n",
+			m.BodyString())
+	}
+	_ = c.Disconnect(stompngo.Headers{})
+}
+
+/*
+	Test protocol errors are reported with an ERROR frame carrying the
+	receipt-id.
+*/
+func TestBrokerError(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	c := pipeConnect(t, b, connHeaders(stompngo.SPL_12))
+	e := c.CommitWithReceipt(context.Background(),
+		stompngo.Headers{stompngo.HK_TRANSACTION, "no-such-tx"})
+	be, ok := e.(*stompngo.BrokerError)
+	if !ok {
+		t.Fatalf("TestBrokerError Expected *BrokerError, got [%v]\n", e)
+	}
+	if v := be.Message.Headers.Value(stompngo.HK_MESSAGE); v != "transaction not active" {
+		t.Fatalf("TestBrokerError Expected message header, got [%s]\n", v)
+	}
+}
+
+/*
+	Test credentials are enforced.
+*/
+func TestBrokerCredentials(t *testing.T) {
+	b := stomptest.NewBroker(stomptest.WithCredentials("user", "pw"))
+	defer b.Close()
+	h := connHeaders(stompngo.SPL_12).Add(stompngo.HK_LOGIN, "user")
+	_, e := stompngo.Connect(b.Pipe(), h.Add(stompngo.HK_PASSCODE, "bad"))
+	if e == nil {
+		t.Fatalf("TestBrokerCredentials Expected an error, got [nil]\n")
+	}
+	c := pipeConnect(t, b, h.Add(stompngo.HK_PASSCODE, "pw"))
+	_ = c.Disconnect(stompngo.Headers{})
+}
+
+/*
+	Test heart beats: a connection with heart beats in both directions stays
+	up, and a silent client is dropped.
+*/
+func TestBrokerHeartBeats(t *testing.T) {
+	b := stomptest.NewBroker(stomptest.WithHeartBeat(50*time.Millisecond,
+		50*time.Millisecond))
+	defer b.Close()
+	c := pipeConnect(t, b, connHeaders(stompngo.SPL_12).Add(stompngo.HK_HEART_BEAT,
+		"50,50"))
+	time.Sleep(300 * time.Millisecond)
+	e := c.SendWithReceipt(context.Background(), stompngo.Headers{
+		stompngo.HK_DESTINATION, "/queue/stomptest.hb"}, "alive")
+	if e != nil {
+		t.Fatalf("TestBrokerHeartBeats Expected [nil], got [%v]\n", e)
+	}
+	_ = c.Disconnect(stompngo.Headers{})
+	//
+	n := b.Pipe()
+	_, _ = n.Write([]byte("CONNECT\naccept-version:1.2\nhost:localhost\n" +
+		"heart-beat:50,0\n\n\x00"))
+	_ = n.SetReadDeadline(time.Now().Add(testWait))
+	bf := make([]byte, 512)
+	for {
+		if _, e := n.Read(bf); e != nil {
+			if ne, ok := e.(net.Error); ok && ne.Timeout() {
+				t.Fatalf("TestBrokerHeartBeats Expected a dropped connection\n")
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,239 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stomptest
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+	A broker side STOMP frame.  Headers are key / value pairs, in wire order,
+	already decoded.
+*/
+type frame struct {
+	command string
+	headers []string
+	body    []byte
+}
+
+var (
+	errBadHeader  = errors.New("malformed header line")
+	errBadEscape  = errors.New("undefined escape sequence")
+	errBadLength  = errors.New("invalid content-length")
+	errNoTerm     = errors.New("frame not NUL terminated")
+	errBadCommand = errors.New("unknown command")
+)
+
+/*
+	Client frames the broker understands.
+*/
+var clientCommands = map[string]bool{
+	"CONNECT": true, "STOMP": true, "SEND": true, "SUBSCRIBE": true,
+	"UNSUBSCRIBE": true, "ACK": true, "NACK": true, "BEGIN": true,
+	"COMMIT": true, "ABORT": true, "DISCONNECT": true,
+}
+
+/*
+	First value for a header key.
+*/
+func (f *frame) value(k string) (string, bool) {
+	for i := 0; i < len(f.headers); i += 2 {
+		if f.headers[i] == k {
+			return f.headers[i+1], true
+		}
+	}
+	return "", false
+}
+
+/*
+	Check for a header key / value pair.
+*/
+func (f *frame) contains(k, v string) bool {
+	for i := 0; i < len(f.headers); i += 2 {
+		if f.headers[i] == k && f.headers[i+1] == v {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Append a header.
+*/
+func (f *frame) add(k, v string) {
+	f.headers = append(f.headers, k, v)
+}
+
+/*
+	Read one frame.  Heart beat EOLs are consumed, and reported through hb.
+	Header values are decoded for protocol levels 1.1 and 1.2, except in the
+	CONNECT and STOMP frames.
+*/
+func readFrame(r *bufio.Reader, proto string, hb func()) (frame, error) {
+	var f frame
+	for {
+		s, e := r.ReadString('\n')
+		if e != nil {
+			return f, e
+		}
+		hb()
+		s = strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r")
+		if s != "" {
+			f.command = s
+			break
+		}
+	}
+	if !clientCommands[f.command] {
+		return f, errBadCommand
+	}
+	esc := proto != "1.0" && f.command != "CONNECT" && f.command != "STOMP"
+	for {
+		s, e := r.ReadString('\n')
+		if e != nil {
+			return f, e
+		}
+		hb()
+		s = strings.TrimSuffix(s, "\n")
+		if proto == "1.2" {
+			s = strings.TrimSuffix(s, "\r")
+		}
+		if s == "" {
+			break
+		}
+		k, v, ok := strings.Cut(s, ":")
+		if !ok {
+			return f, errBadHeader
+		}
+		if esc {
+			if k, e = unescape(k); e != nil {
+				return f, e
+			}
+			if v, e = unescape(v); e != nil {
+				return f, e
+			}
+		}
+		f.add(k, v)
+	}
+	if cl, ok := f.value("content-length"); ok {
+		l, e := strconv.Atoi(strings.TrimSpace(cl))
+		if e != nil || l < 0 {
+			return f, errBadLength
+		}
+		f.body = make([]byte, l+1)
+		if _, e = io.ReadFull(r, f.body); e != nil {
+			return f, e
+		}
+		if f.body[l] != 0 {
+			return f, errNoTerm
+		}
+		f.body = f.body[:l]
+	} else {
+		b, e := r.ReadBytes(0)
+		if e != nil {
+			return f, e
+		}
+		f.body = b[:len(b)-1]
+	}
+	hb()
+	return f, nil
+}
+
+/*
+	Write one frame.  Header values are encoded for protocol levels 1.1 and
+	1.2, except in the CONNECTED frame.
+*/
+func writeFrame(w *bufio.Writer, proto string, f frame) error {
+	esc := proto != "1.0" && f.command != "CONNECTED"
+	_, _ = w.WriteString(f.command + "\n")
+	for i := 0; i < len(f.headers); i += 2 {
+		k, v := f.headers[i], f.headers[i+1]
+		if esc {
+			k, v = escape(k, proto), escape(v, proto)
+		}
+		_, _ = w.WriteString(k + ":" + v + "\n")
+	}
+	_ = w.WriteByte('\n')
+	_, _ = w.Write(f.body)
+	_ = w.WriteByte(0)
+	return w.Flush()
+}
+
+/*
+	Encode a header key or value.
+*/
+func escape(s, proto string) string {
+	if !strings.ContainsAny(s, "\\\n\r:") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case ':':
+			b.WriteString(`\c`)
+		case '\r':
+			if proto == "1.2" {
+				b.WriteString(`\r`)
+			} else {
+				b.WriteByte('\r')
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+/*
+	Decode a header key or value.
+*/
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return s, errBadEscape
+		}
+		switch s[i] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'c':
+			b.WriteByte(':')
+		default:
+			return s, errBadEscape
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,280 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stomptest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+/*
+	Script is a scripted fake STOMP server, for protocol edge cases a
+	well behaved Broker never produces.  A Script is a list of steps, run in
+	order against a single client connection.  Steps that write send exactly
+	the bytes given, so malformed frames, partial frames, and dropped
+	connections are all possible.
+
+	Build a Script with NewScript and the step methods, which all return the
+	Script for chaining.  A Script may be run any number of times.
+
+	Example:
+		s := stomptest.NewScript().
+			Expect("CONNECT").
+			Write("CONNECTED\nversion:1.2\n\nbody not allowed\x00")
+		n, rc := s.Pipe()
+		_, e := stompngo.Connect(n, h) // e is EBDYDATA
+		if se := <-rc; se != nil {
+			// The client did not behave as scripted
+		}
+*/
+type Script struct {
+	proto string
+	steps []scriptStep
+}
+
+type scriptStep struct {
+	name string
+	run  func(*scriptRun) error
+}
+
+/*
+	Per run state.
+*/
+type scriptRun struct {
+	n    net.Conn
+	r    *bufio.Reader
+	last frame // Latest frame read
+}
+
+/*
+	NewScript returns an empty Script.  Header values in frames the client
+	sends are decoded as STOMP 1.2 values.
+*/
+func NewScript() *Script {
+	return &Script{proto: "1.2"}
+}
+
+/*
+	Protocol sets the protocol level used to decode header values in frames
+	the client sends.
+*/
+func (s *Script) Protocol(p string) *Script {
+	s.proto = p
+	return s
+}
+
+/*
+	Expect reads one frame from the client, skipping heart beats.  The step
+	fails unless the frame has the given command, and contains each of the
+	given header key / value pairs.
+*/
+func (s *Script) Expect(command string, headers ...string) *Script {
+	return s.add("Expect "+command, func(sr *scriptRun) error {
+		p := s.proto
+		if command == "CONNECT" || command == "STOMP" {
+			p = ""
+		}
+		f, e := readFrame(sr.r, p, func() {})
+		if e != nil {
+			return e
+		}
+		sr.last = f
+		if f.command != command {
+			return fmt.Errorf("got command %q", f.command)
+		}
+		for i := 0; i+1 < len(headers); i += 2 {
+			if !f.contains(headers[i], headers[i+1]) {
+				return fmt.Errorf("header %s:%s missing", headers[i], headers[i+1])
+			}
+		}
+		return nil
+	})
+}
+
+/*
+	ExpectHeartBeat reads a single client heart beat.
+*/
+func (s *Script) ExpectHeartBeat() *Script {
+	return s.add("ExpectHeartBeat", func(sr *scriptRun) error {
+		b, e := sr.r.ReadByte()
+		if e == nil && b == '\r' {
+			b, e = sr.r.ReadByte()
+		}
+		if e != nil {
+			return e
+		}
+		if b != '\n' {
+			return fmt.Errorf("got byte %q", b)
+		}
+		return nil
+	})
+}
+
+/*
+	Write sends raw bytes, exactly as given.
+*/
+func (s *Script) Write(raw string) *Script {
+	return s.add("Write", func(sr *scriptRun) error {
+		_, e := io.WriteString(sr.n, raw)
+		return e
+	})
+}
+
+/*
+	Frame sends a well formed frame.  Header key / value pairs are sent as
+	given, without encoding, followed by a content-length header.
+*/
+func (s *Script) Frame(command, body string, headers ...string) *Script {
+	var b strings.Builder
+	b.WriteString(command + "\n")
+	for i := 0; i+1 < len(headers); i += 2 {
+		b.WriteString(headers[i] + ":" + headers[i+1] + "\n")
+	}
+	fmt.Fprintf(&b, "content-length:%d\n\n%s\x00", len(body), body)
+	return s.Write(b.String())
+}
+
+/*
+	Connected sends a CONNECTED frame, with the given header key / value
+	pairs.
+*/
+func (s *Script) Connected(headers ...string) *Script {
+	var b strings.Builder
+	b.WriteString("CONNECTED\n")
+	for i := 0; i+1 < len(headers); i += 2 {
+		b.WriteString(headers[i] + ":" + headers[i+1] + "\n")
+	}
+	b.WriteString("\n\x00")
+	return s.Write(b.String())
+}
+
+/*
+	Receipt sends a RECEIPT for the latest frame read, if that frame
+	requested one.
+*/
+func (s *Script) Receipt() *Script {
+	return s.add("Receipt", func(sr *scriptRun) error {
+		r, ok := sr.last.value("receipt")
+		if !ok {
+			return nil
+		}
+		_, e := io.WriteString(sr.n, "RECEIPT\nreceipt-id:"+r+"\n\n\x00")
+		return e
+	})
+}
+
+/*
+	Drip sends raw bytes in chunks of the given size, pausing between
+	chunks.
+*/
+func (s *Script) Drip(raw string, chunk int, every time.Duration) *Script {
+	return s.add("Drip", func(sr *scriptRun) error {
+		for len(raw) > 0 {
+			l := min(chunk, len(raw))
+			if _, e := io.WriteString(sr.n, raw[:l]); e != nil {
+				return e
+			}
+			raw = raw[l:]
+			if len(raw) > 0 {
+				time.Sleep(every)
+			}
+		}
+		return nil
+	})
+}
+
+/*
+	HeartBeats sends count heart beats, pausing before each.
+*/
+func (s *Script) HeartBeats(count int, every time.Duration) *Script {
+	return s.add("HeartBeats", func(sr *scriptRun) error {
+		for i := 0; i < count; i++ {
+			time.Sleep(every)
+			if _, e := io.WriteString(sr.n, "\n"); e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+}
+
+/*
+	Pause does nothing for a while.  Any client heart beats are not read.
+*/
+func (s *Script) Pause(d time.Duration) *Script {
+	return s.add("Pause", func(sr *scriptRun) error {
+		time.Sleep(d)
+		return nil
+	})
+}
+
+/*
+	Drop closes the connection immediately, possibly part way through a frame.
+*/
+func (s *Script) Drop() *Script {
+	return s.add("Drop", func(sr *scriptRun) error {
+		return sr.n.Close()
+	})
+}
+
+/*
+	WaitClose reads and discards client data until the client closes the
+	connection.
+*/
+func (s *Script) WaitClose() *Script {
+	return s.add("WaitClose", func(sr *scriptRun) error {
+		_, e := io.Copy(io.Discard, sr.r)
+		return e
+	})
+}
+
+/*
+	Run runs the Script against n, the server side of a client connection.
+	n is closed when the Script ends.  Returns the first step error.
+*/
+func (s *Script) Run(n net.Conn) error {
+	defer n.Close()
+	sr := &scriptRun{n: n, r: bufio.NewReader(n)}
+	for i, st := range s.steps {
+		if e := st.run(sr); e != nil {
+			return fmt.Errorf("stomptest: script step %d (%s): %w", i, st.name, e)
+		}
+	}
+	return nil
+}
+
+/*
+	Pipe runs the Script against an in-memory net.Pipe.  It returns the
+	client side of the pipe, and a channel that receives the result of Run.
+*/
+func (s *Script) Pipe() (net.Conn, <-chan error) {
+	cn, sn := net.Pipe()
+	rc := make(chan error, 1)
+	go func() {
+		rc <- s.Run(sn)
+	}()
+	return cn, rc
+}
+
+func (s *Script) add(name string, run func(*scriptRun) error) *Script {
+	s.steps = append(s.steps, scriptStep{name, run})
+	return s
+}
@@ -0,0 +1,61 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stomptest
+
+import (
+	"net"
+)
+
+/*
+	Server is a Broker listening on a loopback TCP port.
+*/
+type Server struct {
+	Addr   string // host:port, for use with net.Dial
+	Broker *Broker
+	l      net.Listener
+}
+
+/*
+	NewServer starts a new Broker, listening on a system chosen loopback port.
+	It panics if no port is available.
+*/
+func NewServer(opts ...BrokerOption) *Server {
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		panic("stomptest: failed to listen on a port: " + e.Error())
+	}
+	return NewServerListener(l, opts...)
+}
+
+/*
+	NewServerListener starts a new Broker, serving connections from l.
+*/
+func NewServerListener(l net.Listener, opts ...BrokerOption) *Server {
+	s := &Server{Addr: l.Addr().String(), Broker: NewBroker(opts...), l: l}
+	go func() {
+		_ = s.Broker.Serve(l)
+	}()
+	return s
+}
+
+/*
+	Close shuts the Server down.  See Broker.Close.
+*/
+func (s *Server) Close() error {
+	_ = s.l.Close()
+	return s.Broker.Close()
+}
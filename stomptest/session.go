@@ -0,0 +1,525 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stomptest
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	A single client connection.
+*/
+type session struct {
+	b     *Broker
+	n     net.Conn
+	r     *bufio.Reader
+	w     *bufio.Writer
+	id    string
+	proto string
+	subs  map[string]*sub    // Subscriptions by id, protected by b.mu
+	txs   map[string][]frame // Open transactions, reader goroutine only
+	//
+	qmu    sync.Mutex // Protects outq and qdone
+	outq   []outbound // Frames waiting for the writer
+	qdone  bool       // No further frames are accepted
+	kick   chan struct{}
+	closed chan struct{}
+	once   sync.Once
+	//
+	hbs time.Duration // Negotiated heart beat send interval
+	hbr time.Duration // Negotiated heart beat receive interval
+	lr  atomic.Int64  // Time of the latest read, UnixNano
+}
+
+/*
+	A frame waiting for the writer.  If last is set the connection is closed
+	after the frame is written.
+*/
+type outbound struct {
+	f    frame
+	last bool
+}
+
+/*
+	A client request the broker rejects with an ERROR frame.
+*/
+type protoError struct {
+	msg    string // message header
+	detail string // ERROR body
+}
+
+var supportedVersions = []string{"1.2", "1.1", "1.0"}
+
+func newSession(b *Broker, n net.Conn) *session {
+	return &session{b: b, n: n,
+		r:      bufio.NewReader(n),
+		w:      bufio.NewWriter(n),
+		id:     "stomptest-sess-" + strconv.FormatInt(b.sessid.Add(1), 10),
+		proto:  "1.0",
+		subs:   make(map[string]*sub),
+		txs:    make(map[string][]frame),
+		kick:   make(chan struct{}, 1),
+		closed: make(chan struct{})}
+}
+
+/*
+	Serve the connection: handshake, then frames until DISCONNECT, an error,
+	or Close.
+*/
+func (s *session) serve() {
+	defer s.b.removeSession(s)
+	defer s.close()
+	s.lr.Store(time.Now().UnixNano())
+	f, e := readFrame(s.r, "", s.touch)
+	var pe *protoError
+	if e != nil {
+		pe = &protoError{"malformed frame received", e.Error()}
+	} else {
+		pe = s.connect(f)
+	}
+	wd := make(chan struct{})
+	go func() { // Heart beat intervals are now known
+		defer close(wd)
+		s.writer()
+	}()
+	defer func() { <-wd }()
+	if pe != nil {
+		if e != io.EOF {
+			s.sendError(f, pe)
+		}
+		return
+	}
+	if s.hbr > 0 {
+		go s.monitor()
+	}
+	defer s.dropSubs()
+	for {
+		f, e = readFrame(s.r, s.proto, s.touch)
+		if e != nil {
+			select {
+			case <-s.closed:
+			default:
+				if e != io.EOF {
+					s.sendError(f, &protoError{"malformed frame received", e.Error()})
+				}
+			}
+			return
+		}
+		if f.command == "DISCONNECT" {
+			s.enqueueLast(receiptFor(f))
+			return
+		}
+		if pe := s.handle(f); pe != nil {
+			s.sendError(f, pe)
+			return
+		}
+		if r, ok := f.value("receipt"); ok {
+			rf := frame{command: "RECEIPT"}
+			rf.add("receipt-id", r)
+			s.enqueue(rf)
+		}
+	}
+}
+
+/*
+	Handle CONNECT or STOMP, and send CONNECTED.
+*/
+func (s *session) connect(f frame) *protoError {
+	if f.command != "CONNECT" && f.command != "STOMP" {
+		return &protoError{"connection not established", "expected CONNECT, got " +
+			f.command}
+	}
+	s.proto = ""
+	av, ok := f.value("accept-version")
+	if !ok {
+		av = "1.0"
+	}
+	for _, v := range supportedVersions {
+		for _, cv := range strings.Split(av, ",") {
+			if strings.TrimSpace(cv) == v && s.proto == "" {
+				s.proto = v
+			}
+		}
+	}
+	if s.proto == "" {
+		s.proto = "1.0"
+		return &protoError{"supported protocol versions are 1.0,1.1,1.2", ""}
+	}
+	if s.b.login != "" {
+		l, _ := f.value("login")
+		p, _ := f.value("passcode")
+		if l != s.b.login || p != s.b.passcode {
+			return &protoError{"access refused", "invalid login or passcode"}
+		}
+	}
+	c := frame{command: "CONNECTED"}
+	if s.proto != "1.0" {
+		c.add("version", s.proto)
+		hb, pe := s.heartBeats(f)
+		if pe != nil {
+			return pe
+		}
+		c.add("heart-beat", hb)
+	}
+	c.add("session", s.id)
+	c.add("server", s.b.svrname)
+	s.enqueue(c)
+	return nil
+}
+
+/*
+	Negotiate heart beats.  Returns the CONNECTED heart-beat value.
+*/
+func (s *session) heartBeats(f frame) (string, *protoError) {
+	var cx, cy int64
+	if v, ok := f.value("heart-beat"); ok {
+		p := strings.Split(v, ",")
+		var e1, e2 error
+		if len(p) == 2 {
+			cx, e1 = strconv.ParseInt(strings.TrimSpace(p[0]), 10, 64)
+			cy, e2 = strconv.ParseInt(strings.TrimSpace(p[1]), 10, 64)
+		}
+		if len(p) != 2 || e1 != nil || e2 != nil || cx < 0 || cy < 0 {
+			return "", &protoError{"invalid heart-beat header", v}
+		}
+	}
+	sx, sy := cy, cx // Mirror the client
+	if s.b.hbset {
+		sx, sy = s.b.hbsx.Milliseconds(), s.b.hbsy.Milliseconds()
+	}
+	if sx > 0 && cy > 0 {
+		s.hbs = time.Duration(max(sx, cy)) * time.Millisecond
+	}
+	if cx > 0 && sy > 0 {
+		s.hbr = time.Duration(max(cx, sy)) * time.Millisecond
+	}
+	return strconv.FormatInt(sx, 10) + "," + strconv.FormatInt(sy, 10), nil
+}
+
+/*
+	Handle a single client frame after the handshake.
+*/
+func (s *session) handle(f frame) *protoError {
+	switch f.command {
+	case "SEND":
+		d, ok := f.value("destination")
+		if !ok {
+			return &protoError{"destination header missing", "SEND"}
+		}
+		if t, ok := f.value("transaction"); ok {
+			return s.addTx(t, f)
+		}
+		s.b.publish(newMessage(d, f))
+	case "SUBSCRIBE":
+		return s.subscribe(f)
+	case "UNSUBSCRIBE":
+		id, ok := f.value("id")
+		if !ok && s.proto == "1.0" {
+			id, ok = f.value("destination")
+		}
+		if !ok {
+			return &protoError{"id header missing", "UNSUBSCRIBE"}
+		}
+		s.b.mu.Lock()
+		sb := s.subs[id]
+		s.b.mu.Unlock()
+		if sb != nil {
+			s.b.unsubscribe(sb)
+		}
+	case "ACK", "NACK":
+		sb, ackid, pe := s.ackTarget(f)
+		if pe != nil {
+			return pe
+		}
+		if t, ok := f.value("transaction"); ok {
+			return s.addTx(t, f)
+		}
+		s.b.settle(sb, ackid)
+	case "BEGIN":
+		t, ok := f.value("transaction")
+		if !ok {
+			return &protoError{"transaction header missing", "BEGIN"}
+		}
+		if _, ok := s.txs[t]; ok {
+			return &protoError{"transaction already started", t}
+		}
+		s.txs[t] = []frame{}
+	case "COMMIT", "ABORT":
+		t, ok := f.value("transaction")
+		if !ok {
+			return &protoError{"transaction header missing", f.command}
+		}
+		tf, ok := s.txs[t]
+		if !ok {
+			return &protoError{"transaction not active", t}
+		}
+		delete(s.txs, t)
+		if f.command == "ABORT" {
+			break
+		}
+		for _, x := range tf {
+			if x.command == "SEND" {
+				d, _ := x.value("destination")
+				s.b.publish(newMessage(d, x))
+				continue
+			}
+			if sb, ackid, pe := s.ackTarget(x); pe == nil {
+				s.b.settle(sb, ackid)
+			}
+		}
+	default:
+		return &protoError{"unexpected command", f.command}
+	}
+	return nil
+}
+
+/*
+	Handle SUBSCRIBE.
+*/
+func (s *session) subscribe(f frame) *protoError {
+	d, ok := f.value("destination")
+	if !ok {
+		return &protoError{"destination header missing", "SUBSCRIBE"}
+	}
+	id, ok := f.value("id")
+	if !ok {
+		if s.proto != "1.0" {
+			return &protoError{"id header missing", "SUBSCRIBE"}
+		}
+		id = d
+	}
+	am, ok := f.value("ack")
+	if !ok {
+		am = "auto"
+	}
+	if am != "auto" && am != "client" &&
+		(am != "client-individual" || s.proto == "1.0") {
+		return &protoError{"invalid ack mode", am}
+	}
+	sb := &sub{s: s, id: id, ack: am}
+	if !s.b.subscribe(sb, d) {
+		return &protoError{"duplicate subscription id", id}
+	}
+	return nil
+}
+
+/*
+	Find the subscription and ack id an ACK or NACK refers to.
+*/
+func (s *session) ackTarget(f frame) (*sub, string, *protoError) {
+	var ackid, sid string
+	var ok bool
+	if s.proto == "1.2" {
+		ackid, ok = f.value("id")
+	} else {
+		ackid, ok = f.value("message-id")
+		sid, _ = f.value("subscription")
+	}
+	if !ok {
+		return nil, "", &protoError{"ack id missing", f.command}
+	}
+	sb := s.b.findAck(s, ackid, sid)
+	if sb == nil {
+		return nil, "", &protoError{"unknown message to acknowledge", ackid}
+	}
+	return sb, ackid, nil
+}
+
+/*
+	Add a frame to an open transaction.
+*/
+func (s *session) addTx(t string, f frame) *protoError {
+	if _, ok := s.txs[t]; !ok {
+		return &protoError{"transaction not active", t}
+	}
+	s.txs[t] = append(s.txs[t], f)
+	return nil
+}
+
+/*
+	End all subscriptions for the session.
+*/
+func (s *session) dropSubs() {
+	s.b.mu.Lock()
+	sl := make([]*sub, 0, len(s.subs))
+	for _, sb := range s.subs {
+		sl = append(sl, sb)
+	}
+	s.b.mu.Unlock()
+	for _, sb := range sl {
+		s.b.unsubscribe(sb)
+	}
+}
+
+/*
+	Send an ERROR frame for a rejected client frame, and close the connection.
+*/
+func (s *session) sendError(f frame, pe *protoError) {
+	ef := frame{command: "ERROR"}
+	ef.add("message", pe.msg)
+	if r, ok := f.value("receipt"); ok {
+		ef.add("receipt-id", r)
+	}
+	ef.add("content-type", "text/plain")
+	ef.add("content-length", strconv.Itoa(len(pe.detail)))
+	ef.body = []byte(pe.detail)
+	s.enqueueLast(ef)
+}
+
+/*
+	The RECEIPT for a frame, or an empty frame if none was requested.
+*/
+func receiptFor(f frame) frame {
+	r, ok := f.value("receipt")
+	if !ok {
+		return frame{}
+	}
+	rf := frame{command: "RECEIPT"}
+	rf.add("receipt-id", r)
+	return rf
+}
+
+/*
+	Queue a frame for the writer.
+*/
+func (s *session) enqueue(f frame) {
+	s.push(outbound{f, false})
+}
+
+/*
+	Queue the final frame for the writer, which then closes the connection.
+	An empty frame only marks the end of the connection.
+*/
+func (s *session) enqueueLast(f frame) {
+	s.push(outbound{f, true})
+}
+
+func (s *session) push(o outbound) {
+	s.qmu.Lock()
+	if s.qdone {
+		s.qmu.Unlock()
+		return
+	}
+	s.outq = append(s.outq, o)
+	s.qdone = o.last
+	s.qmu.Unlock()
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+/*
+	Write queued frames, and heart beats.
+*/
+func (s *session) writer() {
+	var tc <-chan time.Time
+	if s.hbs > 0 {
+		t := time.NewTicker(s.hbs)
+		defer t.Stop()
+		tc = t.C
+	}
+	for {
+		select {
+		case <-s.kick:
+			s.qmu.Lock()
+			q := s.outq
+			s.outq = nil
+			s.qmu.Unlock()
+			for _, o := range q {
+				if o.f.command != "" {
+					if writeFrame(s.w, s.proto, o.f) != nil {
+						s.close()
+						return
+					}
+				}
+				if o.last {
+					s.close()
+					return
+				}
+			}
+		case <-tc:
+			_ = s.w.WriteByte('\n')
+			if s.w.Flush() != nil {
+				s.close()
+				return
+			}
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+/*
+	Drop the connection if the client stops sending heart beats.
+*/
+func (s *session) monitor() {
+	lim := time.Duration(float64(s.hbr) * s.b.hbgrace)
+	t := time.NewTicker(s.hbr)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if time.Since(time.Unix(0, s.lr.Load())) > lim {
+				s.close()
+				return
+			}
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+/*
+	Note a read, for heart beat monitoring.
+*/
+func (s *session) touch() {
+	s.lr.Store(time.Now().UnixNano())
+}
+
+/*
+	Close the connection.
+*/
+func (s *session) close() {
+	s.once.Do(func() {
+		close(s.closed)
+		_ = s.n.Close()
+	})
+}
+
+/*
+	Create a message from a SEND frame.
+*/
+func newMessage(d string, f frame) *message {
+	m := &message{dest: d, body: f.body}
+	for i := 0; i < len(f.headers); i += 2 {
+		switch f.headers[i] {
+		case "destination", "message-id", "subscription", "ack", "transaction",
+			"receipt", "content-length", "redelivered":
+			continue
+		}
+		m.headers = append(m.headers, f.headers[i], f.headers[i+1])
+	}
+	return m
+}
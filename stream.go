@@ -0,0 +1,275 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+/*
+	Write chunk size for streamed bodies.  The write deadline, if any, is
+	reset before each chunk.
+*/
+const streamChunk = 32 * 1024
+
+/*
+	A SEND body streamed from an io.Reader.
+*/
+type sendStream struct {
+	r io.Reader
+	n int64 // Body length, sent as the content-length
+}
+
+/*
+	SendStream sends a STOMP MESSAGE with a body of exactly length bytes read
+	from r.  The body is copied to the network as it is read, and is never
+	held in memory as a whole.
+
+	Headers MUST contain a "destination" header key.  A content-length header
+	of length is always sent, so any content-length or
+	suppress-content-length header supplied is replaced.
+
+	If r ends before length bytes, a partial frame is already on the wire,
+	and the connection is shut down.  The error returned wraps ESTRMSHORT.
+
+	Example:
+		f, e := os.Open("large.bin")
+		if e != nil {
+			// Do something sane ...
+		}
+		defer f.Close()
+		st, _ := f.Stat()
+		h := stompngo.Headers{stompngo.HK_DESTINATION, "/queue/large"}
+		e = c.SendStream(h, f, st.Size())
+		if e != nil {
+			// Do something sane ...
+		}
+*/
+func (c *Connection) SendStream(h Headers, r io.Reader, length int64) error {
+	return c.SendStreamContext(context.Background(), h, r, length)
+}
+
+/*
+	SendStreamContext sends a STOMP MESSAGE with a streamed body, honoring
+	ctx cancellation and deadlines.
+
+	If ctx is done first, ctx.Err() is returned.  See SendStream for details.
+*/
+func (c *Connection) SendStreamContext(ctx context.Context, h Headers, r io.Reader,
+	length int64) error {
+	return c.sendStream(ctx, h, r, length, false)
+}
+
+/*
+	SendStream, waiting for the broker's RECEIPT if rcpt is set.
+*/
+func (c *Connection) sendStream(ctx context.Context, h Headers, r io.Reader,
+	length int64, rcpt bool) error {
+	c.logcmd(SEND, "start", h)
+	if !c.isConnected() {
+		return ECONBAD
+	}
+	if length < 0 {
+		return ESTRMLEN
+	}
+	e := checkHeaders(h, c.Protocol())
+	if e != nil {
+		return e
+	}
+	if _, ok := h.Contains(HK_DESTINATION); !ok {
+		return EREQDSTSND
+	}
+	ch, end := c.traceSend(ctx, h.Delete(HK_SUPPRESS_CL))
+	f := Frame{SEND, ch, NULLBUFF}
+	e = c.transmitStream(ctx, f, &sendStream{r, length}, rcpt)
+	end(e)
+	c.logcmd(SEND, "end", ch)
+	return e // nil or not
+}
+
+/*
+	SendStreamWithReceipt sends a STOMP MESSAGE with a streamed body, and
+	waits for the broker's RECEIPT.  See SendWithReceipt and SendStream for
+	details.
+*/
+func (c *Connection) SendStreamWithReceipt(ctx context.Context, h Headers, r io.Reader,
+	length int64) error {
+	return c.sendStream(ctx, h, r, length, true)
+}
+
+/*
+	Copy a streamed body to the wire.  A short stream leaves a partial frame
+	on the wire, so the connection is shut down.
+*/
+func (c *Connection) writeStream(w *bufio.Writer, bs *sendStream) error {
+	var st int64
+	if c.eltd != nil {
+		st = time.Now().UnixNano()
+	}
+	for rem := bs.n; rem > 0; {
+		c.armWriteDeadline()
+		k, e := io.CopyN(w, bs.r, min(rem, streamChunk))
+		rem -= k
+		if e == io.EOF {
+			c.logl(slog.LevelWarn, "SHORT STREAM", "written", bs.n-rem, "expected", bs.n)
+			c.sysAbort()
+			return fmt.Errorf("%w: %d of %d bytes", ESTRMSHORT, bs.n-rem, bs.n)
+		}
+		if c.checkWriteError(e) != nil {
+			return e
+		}
+	}
+	if c.eltd != nil {
+		c.eltd.add(&c.eltd.wbdy, "WBDY", st)
+	}
+	return nil
+}
+
+/*
+	A streamed MESSAGE body.  The reader goroutine waits, with the rest of
+	the frame unread, until the body is read to the end or closed.
+*/
+type bodyStream struct {
+	c    *Connection
+	mu   sync.Mutex
+	n    int64 // Body length, from the content-length header
+	rem  int64 // Bytes not yet read
+	err  error // Network read error, fatal to the connection
+	cls  bool  // Closed
+	done chan struct{}
+	once sync.Once
+}
+
+func newBodyStream(c *Connection, n int64) *bodyStream {
+	return &bodyStream{c: c, n: n, rem: n, done: make(chan struct{})}
+}
+
+/*
+	Read implements io.Reader.
+*/
+func (bs *bodyStream) Read(p []byte) (int, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if bs.cls {
+		return 0, ESTRMCLOSED
+	}
+	if bs.err != nil {
+		return 0, bs.err
+	}
+	if bs.rem == 0 {
+		bs.finish()
+		return 0, io.EOF
+	}
+	if int64(len(p)) > bs.rem {
+		p = p[:bs.rem]
+	}
+	bs.c.setReadDeadline()
+	k, e := bs.c.rdr.Read(p)
+	bs.rem -= int64(k)
+	if k > 0 && bs.c.hbd != nil {
+		bs.c.updateHBReads()
+	}
+	if e != nil {
+		if e == io.EOF {
+			e = io.ErrUnexpectedEOF
+		}
+		bs.err = bs.c.checkReadError(e)
+		bs.finish()
+		return k, e
+	}
+	if bs.rem == 0 {
+		bs.finish()
+	}
+	return k, nil
+}
+
+/*
+	Close implements io.Closer.  Any unread part of the body is discarded by
+	the reader goroutine.
+*/
+func (bs *bodyStream) Close() error {
+	bs.mu.Lock()
+	bs.cls = true
+	bs.finish()
+	bs.mu.Unlock()
+	return nil
+}
+
+/*
+	Release the reader goroutine.
+*/
+func (bs *bodyStream) finish() {
+	bs.once.Do(func() { close(bs.done) })
+}
+
+/*
+	Wait until a streamed body is read or closed, then skip any unread part
+	and the trailing NUL.  Called by the reader goroutine.  Returns ECONBAD
+	if the connection shuts down first.
+*/
+func (c *Connection) finishStream(bs *bodyStream) error {
+	select {
+	case <-bs.done:
+	default:
+		select {
+		case <-bs.done:
+		case <-c.ssdc:
+			return ECONBAD // Shutdown while waiting
+		}
+	}
+	bs.mu.Lock()
+	rem, e := bs.rem, bs.err
+	bs.mu.Unlock()
+	if e != nil {
+		return e
+	}
+	c.setReadDeadline()
+	if _, e = io.CopyN(io.Discard, c.rdr, rem); c.checkReadError(e) != nil {
+		return e
+	}
+	c.setReadDeadline()
+	b, e := c.rdr.ReadByte()
+	if c.checkReadError(e) != nil {
+		return e
+	}
+	if b != 0 { // trailing NUL
+		return &ProtocolError{EBADFRM, []byte{b}}
+	}
+	if c.hbd != nil {
+		c.updateHBReads()
+	}
+	if c.dld.rde {
+		_ = c.netconn.SetReadDeadline(c.dld.t0)
+	}
+	return nil
+}
+
+/*
+	Close any streamed body of a MESSAGE that is not delivered, so that the
+	reader goroutine does not wait for it.
+*/
+func closeStream(md MessageData) {
+	if md.Stream != nil {
+		_ = md.Stream.Close()
+	}
+}
@@ -0,0 +1,236 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+/*
+	Test SendStream and streamed MESSAGE bodies at all protocol levels.  A
+	streamed body closed unread is skipped, and later frames are read
+	normally.
+*/
+func TestStreamRoundTrip(t *testing.T) {
+	body := bytes.Repeat(streamPattern, streamRepeat)
+	for _, sp := range Protocols() {
+		n, _ = openConn(t)
+		ch := headersProtocol(login_headers, sp)
+		conn, e = Connect(n, ch)
+		if e != nil {
+			t.Fatalf("TestStreamRoundTrip CONNECT expected nil, got %v\n", e)
+		}
+		conn.SetStreamBodies(true)
+		d := tdest("/queue/stream.round.trip." + sp)
+		sc, e = conn.Subscribe(Headers{HK_DESTINATION, d, HK_ID, d})
+		if e != nil {
+			t.Fatalf("TestStreamRoundTrip Expected no subscribe error, got [%v]\n", e)
+		}
+		bw := conn.BytesWritten()
+		for i := 0; i < 2; i++ {
+			e = conn.SendStream(Headers{HK_DESTINATION, d}, bytes.NewReader(body),
+				int64(len(body)))
+			if e != nil {
+				t.Fatalf("TestStreamRoundTrip Expected nil error, got [%v]\n", e)
+			}
+		}
+		if bw = conn.BytesWritten() - bw; bw < 2*int64(len(body)) {
+			t.Fatalf("TestStreamRoundTrip Expected [%d] bytes written, got [%d]\n",
+				2*len(body), bw)
+		}
+		e = conn.Send(Headers{HK_DESTINATION, d}, streamLast)
+		if e != nil {
+			t.Fatalf("TestStreamRoundTrip Expected nil error, got [%v]\n", e)
+		}
+		// Read the first body, close the second unread
+		for i := 0; i < 2; i++ {
+			md = getMessageData(sc, conn, t)
+			if md.Error != nil || md.Stream == nil || len(md.Message.Body) != 0 {
+				t.Fatalf("TestStreamRoundTrip Expected a stream, got [%v] [%d]\n",
+					md.Error, len(md.Message.Body))
+			}
+			if i == 0 {
+				b, e := io.ReadAll(md.Stream)
+				if e != nil || !bytes.Equal(b, body) {
+					t.Fatalf("TestStreamRoundTrip Expected [%d] bytes, got [%d] [%v]\n",
+						len(body), len(b), e)
+				}
+			}
+			_ = md.Stream.Close()
+			if _, e = md.Stream.Read(make([]byte, 1)); e != ESTRMCLOSED {
+				t.Fatalf("TestStreamRoundTrip Expected [%v], got [%v]\n", ESTRMCLOSED, e)
+			}
+		}
+		md = getMessageData(sc, conn, t)
+		if md.Error != nil || md.Stream == nil {
+			t.Fatalf("TestStreamRoundTrip Expected a stream, got [%v]\n", md.Error)
+		}
+		b, _ := io.ReadAll(md.Stream)
+		_ = md.Stream.Close()
+		if string(b) != streamLast {
+			t.Fatalf("TestStreamRoundTrip Expected [%s], got [%s]\n", streamLast, b)
+		}
+		checkReceived(t, conn, false)
+		e = conn.Disconnect(empty_headers)
+		checkDisconnectError(t, e)
+		_ = closeConn(t, n)
+	}
+}
+
+/*
+	Test a SendStream reader shorter than the length given.
+*/
+func TestStreamShort(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).WaitClose()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestStreamShort Expected [nil], got [%v]\n", e)
+	}
+	e = c.SendStream(Headers{HK_DESTINATION, "/queue/stream"},
+		strings.NewReader(streamLast), -1)
+	if e != ESTRMLEN {
+		t.Fatalf("TestStreamShort Expected [%v], got [%v]\n", ESTRMLEN, e)
+	}
+	e = c.SendStream(Headers{HK_DESTINATION, "/queue/stream"},
+		strings.NewReader(streamLast), 100)
+	if !errors.Is(e, ESTRMSHORT) {
+		t.Fatalf("TestStreamShort Expected [%v], got [%v]\n", ESTRMSHORT, e)
+	}
+	_ = n.Close()
+	if se := <-rc; se != nil {
+		t.Fatalf("TestStreamShort Script error [%v]\n", se)
+	}
+}
+
+/*
+	Test SetMaxBodySize, streamed and not.
+*/
+func TestStreamMaxBodySize(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+			Expect(SUBSCRIBE).Write(streamBigMessage).WaitClose()
+		n, rc := s.Pipe()
+		c, e := Connect(n, ctxHeaders)
+		if e != nil {
+			t.Fatalf("TestStreamMaxBodySize Expected [nil], got [%v]\n", e)
+		}
+		c.SetStreamBodies(stream)
+		c.SetMaxBodySize(10)
+		sc, e := c.Subscribe(Headers{HK_DESTINATION, "/queue/stream", HK_ID, "stream-sub"})
+		if e != nil {
+			t.Fatalf("TestStreamMaxBodySize Expected [nil], got [%v]\n", e)
+		}
+		select {
+		case md := <-sc:
+			if !errors.Is(md.Error, ErrFrameTooLarge) {
+				t.Fatalf("TestStreamMaxBodySize Expected [%v], got [%v]\n", ErrFrameTooLarge,
+					md.Error)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("TestStreamMaxBodySize Expected an error, got none\n")
+		}
+		_ = n.Close()
+		if se := <-rc; se != nil {
+			t.Fatalf("TestStreamMaxBodySize Script error [%v]\n", se)
+		}
+	}
+}
+
+/*
+	Test a streamed body not followed by the trailing NUL.
+*/
+func TestStreamBadTrailer(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).
+		Expect(SUBSCRIBE).Write(streamBadTrailer).WaitClose()
+	n, rc := s.Pipe()
+	c, e := Connect(n, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestStreamBadTrailer Expected [nil], got [%v]\n", e)
+	}
+	c.SetStreamBodies(true)
+	sc, e := c.Subscribe(Headers{HK_DESTINATION, "/queue/stream", HK_ID, "stream-sub"})
+	if e != nil {
+		t.Fatalf("TestStreamBadTrailer Expected [nil], got [%v]\n", e)
+	}
+	md := getMessageData(sc, c, t)
+	if md.Error != nil || md.Stream == nil {
+		t.Fatalf("TestStreamBadTrailer Expected a stream, got [%v]\n", md.Error)
+	}
+	_ = md.Stream.Close()
+	select {
+	case md = <-sc:
+		var pe *ProtocolError
+		if !errors.Is(md.Error, EBADFRM) || !errors.As(md.Error, &pe) ||
+			string(pe.Data) != "X" {
+			t.Fatalf("TestStreamBadTrailer Expected [%v], got [%v]\n", EBADFRM,
+				md.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("TestStreamBadTrailer Expected an error, got none\n")
+	}
+	_ = n.Close()
+	if se := <-rc; se != nil {
+		t.Fatalf("TestStreamBadTrailer Script error [%v]\n", se)
+	}
+}
+
+/*
+	Test SendStreamContext with write deadlines enabled.  The deadline armed
+	for each chunk must not undo the context abort.
+*/
+func TestStreamWriteDeadline(t *testing.T) {
+	s := stomptest.NewScript().Expect(CONNECT).Write(ctxConnected).WaitClose()
+	n, rc := s.Pipe()
+	sc := &slow_arm_conn{Conn: n}
+	c, e := Connect(sc, ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestStreamWriteDeadline Expected [nil], got [%v]\n", e)
+	}
+	c.WriteDeadline(ctxWriteDeadline)
+	c.EnableWriteDeadline(true)
+	sc.arm = ctxArmPause
+	ctx, cf := context.WithTimeout(context.Background(), 5*ctxArmPause)
+	defer cf()
+	e = c.SendStreamContext(ctx, Headers{HK_DESTINATION, "/queue/stream"},
+		bytes.NewReader(make([]byte, streamArmChunks*streamChunk)),
+		streamArmChunks*streamChunk)
+	if e != context.DeadlineExceeded {
+		t.Fatalf("TestStreamWriteDeadline Expected [%v], got [%v]\n",
+			context.DeadlineExceeded, e)
+	}
+	// The interrupted write shuts the connection down.
+	dl := time.Now().Add(ctxStallWait)
+	for c.Connected() && time.Now().Before(dl) {
+		time.Sleep(ctxArmPause)
+	}
+	if c.Connected() {
+		t.Fatalf("TestStreamWriteDeadline Expected connected [false], got [true]\n")
+	}
+	_ = n.Close()
+	if se := <-rc; se != nil {
+		t.Fatalf("TestStreamWriteDeadline Script error [%v]\n", se)
+	}
+}
@@ -17,6 +17,7 @@
 package stompngo
 
 import (
+	"context"
 	"strconv"
 )
 
@@ -42,6 +43,18 @@ import (
 
 	For details about the returned MessageData channel, see: https://github.com/photostorm/stompngo/wiki/subscribe-and-messagedata
 
+	By default a full MessageData channel blocks the connection reader, and
+	so every subscription on the connection.  The StompPlusFlow header
+	selects another flow control policy for this subscription: FlowBlock,
+	FlowDropNewest, FlowDropOldest, or FlowOverflow, with an overflow buffer
+	of StompPlusOverflow entries (default: the channel capacity).  Dropped
+	MESSAGE frames are counted in Stats.
+
+	If enabled with SetAutoPrefetch, and not supplied by the caller, the
+	activemq.prefetchSize and prefetch-count headers are added, set to the
+	channel capacity plus any overflow buffer size, so that brokers which
+	support them do not send more unacknowledged messages than can be queued.
+
 	Example:
 		// Possible additional Header keys: "ack", "id".
 		h := stompngo.Headers{stompngo.HK_DESTINATION, "/queue/myqueue"}
@@ -52,17 +65,42 @@ import (
 
 */
 func (c *Connection) Subscribe(h Headers) (<-chan MessageData, error) {
-	c.log(SUBSCRIBE, "start", h, c.Protocol())
+	return c.SubscribeContext(context.Background(), h)
+}
+
+/*
+	SubscribeContext subscribes to a STOMP subscription, honoring ctx
+	cancellation and deadlines.
+
+	If the SUBSCRIBE frame can not be written, the subscription is removed
+	and the error (ctx.Err() if ctx is done first) is returned.  See Subscribe
+	for details.
+*/
+func (c *Connection) SubscribeContext(ctx context.Context, h Headers) (<-chan MessageData, error) {
+	sub, _, e := c.subscribe(ctx, h, false)
+	if e != nil {
+		return nil, e
+	}
+	return sub.md, nil
+}
+
+/*
+	Subscribe, returning the new subscription and the headers sent.  If rcpt
+	is set, wait for the broker's RECEIPT.
+*/
+func (c *Connection) subscribe(ctx context.Context, h Headers,
+	rcpt bool) (*subscription, Headers, error) {
+	c.logcmd(SUBSCRIBE, "start", h)
 	if !c.isConnected() {
-		return nil, ECONBAD
+		return nil, nil, ECONBAD
 	}
 	e := checkHeaders(h, c.Protocol())
 	if e != nil {
-		return nil, e
+		return nil, nil, e
 	}
 	e = c.checkSubscribeHeaders(h)
 	if e != nil {
-		return nil, e
+		return nil, nil, e
 	}
 	ch := h.Clone()
 	if _, ok := ch.Contains(HK_ACK); !ok {
@@ -70,18 +108,31 @@ func (c *Connection) Subscribe(h Headers) (<-chan MessageData, error) {
 	}
 	sub, e, ch := c.establishSubscription(ch)
 	if e != nil {
-		return nil, e
+		return nil, nil, e
 	}
+	ch = c.addPrefetch(sub, ch)
 	//
 	f := Frame{SUBSCRIBE, ch, NULLBUFF}
 	//
-	r := make(chan error)
-	if e = c.writeWireData(wiredata{f, r}); e != nil {
+	if e = c.transmitFrame(ctx, f, rcpt); e != nil {
+		c.removeSubscription(sub.id)
+		return nil, nil, e
+	}
+	c.logcmd(SUBSCRIBE, "end", ch)
+	return sub, ch, nil
+}
+
+/*
+	SubscribeWithReceipt subscribes to a STOMP subscription, and waits for the
+	broker's RECEIPT.  If an ERROR frame or any other error is returned, the
+	subscription is removed.  See SendWithReceipt and Subscribe for details.
+*/
+func (c *Connection) SubscribeWithReceipt(ctx context.Context, h Headers) (<-chan MessageData, error) {
+	sub, _, e := c.subscribe(ctx, h, true)
+	if e != nil {
 		return nil, e
 	}
-	e = <-r
-	c.log(SUBSCRIBE, "end", ch, c.Protocol())
-	return sub.md, e
+	return sub.md, nil
 }
 
 /*
@@ -110,7 +161,7 @@ func (c *Connection) checkSubscribeHeaders(h Headers) error {
 			}
 		}
 	default:
-		panic("Internal protocol level error: " + c.Protocol())
+		return EBADVERSUB
 	}
 	return nil
 }
@@ -155,6 +206,12 @@ func (c *Connection) establishSubscription(h Headers) (*subscription, error, Hea
 	sd.drmc = 0                           // Current drain count
 	sd.md = make(chan MessageData, c.scc) // Make subscription MD channel
 	sd.am = h.Value(HK_ACK)               // Set subscription ack mode
+	sd.uc = make(chan struct{})           // Removal notification
+	sd.dest = h.Value(HK_DESTINATION)     // For Close
+	sd.ssdc = c.ssdc                      // Abort notification
+	if e := c.setFlow(sd, h); e != nil {
+		return nil, e, h
+	}
 	//
 	if !hid {
 		// No caller supplied ID.  This STOMP client package supplies one.  It is the
@@ -171,7 +228,7 @@ func (c *Connection) establishSubscription(h Headers) (*subscription, error, Hea
 			sd.id = uuid1
 			h = h.Add(HK_ID, uuid1)
 		default:
-			panic("Internal protocol level error: " + c.Protocol())
+			return nil, EBADVERSUB, h
 		}
 	}
 
@@ -188,6 +245,23 @@ func (c *Connection) establishSubscription(h Headers) (*subscription, error, Hea
 	c.subsLock.Lock()
 	c.subs[sd.id] = sd // Add subscription to the connection subscription map
 	c.subsLock.Unlock()
+	if sd.fp == FlowOverflow {
+		go sd.pump()
+	}
 	//
 	return sd, nil, h // Return the subscription pointer
 }
+
+/*
+	Remove a subscription from the connection, and notify any SubscribeFunc
+	workers.
+*/
+func (c *Connection) removeSubscription(id string) {
+	c.subsLock.Lock()
+	if sd, ok := c.subs[id]; ok {
+		delete(c.subs, id)
+		close(sd.uc)
+		c.untrackAcks(sd)
+	}
+	c.subsLock.Unlock()
+}
@@ -0,0 +1,322 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"io"
+	"runtime/debug"
+	"sync"
+)
+
+/*
+	MessageHandler processes a single MESSAGE for SubscribeFunc.  A nil
+	return acknowledges the message, an error rejects it.
+*/
+type MessageHandler func(ctx context.Context, m *Message) error
+
+/*
+	SubscribeFuncOption is a function that modifies SubscribeFunc behavior.
+*/
+type SubscribeFuncOption func(*subFuncConfig)
+
+/*
+	SubscribeFunc configuration, built from SubscribeFuncOptions.
+*/
+type subFuncConfig struct {
+	wkrs int                       // Worker goroutines
+	ctx  context.Context           // Parent of the handler context
+	errf func(m *Message, e error) // Error callback, possibly nil
+}
+
+/*
+	SubWorkers sets the number of worker goroutines calling the handler.  The
+	default is 1.
+*/
+func SubWorkers(n int) SubscribeFuncOption {
+	return func(sc *subFuncConfig) {
+		sc.wkrs = n
+	}
+}
+
+/*
+	SubContext sets the parent of the context passed to the handler.  When
+	ctx is done, the subscription is unsubscribed.  The default is
+	context.Background().
+*/
+func SubContext(ctx context.Context) SubscribeFuncOption {
+	return func(sc *subFuncConfig) {
+		sc.ctx = ctx
+	}
+}
+
+/*
+	SubErrorFunc sets a function called with each handler error, recovered
+	handler panic (a *HandlerPanic), and ACK or NACK failure.  It is called
+	from the worker goroutines.
+*/
+func SubErrorFunc(f func(m *Message, e error)) SubscribeFuncOption {
+	return func(sc *subFuncConfig) {
+		sc.errf = f
+	}
+}
+
+/*
+	FuncSubscription is a subscription created by SubscribeFunc.
+*/
+type FuncSubscription struct {
+	c    *Connection
+	sd   *subscription
+	h    Headers // As sent
+	cfg  subFuncConfig
+	quit chan struct{} // Closed when the workers are told to stop
+	done chan struct{} // Closed when the workers have stopped
+	once sync.Once
+	err  error
+}
+
+/*
+	SubscribeFunc subscribes to a STOMP subscription, and calls f for each
+	MESSAGE received, from a pool of worker goroutines.  Headers are as for
+	Subscribe.
+
+	The result of f is acknowledged according to the subscription ack mode.
+	For the auto mode nothing is sent.  For the client and client-individual
+	modes a nil result is ACKed, and an error is NACKed (STOMP 1.1+), or
+	left unacknowledged (STOMP 1.0).  A handler panic is recovered, and
+	treated as an error.  With the client ack mode an ACK also acknowledges
+	all earlier messages, so use client-individual with more than one
+	worker.
+
+	A streamed body (see SetStreamBodies) is read in full before f is called.
+
+	The workers stop when the subscription is unsubscribed, when the
+	connection fails or shuts down, or when the SubContext context is done,
+	in which case the subscription is also unsubscribed.  The context passed
+	to f is then cancelled.  Messages not yet passed to f are dropped.
+
+	Example:
+		h := stompngo.Headers{stompngo.HK_DESTINATION, "/queue/work",
+			stompngo.HK_ACK, stompngo.AckModeClientIndividual}
+		fs, e := c.SubscribeFunc(h, func(ctx context.Context, m *stompngo.Message) error {
+			return process(ctx, m.Body)
+		}, stompngo.SubWorkers(4))
+		if e != nil {
+			// Do something sane ...
+		}
+		...
+		e = fs.Unsubscribe(ctx)
+*/
+func (c *Connection) SubscribeFunc(h Headers, f MessageHandler,
+	opts ...SubscribeFuncOption) (*FuncSubscription, error) {
+	cfg := subFuncConfig{wkrs: 1, ctx: context.Background()}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.wkrs < 1 {
+		cfg.wkrs = 1
+	}
+	sd, ch, e := c.subscribe(cfg.ctx, h, false)
+	if e != nil {
+		return nil, e
+	}
+	fs := &FuncSubscription{c: c, sd: sd, h: ch, cfg: cfg,
+		quit: make(chan struct{}), done: make(chan struct{})}
+	ctx, cancel := context.WithCancel(cfg.ctx)
+	go func() {
+		select {
+		case <-sd.uc: // Unsubscribed
+			fs.halt(nil)
+		case <-fs.quit:
+		}
+		cancel()
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.wkrs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fs.worker(ctx, f)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		if fs.err != nil && fs.err == cfg.ctx.Err() {
+			if c.Unsubscribe(fs.unsubHeaders()) != nil {
+				c.removeSubscription(sd.id)
+			}
+		}
+		close(fs.done)
+	}()
+	return fs, nil
+}
+
+/*
+	Id returns the subscription id.
+*/
+func (fs *FuncSubscription) Id() string {
+	return fs.sd.id
+}
+
+/*
+	Done returns a channel that is closed when all workers have stopped.
+*/
+func (fs *FuncSubscription) Done() <-chan struct{} {
+	return fs.done
+}
+
+/*
+	Err waits for the workers to stop, and returns the reason: nil after
+	Unsubscribe, the connection error after a connection failure, ECONBAD
+	after a connection shutdown, or the SubContext context error.
+*/
+func (fs *FuncSubscription) Err() error {
+	<-fs.done
+	return fs.err
+}
+
+/*
+	Unsubscribe unsubscribes, and waits for the workers to stop, or for ctx
+	to be done.
+*/
+func (fs *FuncSubscription) Unsubscribe(ctx context.Context) error {
+	if e := fs.c.UnsubscribeContext(ctx, fs.unsubHeaders()); e != nil {
+		return e
+	}
+	select {
+	case <-fs.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+	Headers identifying the subscription, for UNSUBSCRIBE.
+*/
+func (fs *FuncSubscription) unsubHeaders() Headers {
+	return Headers{HK_DESTINATION, fs.h.Value(HK_DESTINATION), HK_ID, fs.sd.id}
+}
+
+/*
+	Tell all workers to stop, recording the first stop reason.
+*/
+func (fs *FuncSubscription) halt(e error) {
+	fs.once.Do(func() {
+		fs.err = e
+		close(fs.quit)
+	})
+}
+
+/*
+	A single worker.
+*/
+func (fs *FuncSubscription) worker(ctx context.Context, f MessageHandler) {
+	for {
+		select {
+		case <-fs.quit:
+			return
+		default:
+		}
+		select {
+		case md, ok := <-fs.sd.md:
+			if !ok { // Connection shutdown
+				fs.halt(ECONBAD)
+				return
+			}
+			if md.Error != nil { // Connection failure
+				fs.halt(md.Error)
+				return
+			}
+			if md.Stream != nil { // Handlers see the whole body
+				b, e := io.ReadAll(md.Stream)
+				_ = md.Stream.Close()
+				if e != nil {
+					fs.report(&md.Message, e)
+					continue
+				}
+				md.Message.Body = b
+			}
+			fs.handle(ctx, f, &md.Message)
+		case <-fs.quit:
+			return
+		case <-ctx.Done():
+			fs.halt(fs.cfg.ctx.Err())
+			return
+		}
+	}
+}
+
+/*
+	Call the handler for one message, and ACK or NACK the result.
+*/
+func (fs *FuncSubscription) handle(ctx context.Context, f MessageHandler, m *Message) {
+	e := fs.call(ctx, f, m)
+	if e != nil {
+		fs.report(m, e)
+	}
+	if fs.sd.am == AckModeAuto || fs.sd.am == "" {
+		return
+	}
+	ah := ackHeaders(fs.c.Protocol(), m)
+	var ae error
+	switch {
+	case e == nil:
+		ae = fs.c.AckContext(context.Background(), ah)
+	case fs.c.Protocol() != SPL_10:
+		ae = fs.c.NackContext(context.Background(), ah)
+	}
+	if ae != nil {
+		fs.report(m, ae)
+	}
+}
+
+/*
+	Call the handler, recovering any panic.
+*/
+func (fs *FuncSubscription) call(ctx context.Context, f MessageHandler, m *Message) (e error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e = &HandlerPanic{r, debug.Stack()}
+		}
+	}()
+	return f(ctx, m)
+}
+
+/*
+	Pass an error to the SubErrorFunc, if any.
+*/
+func (fs *FuncSubscription) report(m *Message, e error) {
+	if fs.cfg.errf != nil {
+		fs.cfg.errf(m, e)
+	}
+}
+
+/*
+	ACK / NACK headers for a MESSAGE, by protocol level.
+*/
+func ackHeaders(p string, m *Message) Headers {
+	switch p {
+	case SPL_12:
+		return Headers{HK_ID, m.Headers.Value(HK_ACK)}
+	case SPL_11:
+		return Headers{HK_MESSAGE_ID, m.Headers.Value(HK_MESSAGE_ID),
+			HK_SUBSCRIPTION, m.Headers.Value(HK_SUBSCRIPTION)}
+	default:
+		return Headers{HK_MESSAGE_ID, m.Headers.Value(HK_MESSAGE_ID)}
+	}
+}
@@ -0,0 +1,187 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+	Test SubscribeFunc ACK, NACK and panic recovery at all protocol levels.
+	ACKed and NACKed messages are settled, anything else is redelivered.
+*/
+func TestSubscribeFuncAck(t *testing.T) {
+	for _, sp := range Protocols() {
+		n, _ = openConn(t)
+		ch := headersProtocol(login_headers, sp)
+		conn, e = Connect(n, ch)
+		if e != nil {
+			t.Fatalf("TestSubscribeFuncAck CONNECT expected nil, got %v\n", e)
+		}
+		d := tdest("/queue/subscribe.func.ack." + sp)
+		for _, b := range subFuncBodies {
+			e = conn.Send(Headers{HK_DESTINATION, d}, b)
+			if e != nil {
+				t.Fatalf("TestSubscribeFuncAck Expected nil error, got [%v]\n", e)
+			}
+		}
+		// Client mode ACKs are cumulative, so use one worker.
+		am, wkrs := AckModeClientIndividual, 2
+		if sp == SPL_10 {
+			am, wkrs = AckModeClient, 1
+		}
+		var mu sync.Mutex
+		var errs []error
+		handled := make(chan string, len(subFuncBodies))
+		fs, e := conn.SubscribeFunc(Headers{HK_DESTINATION, d, HK_ACK, am},
+			func(ctx context.Context, m *Message) error {
+				defer func() { handled <- m.BodyString() }()
+				switch m.BodyString() {
+				case subFuncFail:
+					return errSubFunc
+				case subFuncPanic:
+					panic(subFuncPanic)
+				}
+				return nil
+			}, SubWorkers(wkrs), SubErrorFunc(func(m *Message, e error) {
+				mu.Lock()
+				errs = append(errs, e)
+				mu.Unlock()
+			}))
+		if e != nil {
+			t.Fatalf("TestSubscribeFuncAck Expected no subscribe error, got [%v]\n", e)
+		}
+		for range subFuncBodies {
+			select {
+			case <-handled:
+			case <-time.After(subFuncWait):
+				t.Fatalf("TestSubscribeFuncAck Timed out, protocol [%s]\n", sp)
+			}
+		}
+		if e = fs.Unsubscribe(context.Background()); e != nil {
+			t.Fatalf("TestSubscribeFuncAck Expected no unsubscribe error, got [%v]\n", e)
+		}
+		if e = fs.Err(); e != nil {
+			t.Fatalf("TestSubscribeFuncAck Expected nil Err, got [%v]\n", e)
+		}
+		mu.Lock()
+		var hp *HandlerPanic
+		if len(errs) != 2 || !errors.Is(errs[0], errSubFunc) && !errors.Is(errs[1], errSubFunc) ||
+			!errors.As(errs[0], &hp) && !errors.As(errs[1], &hp) {
+			t.Fatalf("TestSubscribeFuncAck Expected handler error and panic, got [%v]\n", errs)
+		}
+		mu.Unlock()
+		if hp.Value != subFuncPanic || len(hp.Stack) == 0 {
+			t.Fatalf("TestSubscribeFuncAck Expected [%s] and a stack, got [%v]\n",
+				subFuncPanic, hp.Value)
+		}
+		// NACKed messages are discarded, 1.0 cannot NACK.
+		want := 0
+		if sp == SPL_10 {
+			want = 2
+		}
+		if got := subFuncRemaining(t, d); got != want {
+			t.Fatalf("TestSubscribeFuncAck Expected [%d] unsettled, got [%d], protocol [%s]\n",
+				want, got, sp)
+		}
+		checkReceived(t, conn, false)
+		e = conn.Disconnect(empty_headers)
+		checkDisconnectError(t, e)
+		_ = closeConn(t, n)
+	}
+}
+
+/*
+	Count the messages left on destination d, using a marker message sent
+	last.
+*/
+func subFuncRemaining(t *testing.T, d string) int {
+	sc, e := conn.Subscribe(Headers{HK_DESTINATION, d, HK_ID, d})
+	if e != nil {
+		t.Fatalf("subFuncRemaining Expected no subscribe error, got [%v]\n", e)
+	}
+	if e = conn.Send(Headers{HK_DESTINATION, d}, subFuncMarker); e != nil {
+		t.Fatalf("subFuncRemaining Expected nil error, got [%v]\n", e)
+	}
+	c := 0
+	for {
+		md := getMessageData(sc, conn, t)
+		if md.Message.BodyString() == subFuncMarker {
+			break
+		}
+		c++
+	}
+	if e = conn.Unsubscribe(Headers{HK_DESTINATION, d, HK_ID, d}); e != nil {
+		t.Fatalf("subFuncRemaining Expected no unsubscribe error, got [%v]\n", e)
+	}
+	return c
+}
+
+/*
+	Test SubscribeFunc workers stop, and the subscription is removed, when
+	the context is done and when the connection shuts down.
+*/
+func TestSubscribeFuncStop(t *testing.T) {
+	n, _ = openConn(t)
+	ch := headersProtocol(login_headers, SPL_12)
+	conn, e = Connect(n, ch)
+	if e != nil {
+		t.Fatalf("TestSubscribeFuncStop CONNECT expected nil, got %v\n", e)
+	}
+	d := tdest("/queue/subscribe.func.stop")
+	started := make(chan struct{})
+	blocked := func(ctx context.Context, m *Message) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	fs, e := conn.SubscribeFunc(Headers{HK_DESTINATION, d}, blocked,
+		SubContext(ctx))
+	if e != nil {
+		t.Fatalf("TestSubscribeFuncStop Expected no subscribe error, got [%v]\n", e)
+	}
+	if e = conn.Send(Headers{HK_DESTINATION, d}, subFuncFail); e != nil {
+		t.Fatalf("TestSubscribeFuncStop Expected nil error, got [%v]\n", e)
+	}
+	<-started
+	cancel()
+	if e = fs.Err(); !errors.Is(e, context.Canceled) {
+		t.Fatalf("TestSubscribeFuncStop Expected [%v], got [%v]\n", context.Canceled, e)
+	}
+	if len(conn.Stats().Subscriptions) != 0 {
+		t.Fatalf("TestSubscribeFuncStop Expected no subscriptions, got [%v]\n",
+			conn.Stats().Subscriptions)
+	}
+	//
+	fs, e = conn.SubscribeFunc(Headers{HK_DESTINATION, d},
+		func(ctx context.Context, m *Message) error { return nil })
+	if e != nil {
+		t.Fatalf("TestSubscribeFuncStop Expected no subscribe error, got [%v]\n", e)
+	}
+	checkReceived(t, conn, false)
+	e = conn.Disconnect(empty_headers)
+	checkDisconnectError(t, e)
+	_ = closeConn(t, n)
+	if e = fs.Err(); e != ECONBAD {
+		t.Fatalf("TestSubscribeFuncStop Expected [%v], got [%v]\n", ECONBAD, e)
+	}
+}
@@ -1,9 +1,15 @@
 package stompngo
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"log"
 	"net"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/photostorm/stompngo/senv"
 )
@@ -86,6 +92,8 @@ var (
 		{"\\\\\\c", "\\:"},
 		{"c\\cc", "c:c"},
 		{"n\\nn", "n\nn"},
+		{"\\\\c", "\\c"},
+		{"\\\\n", "\\n"},
 	}
 )
 
@@ -250,14 +258,18 @@ const (
 //= logger_test type ==========================================================
 //=============================================================================
 type (
-// None at present.
+	log_buffer struct {
+		mu sync.Mutex   // protects b
+		b  bytes.Buffer // log output
+	}
 )
 
 //=============================================================================
 //= logger_test var ===========================================================
 //=============================================================================
 var (
-// None at present.
+	lgHeaders = Headers{HK_ACCEPT_VERSION, SPL_12, HK_HOST, "localhost",
+		HK_LOGIN, "lguser", HK_PASSCODE, "lgsecret"}
 )
 
 //=============================================================================
@@ -847,6 +859,16 @@ type (
 		mpref string      // message prefix
 		count int         // number of messages
 	}
+	pipe_broker struct {
+		n net.Conn      // broker side of a net.Pipe
+		r *bufio.Reader // reader for n
+	}
+	slow_arm_conn struct {
+		net.Conn
+		mu  sync.Mutex    // protects wdl
+		wdl time.Time     // current write deadline
+		arm time.Duration // pause when a future write deadline is set
+	}
 )
 
 //=============================================================================
@@ -919,6 +941,503 @@ const (
 // None at present.
 )
 
+//=============================================================================
+//= context_test type =========================================================
+//=============================================================================
+type (
+// None at present.
+)
+
+//=============================================================================
+//= context_test var ==========================================================
+//=============================================================================
+var (
+	ctxConnected = "CONNECTED\nversion:1.2\nsession:ctx-test\n\n\x00"
+	ctxHeaders   = Headers{HK_ACCEPT_VERSION, SPL_12, HK_HOST, "localhost"}
+)
+
+//=============================================================================
+//= context_test const ========================================================
+//=============================================================================
+const (
+	ctxStalledMessage = "MESSAGE\ndestination:/queue/ctx.stalled\n" +
+		"subscription:ctx-stalled\nmessage-id:ctx-stalled\n\nstalled\x00"
+	ctxStallPause    = 50 * time.Millisecond
+	ctxStallWait     = 2 * time.Second
+	ctxWriteDeadline = 5 * time.Second
+	ctxArmPause      = 20 * time.Millisecond
+	ctxArmHeaders    = 10
+)
+
+//=============================================================================
+//= dial_test type ============================================================
+//=============================================================================
+type (
+	dialURLData struct {
+		url string // URL to dial
+		err error  // Expected error
+	}
+	cancel_read_conn struct {
+		net.Conn
+		cf context.CancelFunc // called once CONNECTED is read
+	}
+)
+
+//=============================================================================
+//= dial_test var =============================================================
+//=============================================================================
+var (
+	dialBadURLs = []dialURLData{
+		{"http://localhost:61613", EBADSCHEME},
+		{"localhost:61613", EBADSCHEME},
+		{"stomp://:61613", EBADURLHOST},
+		{"stomp+ssl:///vhost", EBADURLHOST},
+	}
+)
+
+//=============================================================================
+//= dial_test const ===========================================================
+//=============================================================================
+const (
+	dialConnected10 = "CONNECTED\n\n\x00"
+	dialCancelPause = 50 * time.Millisecond
+)
+
+//=============================================================================
+//= receipt_test type =========================================================
+//=============================================================================
+type (
+	receiptData struct {
+		cmd  string // Broker response command
+		hdrs string // Additional raw broker headers
+	}
+)
+
+//=============================================================================
+//= receipt_test var ==========================================================
+//=============================================================================
+var (
+	receiptResponses = []receiptData{
+		{RECEIPT, ""},
+		{ERROR, HK_MESSAGE + ":denied\n"},
+		{RECEIPT, ""},
+	}
+)
+
+//=============================================================================
+//= receipt_test const ========================================================
+//=============================================================================
+const (
+	receiptLatePause = 200 * time.Millisecond
+)
+
+//=============================================================================
+//= fault_test type ===========================================================
+//=============================================================================
+type (
+	faultConnData struct {
+		resp string // Raw broker CONNECT response
+		err  error  // Expected Connect error
+	}
+	faultOrphanData struct {
+		op   OrphanPolicy // Policy under test
+		err  error        // Expected subscriber error
+		body string       // Expected MessageData channel body, if any
+	}
+)
+
+//=============================================================================
+//= fault_test var ============================================================
+//=============================================================================
+var (
+	faultConnResponses = []faultConnData{
+		{"CONNECTED\n\x00", EBADFRM},
+		{"CONNECTED\nversion1.2\n\n\x00", EUNKHDR},
+		{"CONNECTED\nversion:1.2\n\nbody data\x00", EBDYDATA},
+		{"MESSAGE\nversion:1.2\n\n\x00", EUNKFRM},
+	}
+	faultMessage = "MESSAGE\nsubscription:fault-sub\nmessage-id:fault-1\n" +
+		"destination:/queue/fault\ncontent-length:13\n\nfault message\x00"
+	faultNoSubMessage = "MESSAGE\nmessage-id:fault-2\n" +
+		"destination:/queue/fault\n\nno subscription\x00"
+	faultOrphanMessage = "MESSAGE\nsubscription:fault-orphan\nmessage-id:fault-3\n" +
+		"destination:/queue/fault\n\norphan\x00"
+	faultConnError = "ERROR\nmessage:bad login\n\nbad login details\x00"
+	faultDiscError = "ERROR\nmessage:disconnect refused\n" +
+		"receipt-id:fault-disc\n\n\x00"
+	faultOrphans = []faultOrphanData{
+		{OrphanDrop, nil, ""},
+		{OrphanDeliver, nil, "orphan"},
+		{OrphanAbort, EORPHMSG, ""},
+	}
+)
+
+//=============================================================================
+//= fault_test const ==========================================================
+//=============================================================================
+const (
+// None at present.
+)
+
+//=============================================================================
+//= stats_test type ===========================================================
+//=============================================================================
+type (
+// None at present.
+)
+
+//=============================================================================
+//= stats_test var ============================================================
+//=============================================================================
+var (
+	statsMessages = []string{"stats one", "stats two"}
+)
+
+//=============================================================================
+//= stats_test const ==========================================================
+//=============================================================================
+const (
+// None at present.
+)
+
+//=============================================================================
+//= tracer_test type ==========================================================
+//=============================================================================
+type (
+	tracerCtxKey struct{}
+)
+
+//=============================================================================
+//= tracer_test var ===========================================================
+//=============================================================================
+var (
+// None at present.
+)
+
+//=============================================================================
+//= tracer_test const =========================================================
+//=============================================================================
+const (
+	tracerHdr   = "x-test-trace"
+	tracerValue = "00:trace\\id,k=v"
+)
+
+//=============================================================================
+//= subscribe_func_test type ==================================================
+//=============================================================================
+type (
+// None at present.
+)
+
+//=============================================================================
+//= subscribe_func_test var ===================================================
+//=============================================================================
+var (
+	subFuncBodies = []string{"ok", subFuncFail, subFuncPanic}
+	errSubFunc    = errors.New("subscribe func handler failed")
+)
+
+//=============================================================================
+//= subscribe_func_test const =================================================
+//=============================================================================
+const (
+	subFuncFail   = "fail"
+	subFuncPanic  = "panic"
+	subFuncMarker = "marker"
+	subFuncWait   = 5 * time.Second
+)
+
+//=============================================================================
+//= flow_test type ============================================================
+//=============================================================================
+type (
+	flowPolicyData struct {
+		policy  string
+		want    []string // Bodies received, in order
+		dropped int64
+	}
+)
+
+//=============================================================================
+//= flow_test var =============================================================
+//=============================================================================
+var (
+	flowBodies = []string{"flow 1", "flow 2", "flow 3", "flow 4", "flow 5"}
+	flowData   = []flowPolicyData{
+		{FlowDropNewest, flowBodies[:2], 3},
+		{FlowDropOldest, flowBodies[3:], 3},
+		{FlowOverflow, flowBodies[:5], 0},
+	}
+)
+
+//=============================================================================
+//= flow_test const ===========================================================
+//=============================================================================
+const (
+	flowCap    = 2
+	flowWait   = 5 * time.Second
+	flowSettle = 50 * time.Millisecond
+)
+
+//=============================================================================
+//= stream_test type ==========================================================
+//=============================================================================
+type (
+// None at present.
+)
+
+//=============================================================================
+//= stream_test var ===========================================================
+//=============================================================================
+var (
+	streamPattern = []byte("stream\x00body\n")
+)
+
+//=============================================================================
+//= stream_test const =========================================================
+//=============================================================================
+const (
+	streamRepeat     = 64 * 1024
+	streamArmChunks  = 20
+	streamLast       = "last"
+	streamBigMessage = "MESSAGE\nsubscription:stream-sub\nmessage-id:m1\n" +
+		"destination:/queue/stream\ncontent-length:11\n\nhello world\x00"
+	streamBadTrailer = "MESSAGE\nsubscription:stream-sub\nmessage-id:m1\n" +
+		"destination:/queue/stream\ncontent-length:5\n\nhelloX\x00"
+)
+
+//=============================================================================
+//= limits_test type ==========================================================
+//=============================================================================
+type (
+	limitData struct {
+		limit string     // FrameTooLargeError Limit expected
+		rl    ReadLimits // Limits in force
+		frame string     // Frame written by the broker
+	}
+)
+
+//=============================================================================
+//= limits_test var ===========================================================
+//=============================================================================
+var (
+	limitsData = []limitData{
+		{LimitCommand, ReadLimits{MaxCommand: 4},
+			"MESSAGE\nsubscription:limit-sub\nmessage-id:m1\n\nbody\x00"},
+		{LimitHeaders, ReadLimits{MaxHeaders: 2},
+			"MESSAGE\nsubscription:limit-sub\nmessage-id:m1\ndestination:/queue/limit\n\nbody\x00"},
+		{LimitHeaderLine, ReadLimits{MaxHeaderLine: 20},
+			"MESSAGE\nsubscription:limit-sub\nmessage-id:m1\ndestination:/queue/limit/long\n\nbody\x00"},
+		{LimitBody, ReadLimits{MaxBody: 3},
+			"MESSAGE\nsubscription:limit-sub\nmessage-id:m1\n\nbody\x00"},
+		{LimitBody, ReadLimits{MaxBody: 3},
+			"MESSAGE\nsubscription:limit-sub\nmessage-id:m1\ncontent-length:4\n\nbody\x00"},
+	}
+	limitOK = ReadLimits{MaxCommand: 7, MaxHeaders: 3, MaxHeaderLine: 24, MaxBody: 4}
+)
+
+//=============================================================================
+//= limits_test const =========================================================
+//=============================================================================
+const (
+	limitOKMessage = "MESSAGE\nsubscription:limit-sub\nmessage-id:m1\ndestination:/queue/limit\n\nbody\x00"
+	limitWait      = 5 * time.Second
+)
+
+//=============================================================================
+//= frame_test type ===========================================================
+//=============================================================================
+type (
+	frameTestData struct {
+		Command string
+		Headers Headers
+		Body    string
+	}
+)
+
+//=============================================================================
+//= frame_test var ============================================================
+//=============================================================================
+var (
+	frameRoundTrip = []frameTestData{
+		{MESSAGE, Headers{HK_DESTINATION, "/queue/frame.test", HK_MESSAGE_ID, "ID:frame-1",
+			HK_SUBSCRIPTION, "frame-sub", "escaped", "a:b\\c\nd"}, "frame body"},
+		{RECEIPT, Headers{HK_RECEIPT_ID, "r:1", HK_DESTINATION, "/queue/x"}, ""},
+		{ERROR, Headers{HK_MESSAGE, "bad\nthing", HK_DESTINATION, "/queue/x"}, "details"},
+	}
+)
+
+//=============================================================================
+//= frame_test const ==========================================================
+//=============================================================================
+const (
+	frameBenchMessage = "MESSAGE\n" +
+		"destination:/queue/frame.bench\n" +
+		"message-id:ID:broker-1-1234-1:1:1:1:1\n" +
+		"subscription:bench-sub\n" +
+		"ack:ID:broker-1-1234-1:1:1:1:1\n" +
+		"content-type:text/plain\n" +
+		"content-length:64\n" +
+		"priority:4\n" +
+		"app\\cname:value\\nwith escapes\n" +
+		"\n" +
+		"0123456789012345678901234567890123456789012345678901234567890123\x00"
+	frameCRLF = "SEND\r\ndestination:/queue/crlf\r\n\r\ncrlf\x00" +
+		"BOGUS\n\n\x00"
+	frameConnCRLF = "MESSAGE\r\nsubscription:crlf-sub\r\nmessage-id:m1\r\n" +
+		"destination:/queue/crlf\r\n\r\ncrlf\x00"
+	frameConnNoNul = "MESSAGE\nsubscription:crlf-sub\nmessage-id:m2\n" +
+		"destination:/queue/crlf\ncontent-length:4\n\ncrlfX\x00"
+)
+
+//=============================================================================
+//= sendasync_test type =======================================================
+//=============================================================================
+type (
+// None at present.
+)
+
+//=============================================================================
+//= sendasync_test var ========================================================
+//=============================================================================
+var (
+// None at present.
+)
+
+//=============================================================================
+//= sendasync_test const ======================================================
+//=============================================================================
+const (
+	sendAsyncCount  = 20
+	sendAsyncBatch  = 8
+	sendAsyncLinger = 50 * time.Millisecond
+	sendAsyncDest   = "/queue/async."
+)
+
+//=============================================================================
+//= transaction_test type =====================================================
+//=============================================================================
+type (
+	txTestData struct {
+		err     error // fn result, and WithTransaction result
+		panic   bool  // fn panics with errTx
+		pending int   // Messages left on txDest
+	}
+)
+
+//=============================================================================
+//= transaction_test var ======================================================
+//=============================================================================
+var (
+	errTx    = errors.New("transaction test failure")
+	txBodies = []string{"tx 1", "tx 2"}
+	txData   = []txTestData{
+		{nil, false, 2},
+		{errTx, false, 0},
+		{errTx, true, 0},
+	}
+)
+
+//=============================================================================
+//= transaction_test const ====================================================
+//=============================================================================
+const (
+	txDest = "/queue/tx.test"
+)
+
+//=============================================================================
+//= close_test type ===========================================================
+//=============================================================================
+type (
+// None at present.
+)
+
+//=============================================================================
+//= close_test var ============================================================
+//=============================================================================
+var (
+// None at present.
+)
+
+//=============================================================================
+//= close_test const ==========================================================
+//=============================================================================
+const (
+	closeDest    = "/queue/close.test"
+	closeCount   = 3
+	closePause   = 200 * time.Millisecond
+	closeConsume = 20 * time.Millisecond
+	closeStall   = 2 * time.Second
+)
+
+//=============================================================================
+//= pool_test type ============================================================
+//=============================================================================
+// None at present.
+
+//=============================================================================
+//= pool_test var =============================================================
+//=============================================================================
+// None at present.
+
+//=============================================================================
+//= pool_test const ===========================================================
+//=============================================================================
+const (
+	poolDest    = "/queue/pool.test"
+	poolMax     = 3
+	poolSenders = 8
+	poolSends   = 25
+	poolCheck   = 10 * time.Millisecond
+	poolWait    = 2 * time.Second
+	poolEvictN  = 3
+)
+
+//=============================================================================
+//= rpc_test type =============================================================
+//=============================================================================
+// None at present.
+
+//=============================================================================
+//= rpc_test var ==============================================================
+//=============================================================================
+// None at present.
+
+//=============================================================================
+//= rpc_test const ============================================================
+//=============================================================================
+const (
+	rpcDest     = "/queue/rpc.test"
+	rpcRequests = 10
+	rpcWait     = 2 * time.Second
+	rpcRabbit   = "RabbitMQ/3.13.7"
+)
+
+//=============================================================================
+//= payload_test type =========================================================
+//=============================================================================
+type (
+	payloadOrder struct {
+		Id    int
+		Item  string
+		Price float64
+	}
+)
+
+//=============================================================================
+//= payload_test var ==========================================================
+//=============================================================================
+var (
+	payloadValue = payloadOrder{42, "widget", 9.5}
+)
+
+//=============================================================================
+//= payload_test const ========================================================
+//=============================================================================
+const (
+	payloadDest = "/queue/payload.test"
+)
+
 //=============================================================================
 //= for use by all type =======================================================
 //=============================================================================
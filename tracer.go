@@ -0,0 +1,82 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+)
+
+/*
+	Tracer is an optional hook for distributed tracing of SEND and MESSAGE
+	frames.  The tracing module provides an OpenTelemetry implementation.
+
+	StartSend is called by Send, SendBytes and their variants, with the
+	caller's context, before the SEND frame is handed to the writer.  It
+	returns the headers to send, usually h with trace context headers added,
+	and a function that is called with the result of the send.  Header values
+	are encoded for STOMP 1.1+ when the frame is written, as usual.
+
+	StartMessage is called by the reader for each MESSAGE frame, with headers
+	already decoded, before the frame is delivered.  The function it returns
+	is called once the frame has been delivered or dropped.  It runs on the
+	reader goroutine, and must not block.
+*/
+type Tracer interface {
+	StartSend(ctx context.Context, h Headers) (Headers, func(error))
+	StartMessage(m Message) func()
+}
+
+/*
+	Holder for a Tracer, for atomic use.
+*/
+type tracerRef struct {
+	t Tracer
+}
+
+/*
+	SetTracer sets the connection Tracer.  A nil Tracer disables tracing,
+	which is the default.
+*/
+func (c *Connection) SetTracer(t Tracer) {
+	if t == nil {
+		c.trc.Store(nil)
+		return
+	}
+	c.trc.Store(&tracerRef{t})
+}
+
+/*
+	Start tracing a SEND, if a Tracer is set.
+*/
+func (c *Connection) traceSend(ctx context.Context, h Headers) (Headers, func(error)) {
+	r := c.trc.Load()
+	if r == nil {
+		return h, func(error) {}
+	}
+	return r.t.StartSend(ctx, h)
+}
+
+/*
+	Start tracing a MESSAGE, if a Tracer is set.
+*/
+func (c *Connection) traceMessage(m Message) func() {
+	r := c.trc.Load()
+	if r == nil {
+		return func() {}
+	}
+	return r.t.StartMessage(m)
+}
@@ -0,0 +1,99 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+/*
+	A Tracer that adds a header with an encoded value, and records events.
+*/
+type testTracer struct {
+	mu    sync.Mutex
+	sends []error
+	msgs  []string
+	ends  int
+}
+
+func (tt *testTracer) StartSend(ctx context.Context, h Headers) (Headers, func(error)) {
+	v, _ := ctx.Value(tracerCtxKey{}).(string)
+	return h.Add(tracerHdr, v), func(e error) {
+		tt.mu.Lock()
+		tt.sends = append(tt.sends, e)
+		tt.mu.Unlock()
+	}
+}
+
+func (tt *testTracer) StartMessage(m Message) func() {
+	tt.mu.Lock()
+	tt.msgs = append(tt.msgs, m.Headers.Value(tracerHdr))
+	tt.mu.Unlock()
+	return func() {
+		tt.mu.Lock()
+		tt.ends++
+		tt.mu.Unlock()
+	}
+}
+
+/*
+	Test Tracer calls, and trace header values round trip at all protocol
+	levels.
+*/
+func TestTracerRoundTrip(t *testing.T) {
+	for _, sp := range Protocols() {
+		n, _ = openConn(t)
+		ch := headersProtocol(login_headers, sp)
+		conn, e = Connect(n, ch)
+		if e != nil {
+			t.Fatalf("TestTracerRoundTrip CONNECT expected nil, got %v\n", e)
+		}
+		tt := &testTracer{}
+		conn.SetTracer(tt)
+		d := tdest("/queue/tracer.round.trip." + sp)
+		ctx := context.WithValue(context.Background(), tracerCtxKey{}, tracerValue)
+		e = conn.SendContext(ctx, Headers{HK_DESTINATION, d}, "traced")
+		if e != nil {
+			t.Fatalf("TestTracerRoundTrip Expected nil error, got [%v]\n", e)
+		}
+		sc, e = conn.Subscribe(Headers{HK_DESTINATION, d, HK_ID, d})
+		if e != nil {
+			t.Fatalf("TestTracerRoundTrip Expected no subscribe error, got [%v]\n", e)
+		}
+		md = getMessageData(sc, conn, t)
+		if v := md.Message.Headers.Value(tracerHdr); v != tracerValue {
+			t.Fatalf("TestTracerRoundTrip Expected [%s], got [%s]\n", tracerValue, v)
+		}
+		conn.SetTracer(nil)
+		checkReceived(t, conn, false)
+		e = conn.Disconnect(empty_headers)
+		checkDisconnectError(t, e)
+		_ = closeConn(t, n)
+		//
+		tt.mu.Lock()
+		if len(tt.sends) != 1 || tt.sends[0] != nil {
+			t.Fatalf("TestTracerRoundTrip Expected one good send, got [%v]\n", tt.sends)
+		}
+		if len(tt.msgs) != 1 || tt.msgs[0] != tracerValue || tt.ends != 1 {
+			t.Fatalf("TestTracerRoundTrip Expected one message, got [%v] [%d]\n",
+				tt.msgs, tt.ends)
+		}
+		tt.mu.Unlock()
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/photostorm/stompngo/tracing
+
+go 1.21
+
+require (
+	github.com/photostorm/stompngo v0.0.0-20261018014503-ed5ce6ade001
+	go.opentelemetry.io/otel v1.29.0
+	go.opentelemetry.io/otel/sdk v1.29.0
+	go.opentelemetry.io/otel/trace v1.29.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/otel/metric v1.29.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+)
@@ -0,0 +1,201 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+/*
+	Package tracing is an OpenTelemetry implementation of stompngo.Tracer.
+
+	Each SEND starts a producer span, a child of any span in the send
+	context, and the span context is injected into the frame headers.  By
+	default the W3C traceparent and tracestate headers are used.  Each
+	MESSAGE starts a consumer span, linked to the producer span extracted
+	from the frame headers.  The consumer span ends when the reader has
+	delivered the frame.
+
+	To continue the producer's trace while processing a message, use Extract.
+
+	Example:
+		c.SetTracer(tracing.New())
+		e := c.SendContext(ctx, h, "traced")
+		...
+		md := <-sc
+		ctx, span := tr.Start(tracing.Extract(ctx, md.Message), "process")
+		defer span.End()
+*/
+package tracing
+
+import (
+	"context"
+
+	"github.com/photostorm/stompngo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*
+	Instrumentation scope name.
+*/
+const scope = "github.com/photostorm/stompngo/tracing"
+
+/*
+	Span attribute keys, from the OpenTelemetry messaging conventions.
+*/
+const (
+	akSystem      = "messaging.system"
+	akOperation   = "messaging.operation.name"
+	akDestination = "messaging.destination.name"
+	akMessageId   = "messaging.message.id"
+	akSubId       = "messaging.destination.subscription.name"
+	akBodySize    = "messaging.message.body.size"
+)
+
+/*
+	Tracer implements stompngo.Tracer.
+*/
+type Tracer struct {
+	tp   trace.TracerProvider
+	prop propagation.TextMapPropagator
+	tr   trace.Tracer
+}
+
+/*
+	Option is a function that modifies a Tracer.
+*/
+type Option func(*Tracer)
+
+/*
+	WithTracerProvider sets the TracerProvider.  The default is the global
+	provider, from otel.GetTracerProvider.
+*/
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(t *Tracer) {
+		t.tp = tp
+	}
+}
+
+/*
+	WithPropagator sets the propagator used to inject and extract span
+	contexts.  The default is propagation.TraceContext, the W3C traceparent
+	and tracestate headers.
+*/
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(t *Tracer) {
+		t.prop = p
+	}
+}
+
+/*
+	New returns a Tracer.
+*/
+func New(opts ...Option) *Tracer {
+	t := &Tracer{prop: propagation.TraceContext{}}
+	for _, o := range opts {
+		o(t)
+	}
+	if t.tp == nil {
+		t.tp = otel.GetTracerProvider()
+	}
+	t.tr = t.tp.Tracer(scope)
+	return t
+}
+
+/*
+	StartSend implements stompngo.Tracer.  It starts a producer span, and
+	adds the span context to a copy of h.
+*/
+func (t *Tracer) StartSend(ctx context.Context, h stompngo.Headers) (stompngo.Headers, func(error)) {
+	d := h.Value(stompngo.HK_DESTINATION)
+	ctx, span := t.tr.Start(ctx, "send "+d,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String(akSystem, "stomp"),
+			attribute.String(akOperation, "send"),
+			attribute.String(akDestination, d)))
+	hc := &carrier{h.Clone()}
+	t.prop.Inject(ctx, hc)
+	return hc.h, func(e error) {
+		if e != nil {
+			span.RecordError(e)
+			span.SetStatus(codes.Error, e.Error())
+		}
+		span.End()
+	}
+}
+
+/*
+	StartMessage implements stompngo.Tracer.  It starts a consumer span,
+	linked to any producer span found in the MESSAGE headers.
+*/
+func (t *Tracer) StartMessage(m stompngo.Message) func() {
+	d := m.Headers.Value(stompngo.HK_DESTINATION)
+	opts := []trace.SpanStartOption{trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String(akSystem, "stomp"),
+			attribute.String(akOperation, "receive"),
+			attribute.String(akDestination, d),
+			attribute.String(akMessageId, m.Headers.Value(stompngo.HK_MESSAGE_ID)),
+			attribute.String(akSubId, m.Headers.Value(stompngo.HK_SUBSCRIPTION)),
+			attribute.Int(akBodySize, len(m.Body)))}
+	pc := trace.SpanContextFromContext(t.prop.Extract(context.Background(),
+		&carrier{m.Headers}))
+	if pc.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: pc}))
+	}
+	_, span := t.tr.Start(context.Background(), "receive "+d, opts...)
+	return func() {
+		span.End()
+	}
+}
+
+/*
+	Extract returns ctx with the producer span context from the headers of m,
+	using the W3C trace context headers.  Spans started from the result are
+	part of the producer's trace.
+*/
+func Extract(ctx context.Context, m stompngo.Message) context.Context {
+	return propagation.TraceContext{}.Extract(ctx, &carrier{m.Headers})
+}
+
+/*
+	Extract returns ctx with the producer span context from the headers of m,
+	using the Tracer propagator.
+*/
+func (t *Tracer) Extract(ctx context.Context, m stompngo.Message) context.Context {
+	return t.prop.Extract(ctx, &carrier{m.Headers})
+}
+
+/*
+	carrier adapts Headers to propagation.TextMapCarrier.
+*/
+type carrier struct {
+	h stompngo.Headers
+}
+
+func (c *carrier) Get(k string) string {
+	return c.h.Value(k)
+}
+
+func (c *carrier) Set(k, v string) {
+	c.h = c.h.Delete(k).Add(k, v)
+}
+
+func (c *carrier) Keys() []string {
+	ks := make([]string, 0, len(c.h)/2)
+	for i := 0; i < len(c.h); i += 2 {
+		ks = append(ks, c.h[i])
+	}
+	return ks
+}
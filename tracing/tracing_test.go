@@ -0,0 +1,100 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tracing_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/photostorm/stompngo"
+	"github.com/photostorm/stompngo/stomptest"
+	"github.com/photostorm/stompngo/tracing"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*
+	Test a producer span is injected into a SEND, and a linked consumer span
+	is started for the MESSAGE, at all protocol levels.
+*/
+func TestTracingSendMessage(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	b := stomptest.NewBroker()
+	defer b.Close()
+	for _, p := range stompngo.Protocols() {
+		c, e := stompngo.Connect(b.Pipe(), stompngo.Headers{
+			stompngo.HK_ACCEPT_VERSION, p, stompngo.HK_HOST, "localhost"})
+		if e != nil {
+			t.Fatalf("TestTracingSendMessage Expected [nil], got [%v]\n", e)
+		}
+		c.SetTracer(tracing.New(tracing.WithTracerProvider(tp)))
+		ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+		d := "/queue/tracing." + p
+		e = c.SendContext(ctx, stompngo.Headers{stompngo.HK_DESTINATION, d}, "traced")
+		parent.End()
+		if e != nil {
+			t.Fatalf("TestTracingSendMessage Expected [nil], got [%v]\n", e)
+		}
+		sc, e := c.Subscribe(stompngo.Headers{stompngo.HK_DESTINATION, d,
+			stompngo.HK_ID, d})
+		if e != nil {
+			t.Fatalf("TestTracingSendMessage Expected [nil], got [%v]\n", e)
+		}
+		var md stompngo.MessageData
+		select {
+		case md = <-sc:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("TestTracingSendMessage Expected a message, got none\n")
+		}
+		_ = c.Disconnect(stompngo.Headers{})
+		//
+		if md.Message.Headers.Value("traceparent") == "" {
+			t.Fatalf("TestTracingSendMessage Expected traceparent, got [%v]\n",
+				md.Message.Headers)
+		}
+		sx := trace.SpanContextFromContext(tracing.Extract(context.Background(),
+			md.Message))
+		if sx.TraceID() != parent.SpanContext().TraceID() {
+			t.Fatalf("TestTracingSendMessage Expected trace [%v], got [%v]\n",
+				parent.SpanContext().TraceID(), sx.TraceID())
+		}
+		var prod, cons sdktrace.ReadOnlySpan
+		for _, s := range sr.Ended() {
+			switch {
+			case s.SpanKind() == trace.SpanKindProducer && s.Name() == "send "+d:
+				prod = s
+			case s.SpanKind() == trace.SpanKindConsumer && s.Name() == "receive "+d:
+				cons = s
+			}
+		}
+		if prod == nil || cons == nil {
+			t.Fatalf("TestTracingSendMessage Expected two spans, got [%v]\n", sr.Ended())
+		}
+		if prod.Parent().SpanID() != parent.SpanContext().SpanID() ||
+			prod.SpanContext().SpanID() != sx.SpanID() {
+			t.Fatalf("TestTracingSendMessage Expected producer span, got [%v]\n", prod)
+		}
+		if len(cons.Links()) != 1 ||
+			cons.Links()[0].SpanContext.SpanID() != prod.SpanContext().SpanID() {
+			t.Fatalf("TestTracingSendMessage Expected producer link, got [%v]\n",
+				cons.Links())
+		}
+	}
+}
@@ -0,0 +1,223 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+/*
+	Tx is a STOMP transaction begun by Transaction.  Its methods add the
+	transaction header, and use the context given to Transaction.  A Tx is
+	finished by one successful Commit or Abort, and after that its methods
+	return ETXDONE.
+*/
+type Tx struct {
+	c    *Connection
+	id   string
+	ctx  context.Context
+	mu   sync.Mutex
+	done bool
+}
+
+/*
+	Transaction begins a STOMP transaction with a generated transaction id.
+	The transaction stays open until committed or aborted, or until
+	Disconnect aborts it.
+
+	Example:
+		tx, e := c.Transaction(ctx)
+		if e != nil {
+			// Do something sane ...
+		}
+		e = tx.Send(stompngo.Headers{stompngo.HK_DESTINATION, "/queue/q"}, "m")
+		if e != nil {
+			_ = tx.Abort()
+			// Do something sane ...
+		}
+		e = tx.Commit()
+
+*/
+func (c *Connection) Transaction(ctx context.Context) (*Tx, error) {
+	tx := &Tx{c: c, id: Uuid(), ctx: ctx}
+	if e := c.BeginContext(ctx, Headers{HK_TRANSACTION, tx.id}); e != nil {
+		return nil, e
+	}
+	c.txLock.Lock()
+	if c.txns == nil {
+		c.txns = make(map[string]*Tx)
+	}
+	c.txns[tx.id] = tx
+	c.txLock.Unlock()
+	return tx, nil
+}
+
+/*
+	WithTransaction runs fn in a new transaction.  The transaction is
+	committed if fn returns nil, and aborted if fn returns an error or panics.
+	A panic is passed on after the abort.  fn must not Commit or Abort the Tx.
+
+	If the commit fails the transaction is aborted.  The error returned is
+	fn's error or the commit error, joined with any abort error.  The abort is
+	sent even if ctx is done.
+*/
+func (c *Connection) WithTransaction(ctx context.Context, fn func(*Tx) error) error {
+	tx, e := c.Transaction(ctx)
+	if e != nil {
+		return e
+	}
+	ac := context.WithoutCancel(ctx)
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.finish(ac, ABORT)
+			panic(r)
+		}
+	}()
+	if e = fn(tx); e == nil {
+		if e = tx.Commit(); e == nil {
+			return nil
+		}
+	}
+	if ae := tx.finish(ac, ABORT); ae != nil {
+		return errors.Join(e, ae)
+	}
+	return e
+}
+
+/*
+	Id returns the transaction id.
+*/
+func (tx *Tx) Id() string {
+	return tx.id
+}
+
+/*
+	Send sends a STOMP MESSAGE in the transaction.  See Connection.Send.
+*/
+func (tx *Tx) Send(h Headers, b string) error {
+	if e := tx.check(); e != nil {
+		return e
+	}
+	return tx.c.SendContext(tx.ctx, tx.headers(h), b)
+}
+
+/*
+	Ack acks a STOMP MESSAGE in the transaction.  See Connection.Ack.
+*/
+func (tx *Tx) Ack(h Headers) error {
+	if e := tx.check(); e != nil {
+		return e
+	}
+	return tx.c.AckContext(tx.ctx, tx.headers(h))
+}
+
+/*
+	Nack nacks a STOMP MESSAGE in the transaction.  See Connection.Nack.
+*/
+func (tx *Tx) Nack(h Headers) error {
+	if e := tx.check(); e != nil {
+		return e
+	}
+	return tx.c.NackContext(tx.ctx, tx.headers(h))
+}
+
+/*
+	Commit commits the transaction.  If an error is returned the transaction
+	is still open, and may be aborted.
+*/
+func (tx *Tx) Commit() error {
+	return tx.finish(tx.ctx, COMMIT)
+}
+
+/*
+	Abort aborts the transaction.  If an error is returned the transaction
+	is still open.
+*/
+func (tx *Tx) Abort() error {
+	return tx.finish(tx.ctx, ABORT)
+}
+
+/*
+	Check the transaction is open.
+*/
+func (tx *Tx) check() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ETXDONE
+	}
+	return nil
+}
+
+/*
+	Headers for a frame in the transaction.
+*/
+func (tx *Tx) headers(h Headers) Headers {
+	return h.Delete(HK_TRANSACTION).Add(HK_TRANSACTION, tx.id)
+}
+
+/*
+	COMMIT or ABORT the transaction, and stop tracking it if that succeeds.
+*/
+func (tx *Tx) finish(ctx context.Context, cmd string) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ETXDONE
+	}
+	h := Headers{HK_TRANSACTION, tx.id}
+	var e error
+	if cmd == COMMIT {
+		e = tx.c.CommitContext(ctx, h)
+	} else {
+		e = tx.c.AbortContext(ctx, h)
+	}
+	if e != nil {
+		return e
+	}
+	tx.done = true
+	tx.c.txLock.Lock()
+	delete(tx.c.txns, tx.id)
+	tx.c.txLock.Unlock()
+	return nil
+}
+
+/*
+	Abort every open transaction, for Disconnect.  They are finished even if
+	the ABORT fails.
+*/
+func (c *Connection) abortTransactions(ctx context.Context) {
+	c.txLock.Lock()
+	txs := make([]*Tx, 0, len(c.txns))
+	for _, tx := range c.txns {
+		txs = append(txs, tx)
+	}
+	c.txLock.Unlock()
+	for _, tx := range txs {
+		if e := tx.finish(ctx, ABORT); e != nil && e != ETXDONE {
+			c.log(DISCONNECT, "transaction abort", tx.id, e)
+		}
+		tx.mu.Lock()
+		tx.done = true
+		tx.mu.Unlock()
+	}
+	c.txLock.Lock()
+	c.txns = nil
+	c.txLock.Unlock()
+}
@@ -0,0 +1,201 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stompngo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/photostorm/stompngo/stomptest"
+)
+
+/*
+	Test WithTransaction commits on success, and aborts on an error or a
+	panic.
+*/
+func TestTxWithTransaction(t *testing.T) {
+	for _, td := range txData {
+		b := stomptest.NewBroker()
+		c, e := Connect(b.Pipe(), ctxHeaders)
+		if e != nil {
+			t.Fatalf("TestTxWithTransaction Expected [nil], got [%v]\n", e)
+		}
+		var tid string
+		e = txRun(c, func(tx *Tx) error {
+			tid = tx.Id()
+			for _, m := range txBodies {
+				if se := tx.Send(Headers{HK_DESTINATION, txDest}, m); se != nil {
+					return se
+				}
+			}
+			if td.panic {
+				panic(errTx)
+			}
+			return td.err
+		})
+		if !errors.Is(e, td.err) {
+			t.Fatalf("TestTxWithTransaction Expected [%v], got [%v]\n", td.err, e)
+		}
+		if tid == "" {
+			t.Fatalf("TestTxWithTransaction Expected a transaction id\n")
+		}
+		if len(c.txns) != 0 {
+			t.Fatalf("TestTxWithTransaction Expected no open transactions, got [%d]\n",
+				len(c.txns))
+		}
+		if e = c.Disconnect(empty_headers); e != nil {
+			t.Fatalf("TestTxWithTransaction Expected [nil], got [%v]\n", e)
+		}
+		if p := b.Pending(txDest); p != td.pending {
+			t.Fatalf("TestTxWithTransaction Expected [%d] pending, got [%d]\n",
+				td.pending, p)
+		}
+		_ = b.Close()
+	}
+}
+
+/*
+	Run WithTransaction, returning any panic as an error.
+*/
+func txRun(c *Connection, fn func(*Tx) error) (e error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e = r.(error)
+		}
+	}()
+	return c.WithTransaction(context.Background(), fn)
+}
+
+/*
+	Test WithTransaction aborts when the commit fails.
+*/
+func TestTxCommitFailure(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	c, e := Connect(b.Pipe(), ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestTxCommitFailure Expected [nil], got [%v]\n", e)
+	}
+	ctx, cf := context.WithCancel(context.Background())
+	e = c.WithTransaction(ctx, func(tx *Tx) error {
+		if se := tx.Send(Headers{HK_DESTINATION, txDest}, txBodies[0]); se != nil {
+			return se
+		}
+		cf() // The COMMIT fails
+		return nil
+	})
+	if !errors.Is(e, context.Canceled) {
+		t.Fatalf("TestTxCommitFailure Expected [%v], got [%v]\n", context.Canceled, e)
+	}
+	if len(c.txns) != 0 {
+		t.Fatalf("TestTxCommitFailure Expected no open transactions, got [%d]\n",
+			len(c.txns))
+	}
+	if e = c.Disconnect(empty_headers); e != nil {
+		t.Fatalf("TestTxCommitFailure Expected [nil], got [%v]\n", e)
+	}
+	if p := b.Pending(txDest); p != 0 {
+		t.Fatalf("TestTxCommitFailure Expected [0] pending, got [%d]\n", p)
+	}
+}
+
+/*
+	Test Disconnect aborts an open transaction, and the Tx is then finished.
+*/
+func TestTxDisconnect(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	c, e := Connect(b.Pipe(), ctxHeaders)
+	if e != nil {
+		t.Fatalf("TestTxDisconnect Expected [nil], got [%v]\n", e)
+	}
+	tx, e := c.Transaction(context.Background())
+	if e != nil {
+		t.Fatalf("TestTxDisconnect Expected [nil], got [%v]\n", e)
+	}
+	if e = tx.Send(Headers{HK_DESTINATION, txDest}, txBodies[0]); e != nil {
+		t.Fatalf("TestTxDisconnect Expected [nil], got [%v]\n", e)
+	}
+	if e = c.Disconnect(empty_headers); e != nil {
+		t.Fatalf("TestTxDisconnect Expected [nil], got [%v]\n", e)
+	}
+	if p := b.Pending(txDest); p != 0 {
+		t.Fatalf("TestTxDisconnect Expected [0] pending, got [%d]\n", p)
+	}
+	if e = tx.Commit(); e != ETXDONE {
+		t.Fatalf("TestTxDisconnect Expected [%v], got [%v]\n", ETXDONE, e)
+	}
+	if e = tx.Send(Headers{HK_DESTINATION, txDest}, txBodies[0]); e != ETXDONE {
+		t.Fatalf("TestTxDisconnect Expected [%v], got [%v]\n", ETXDONE, e)
+	}
+}
+
+/*
+	Test a transactional ACK takes effect only when committed.
+*/
+func TestTxAck(t *testing.T) {
+	for _, commit := range []bool{true, false} {
+		b := stomptest.NewBroker()
+		c, e := Connect(b.Pipe(), ctxHeaders)
+		if e != nil {
+			t.Fatalf("TestTxAck Expected [nil], got [%v]\n", e)
+		}
+		sc, e := c.Subscribe(Headers{HK_DESTINATION, txDest, HK_ID, txDest,
+			HK_ACK, AckModeClientIndividual})
+		if e != nil {
+			t.Fatalf("TestTxAck Expected [nil], got [%v]\n", e)
+		}
+		if e = c.Send(Headers{HK_DESTINATION, txDest}, txBodies[0]); e != nil {
+			t.Fatalf("TestTxAck Expected [nil], got [%v]\n", e)
+		}
+		md := <-sc
+		if md.Error != nil {
+			t.Fatalf("TestTxAck Expected [nil], got [%v]\n", md.Error)
+		}
+		tx, e := c.Transaction(context.Background())
+		if e != nil {
+			t.Fatalf("TestTxAck Expected [nil], got [%v]\n", e)
+		}
+		if e = tx.Ack(Headers{HK_ID, md.Message.Headers.Value(HK_ACK)}); e != nil {
+			t.Fatalf("TestTxAck Expected [nil], got [%v]\n", e)
+		}
+		if commit {
+			e = tx.Commit()
+		} else {
+			e = tx.Abort()
+		}
+		if e != nil {
+			t.Fatalf("TestTxAck Expected [nil], got [%v]\n", e)
+		}
+		if e = c.Unsubscribe(Headers{HK_ID, txDest}); e != nil {
+			t.Fatalf("TestTxAck Expected [nil], got [%v]\n", e)
+		}
+		if e = c.Disconnect(empty_headers); e != nil {
+			t.Fatalf("TestTxAck Expected [nil], got [%v]\n", e)
+		}
+		want := 1 // Redelivered on unsubscribe
+		if commit {
+			want = 0
+		}
+		if p := b.Pending(txDest); p != want {
+			t.Fatalf("TestTxAck commit:%v Expected [%d] pending, got [%d]\n",
+				commit, want, p)
+		}
+		_ = b.Close()
+	}
+}
@@ -16,16 +16,63 @@
 
 package stompngo
 
+import (
+	"context"
+)
+
 /*
 	Common transmit data for many stomp API calls.
 */
-func (c *Connection) transmitCommon(v string, h Headers) error {
+func (c *Connection) transmitCommon(ctx context.Context, v string, h Headers,
+	rcpt bool) error {
 	ch := h.Clone()
 	f := Frame{v, ch, NULLBUFF}
-	r := make(chan error)
-	if e := c.writeWireData(wiredata{f, r}); e != nil {
+	return c.transmitFrame(ctx, f, rcpt)
+}
+
+/*
+	Transmit a frame, and if rcpt is set, wait for the broker's RECEIPT.
+*/
+func (c *Connection) transmitFrame(ctx context.Context, f Frame, rcpt bool) error {
+	return c.transmitStream(ctx, f, nil, rcpt)
+}
+
+/*
+	Transmit a frame with a body streamed from bs, if bs is not nil, and if rcpt
+	is set, wait for the broker's RECEIPT.
+*/
+func (c *Connection) transmitStream(ctx context.Context, f Frame, bs *sendStream,
+	rcpt bool) error {
+	if e := c.opBegin(ctx, f.Command); e != nil {
+		return e
+	}
+	defer c.opEnd()
+	if rcpt {
+		return c.transmitReceipt(ctx, f, bs)
+	}
+	return c.transmitWire(ctx, f, bs)
+}
+
+/*
+	Hand a frame to the logical network writer and wait for the result of the
+	wire write.
+
+	The error channel is buffered, so the writer never blocks if the caller
+	gives up early because ctx is done.
+*/
+func (c *Connection) transmitWire(ctx context.Context, f Frame, bs *sendStream) error {
+	r := make(chan error, 1)
+	select {
+	case c.output <- wiredata{f, r, ctx, bs, nil}:
+	case <-c.ssdc:
+		return ECONBAD
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case e := <-r:
 		return e
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	e := <-r
-	return e
 }
@@ -17,6 +17,7 @@
 package stompngo
 
 import (
+	"context"
 	"strconv"
 	"time"
 )
@@ -38,7 +39,23 @@ import (
 
 */
 func (c *Connection) Unsubscribe(h Headers) error {
-	c.log(UNSUBSCRIBE, "start", h)
+	return c.UnsubscribeContext(context.Background(), h)
+}
+
+/*
+	UnsubscribeContext unsubscribes from a STOMP subscription, honoring ctx
+	cancellation and deadlines.  See Unsubscribe for details.
+*/
+func (c *Connection) UnsubscribeContext(ctx context.Context, h Headers) error {
+	return c.unsubscribe(ctx, h, false)
+}
+
+/*
+	Unsubscribe, waiting for the broker's RECEIPT if rcpt is set.
+*/
+func (c *Connection) unsubscribe(ctx context.Context, h Headers,
+	rcpt bool) error {
+	c.logcmd(UNSUBSCRIBE, "start", h)
 	// fmt.Printf("Unsub Headers: %v\n", h)
 	if !c.isConnected() {
 		return ECONBAD
@@ -69,7 +86,7 @@ func (c *Connection) Unsubscribe(h Headers) error {
 			return EUNODSID
 		}
 	default:
-		panic("unsubscribe version not supported: " + c.Protocol())
+		return EBADVERUNS
 	}
 	//
 	shaid := Sha1(h.Value(HK_DESTINATION)) // Special for 1.0
@@ -99,21 +116,19 @@ func (c *Connection) Unsubscribe(h Headers) error {
 		usekey = shaid
 		usesp = s10
 	default:
-		panic("unsubscribe version not supported: " + c.Protocol())
+		return EBADVERUNS
 	}
 
 	sdn, ok := h.Contains(StompPlusDrainNow) // STOMP Protocol Extension
 
 	if !ok {
-		e = c.transmitCommon(UNSUBSCRIBE, h) // transmitCommon Clones() the headers
+		e = c.transmitCommon(ctx, UNSUBSCRIBE, h, rcpt) // transmitCommon Clones() the headers
 		if e != nil {
 			return e
 		}
 
-		c.subsLock.Lock()
-		delete(c.subs, usekey)
-		c.subsLock.Unlock()
-		c.log(UNSUBSCRIBE, "end", h)
+		c.removeSubscription(usekey)
+		c.logcmd(UNSUBSCRIBE, "end", h)
 		return nil
 	}
 	//
@@ -141,13 +156,23 @@ forsel:
 		case <-time.After(ival):
 			c.log("sngdrnow extension BREAK")
 			break forsel
+		case <-ctx.Done():
+			c.log("sngdrnow extension CTXDONE")
+			break forsel
 		}
 	}
 	//
 	c.log("sngdrnow extension at very end")
-	c.subsLock.Lock()
-	delete(c.subs, usekey)
-	c.subsLock.Unlock()
-	c.log(UNSUBSCRIBE, "endsngdrnow", h)
+	c.removeSubscription(usekey)
+	c.logcmd(UNSUBSCRIBE, "endsngdrnow", h)
 	return nil
 }
+
+/*
+	UnsubscribeWithReceipt unsubscribes from a STOMP subscription, and waits
+	for the broker's RECEIPT.  See SendWithReceipt and Unsubscribe for
+	details.
+*/
+func (c *Connection) UnsubscribeWithReceipt(ctx context.Context, h Headers) error {
+	return c.unsubscribe(ctx, h, true)
+}
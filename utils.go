@@ -17,98 +17,158 @@
 package stompngo
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
-	"io"
 	"strings"
-	"time"
 )
 
 /*
-	Encode a string per STOMP 1.1+ specifications.
+	Encode a string per STOMP 1.1+ specifications, in a single pass.  A string
+	with nothing to escape is returned as is, with no allocation.
 */
 func encode(s string) string {
-	r := s
-	for _, tr := range codecValues {
-		if strings.Index(r, tr.decoded) >= 0 {
-			r = strings.Replace(r, tr.decoded, tr.encoded, -1)
+	i := encodeIndex(s)
+	if i < 0 {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(encodedLen(s))
+	b.WriteString(s[:i])
+	for ; i < len(s); i++ {
+		if x := encodeEscape(s[i]); x != "" {
+			b.WriteString(x)
+		} else {
+			b.WriteByte(s[i])
 		}
 	}
-	return r
+	return b.String()
 }
 
 /*
-	Decode a string per STOMP 1.1+ specifications.
+	Decode a string per STOMP 1.1+ specifications, in a single pass.  A string
+	with no escapes is returned as is, with no allocation.  An unknown escape
+	sequence is kept as is.
 */
 func decode(s string) string {
-	r := s
-	for _, tr := range codecValues {
-		if strings.Index(r, tr.encoded) >= 0 {
-			r = strings.Replace(r, tr.encoded, tr.decoded, -1)
+	i := strings.IndexByte(s, '\\')
+	if i < 0 {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	b.WriteString(s[:i])
+	for ; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			if d := decodeEscape(s[i+1]); d != 0 {
+				b.WriteByte(d)
+				i++
+				continue
+			}
 		}
+		b.WriteByte(s[i])
 	}
-	return r
+	return b.String()
 }
 
 /*
-	A network helper.  Read from the wire until a 0x00 byte is encountered.
+	Append the decoded form of p to b.  An unknown escape sequence is kept
+	as is.
 */
-func readUntilNul(c *Connection) ([]uint8, error) {
-	var b []byte
-	var e error
-	c.setReadDeadline()
+func appendDecoded(b, p []byte) []byte {
+	for {
+		i := bytes.IndexByte(p, '\\')
+		if i < 0 || i+1 == len(p) {
+			return append(b, p...)
+		}
+		b = append(b, p[:i]...)
+		if d := decodeEscape(p[i+1]); d != 0 {
+			b = append(b, d)
+		} else {
+			b = append(b, p[i:i+2]...)
+		}
+		p = p[i+2:]
+	}
+}
 
-	if c.eltd != nil {
-		st := time.Now().UnixNano()
-		b, e = c.rdr.ReadBytes(0)
-		c.eltd.run.ens += time.Now().UnixNano() - st
-		c.eltd.run.ec++
+/*
+	The escape sequence for a byte, or "" if it needs none.
+*/
+func encodeEscape(c byte) string {
+	switch c {
+	case '\\':
+		return "\\\\"
+	case '\n':
+		return "\\n"
+	case '\r':
+		return "\\r"
+	case ':':
+		return "\\c"
+	}
+	return ""
+}
 
-	} else {
-		b, e = c.rdr.ReadBytes(0)
-	}
+/*
+	The byte for the character following a backslash, or 0 if the escape is
+	not known.
+*/
+func decodeEscape(c byte) byte {
+	switch c {
+	case '\\':
+		return '\\'
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 'c':
+		return ':'
+	}
+	return 0
+}
 
-	if c.checkReadError(e) != nil {
-		return b, e
-	}
-	if len(b) == 1 {
-		b = NULLBUFF
-	} else {
-		b = b[0 : len(b)-1]
+/*
+	The index of the first byte of s needing an escape, or -1.
+*/
+func encodeIndex(s string) int {
+	for i := 0; i < len(s); i++ {
+		if encodeEscape(s[i]) != "" {
+			return i
+		}
 	}
-	return b, e
+	return -1
 }
 
 /*
-	A network helper.  Read a full message body with a known length that is
-	> 0.  Then read the trailing 'null' byte expected for STOMP frames.
+	The length of s once encoded.
 */
-func readBody(c *Connection, l int) ([]uint8, error) {
-	b := make([]byte, l)
-	c.setReadDeadline()
-	var n int
-	var e error
-	if c.eltd != nil {
-		st := time.Now().UnixNano()
-		n, e = io.ReadFull(c.rdr, b)
-		c.eltd.rbdy.ens += time.Now().UnixNano() - st
-		c.eltd.rbdy.ec++
-	} else {
-		n, e = io.ReadFull(c.rdr, b)
+func encodedLen(s string) int {
+	l := len(s)
+	for i := 0; i < len(s); i++ {
+		if encodeEscape(s[i]) != "" {
+			l++
+		}
 	}
+	return l
+}
 
-	if n < l && n != 0 { // Short read, e is ErrUnexpectedEOF
-		c.log("SHORT READ", n, l, e)
-		return b[0 : n-1], e
-	}
-	if c.checkReadError(e) != nil { // Other erors
-		return b, e
+/*
+	Write s to w, encoded if enc is true, without building the encoded string.
+*/
+func writeEncoded(w *bufio.Writer, s string, enc bool) error {
+	if !enc {
+		_, e := w.WriteString(s)
+		return e
 	}
-	c.setReadDeadline()
-	_, _ = c.rdr.ReadByte()         // trailing NUL
-	if c.checkReadError(e) != nil { // Other erors
-		return b, e
+	st := 0
+	for i := 0; i < len(s); i++ {
+		if x := encodeEscape(s[i]); x != "" {
+			_, _ = w.WriteString(s[st:i])
+			_, _ = w.WriteString(x)
+			st = i + 1
+		}
 	}
-	return b, e
+	_, e := w.WriteString(s[st:])
+	return e
 }
 
 /*
@@ -17,14 +17,23 @@
 package stompngo
 
 import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/hex"
 	"fmt"
 	"log"
+	"math/big"
 	"net"
 	"os"
 	"runtime/debug"
 	"strings"
 	"testing"
+	"time"
 
 	//
 	"github.com/photostorm/stompngo/senv"
@@ -293,3 +302,184 @@ func checkDupeHeaders(ms, wh Headers) error {
 	}
 	return nil
 }
+
+/*
+   Test helper.  Connect over a net.Pipe, with the test itself playing the
+   broker.  The CONNECT frame is consumed and resp is returned to the client.
+*/
+func pipeConnect(t *testing.T, ch Headers, resp string) (*Connection, *pipe_broker) {
+	cn, sn := net.Pipe()
+	pb := &pipe_broker{n: sn, r: bufio.NewReader(sn)}
+	go func() {
+		if _, e := pb.readFrame(); e != nil {
+			return
+		}
+		_, _ = pb.n.Write([]byte(resp))
+	}()
+	c, e := Connect(cn, ch)
+	if e != nil {
+		debug.PrintStack()
+		t.Fatalf("Unexpected pipe CONNECT error: %v\n", e)
+	}
+	return c, pb
+}
+
+/*
+   Test helper.  Read one frame, as raw text, from the broker side of a pipe.
+   Heart beats are skipped.
+*/
+func (pb *pipe_broker) readFrame() (string, error) {
+	for {
+		s, e := pb.r.ReadString(0)
+		if e != nil {
+			return s, e
+		}
+		s = strings.TrimLeft(s, "\n")
+		if s != "" {
+			return s, nil
+		}
+	}
+}
+
+/*
+   Test helper.  Listen on a loopback port, plain or TLS, and run one broker
+   conversation per accepted connection.  Returns the listen address.
+*/
+func listenBroker(t *testing.T, tc *tls.Config, bf func(pb *pipe_broker)) string {
+	l, err := net.Listen(NetProtoTCP, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected net.Listen error: %v\n", err)
+	}
+	if tc != nil {
+		l = tls.NewListener(l, tc)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			sn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer sn.Close()
+				bf(&pipe_broker{n: sn, r: bufio.NewReader(sn)})
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+/*
+   Test helper.  Generate a self signed certificate for "stomp.example".
+*/
+func testCertificate(t *testing.T) tls.Certificate {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected GenerateKey error: %v\n", err)
+	}
+	ct := &x509.Certificate{SerialNumber: big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "stomp.example"},
+		DNSNames:              []string{"stomp.example"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true}
+	der, err := x509.CreateCertificate(rand.Reader, ct, ct, &k.PublicKey, k)
+	if err != nil {
+		t.Fatalf("Unexpected CreateCertificate error: %v\n", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Unexpected ParseCertificate error: %v\n", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: k, Leaf: leaf}
+}
+
+/*
+   Test helper.  Get a header value from a raw frame read by a pipe_broker.
+*/
+func rawHeader(f, k string) string {
+	for _, l := range strings.Split(f, "\n")[1:] {
+		if l == "" {
+			break
+		}
+		if strings.HasPrefix(l, k+":") {
+			return l[len(k)+1:]
+		}
+	}
+	return ""
+}
+
+/*
+   Test helper.  Write log output, safe for concurrent use.
+*/
+func (lb *log_buffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.b.Write(p)
+}
+
+/*
+   Test helper.  Current log output.
+*/
+func (lb *log_buffer) String() string {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.b.String()
+}
+
+/*
+   Test helper.  Wait up to testlgslt ms for log output containing s.
+*/
+func (lb *log_buffer) waitFor(s string) bool {
+	dl := time.Now().Add(testlgslt * time.Millisecond)
+	for time.Now().Before(dl) {
+		if strings.Contains(lb.String(), s) {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+/*
+   Test helper.  Set the write deadline.  Setting a future deadline pauses
+   first, like a writer descheduled between frame parts.
+*/
+func (sc *slow_arm_conn) SetWriteDeadline(t time.Time) error {
+	if t.After(time.Now()) {
+		time.Sleep(sc.arm)
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.wdl = t
+	return nil
+}
+
+/*
+   Test helper.  Write, failing if the write deadline has passed.
+*/
+func (sc *slow_arm_conn) Write(p []byte) (int, error) {
+	sc.mu.Lock()
+	wdl := sc.wdl
+	sc.mu.Unlock()
+	if !wdl.IsZero() && wdl.Before(time.Now()) {
+		return 0, os.ErrDeadlineExceeded
+	}
+	return sc.Conn.Write(p)
+}
+
+/*
+   Test helper.  Read, and cancel the handshake context once the CONNECTED
+   frame is read, giving the context watch time to see it.
+*/
+func (cc *cancel_read_conn) Read(p []byte) (int, error) {
+	n, e := cc.Conn.Read(p)
+	if strings.Contains(string(p[:n]), CONNECTED) {
+		cc.cf()
+		time.Sleep(dialCancelPause)
+	}
+	return n, e
+}
@@ -0,0 +1,125 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/coder/websocket"
+	"github.com/photostorm/stompngo"
+)
+
+/*
+	A WebSocket as a net.Conn, writing one message per STOMP frame.
+*/
+type conn struct {
+	net.Conn            // Reads all messages as one stream
+	wmu      sync.Mutex // Protects pend
+	pend     []byte     // Written data not yet a complete frame
+}
+
+/*
+	NetConn returns an established WebSocket as a net.Conn for
+	stompngo.Connect or a broker.  Writes are collected into one message per
+	STOMP frame or heart beat, however they are split by the caller.  Closing
+	it closes the WebSocket.
+*/
+func NetConn(ws *websocket.Conn, binary bool) net.Conn {
+	mt := websocket.MessageText
+	if binary {
+		mt = websocket.MessageBinary
+	}
+	return &conn{Conn: websocket.NetConn(context.Background(), ws, mt)}
+}
+
+/*
+	Write data, sending each complete frame as a message.
+*/
+func (c *conn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.pend = append(c.pend, p...)
+	st := 0
+	for {
+		n := frameLen(c.pend[st:])
+		if n == 0 {
+			break
+		}
+		if _, e := c.Conn.Write(c.pend[st : st+n]); e != nil {
+			c.pend = c.pend[:0]
+			return 0, e
+		}
+		st += n
+	}
+	c.pend = c.pend[:copy(c.pend, c.pend[st:])]
+	return len(p), nil
+}
+
+/*
+	The length of the first complete frame or heart beat in b, 0 if there is
+	none yet.
+*/
+func frameLen(b []byte) int {
+	switch {
+	case len(b) == 0:
+		return 0
+	case b[0] == '\n':
+		return 1
+	case b[0] == '\r':
+		if len(b) > 1 && b[1] == '\n' {
+			return 2
+		}
+		return 0
+	}
+	he := bytes.Index(b, []byte("\n\n"))
+	if he < 0 {
+		return 0
+	}
+	bs := he + 2
+	if cl := contentLength(b[:he]); cl >= 0 {
+		if len(b) < bs+cl+1 {
+			return 0
+		}
+		return bs + cl + 1
+	}
+	i := bytes.IndexByte(b[bs:], 0)
+	if i < 0 {
+		return 0
+	}
+	return bs + i + 1
+}
+
+/*
+	The first content-length header value in a frame head, -1 if none.
+*/
+func contentLength(hd []byte) int {
+	k := []byte(stompngo.HK_CONTENT_LENGTH + ":")
+	for _, l := range bytes.Split(hd, []byte{'\n'})[1:] {
+		if bytes.HasPrefix(l, k) {
+			n, e := strconv.Atoi(string(bytes.TrimSpace(l[len(k):])))
+			if e != nil || n < 0 {
+				return -1
+			}
+			return n
+		}
+	}
+	return -1
+}
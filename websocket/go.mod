@@ -0,0 +1,8 @@
+module github.com/photostorm/stompngo/websocket
+
+go 1.21
+
+require (
+	github.com/coder/websocket v1.8.12
+	github.com/photostorm/stompngo v0.0.0-20261018014503-ed5ce6ade001
+)
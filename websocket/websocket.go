@@ -0,0 +1,199 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+/*
+	Package websocket is a STOMP over WebSocket transport for stompngo.
+
+	The STOMP subprotocols v12.stomp, v11.stomp and v10.stomp are offered for
+	the versions in the accept-version header, and the accept-version sent is
+	narrowed to the subprotocol the server selects.  Each STOMP frame is
+	sent as one WebSocket message, and each heart beat as a message holding
+	a single EOL.  Received messages are read as one stream, so a message
+	may hold any number of frames or heart beats.
+
+	Accept is the server side, for use with stomptest or stompserver.
+
+	Example:
+		h := stompngo.Headers{stompngo.HK_ACCEPT_VERSION, "1.2,1.1",
+			stompngo.HK_HOST, "localhost"}
+		c, e := websocket.Dial(ctx, "ws://localhost:15674/ws", h)
+		if e != nil {
+			// Do something sane ...
+		}
+		// Use c
+*/
+package websocket
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/coder/websocket"
+	"github.com/photostorm/stompngo"
+)
+
+/*
+	STOMP WebSocket subprotocols, by protocol level.
+*/
+var subprotocols = map[string]string{
+	stompngo.SPL_10: "v10.stomp",
+	stompngo.SPL_11: "v11.stomp",
+	stompngo.SPL_12: "v12.stomp",
+}
+
+/*
+	Option is a function that modifies Dial and Accept behavior.
+*/
+type Option func(*config)
+
+type config struct {
+	hdr    http.Header           // Dial HTTP headers
+	hc     *http.Client          // Dial HTTP client
+	binary bool                  // Use binary messages
+	origin []string              // Accept origin patterns
+	dopts  []stompngo.DialOption // Connection options
+}
+
+/*
+	WithHTTPHeader adds HTTP headers to the WebSocket handshake request.
+*/
+func WithHTTPHeader(h http.Header) Option {
+	return func(cf *config) {
+		cf.hdr = h
+	}
+}
+
+/*
+	WithHTTPClient sets the HTTP client used for the WebSocket handshake, for
+	TLS settings or proxies.
+*/
+func WithHTTPClient(hc *http.Client) Option {
+	return func(cf *config) {
+		cf.hc = hc
+	}
+}
+
+/*
+	WithBinary sends and expects binary WebSocket messages.  The default is
+	text messages, as most brokers use.
+*/
+func WithBinary() Option {
+	return func(cf *config) {
+		cf.binary = true
+	}
+}
+
+/*
+	WithOriginPatterns sets the origins Accept allows besides the request
+	host.  See the OriginPatterns field of websocket.AcceptOptions.
+*/
+func WithOriginPatterns(p ...string) Option {
+	return func(cf *config) {
+		cf.origin = p
+	}
+}
+
+/*
+	WithDialOptions sets the stompngo options for the Connection Dial returns.
+*/
+func WithDialOptions(o ...stompngo.DialOption) Option {
+	return func(cf *config) {
+		cf.dopts = o
+	}
+}
+
+/*
+	Subprotocols returns the WebSocket subprotocols for an accept-version
+	header value, highest level first.  An empty value means STOMP 1.0.
+*/
+func Subprotocols(av string) []string {
+	if av == "" {
+		return []string{subprotocols[stompngo.SPL_10]}
+	}
+	var r []string
+	for _, p := range []string{stompngo.SPL_12, stompngo.SPL_11, stompngo.SPL_10} {
+		for _, v := range strings.Split(av, ",") {
+			if strings.TrimSpace(v) == p {
+				r = append(r, subprotocols[p])
+				break
+			}
+		}
+	}
+	return r
+}
+
+/*
+	Dial opens a WebSocket to rawurl, a ws:// or wss:// URL, and performs the
+	CONNECT handshake.  h is used as with stompngo.Connect.  ctx bounds both
+	the WebSocket and CONNECT handshakes.  The returned Connection owns the
+	WebSocket, as with stompngo.Dial.
+*/
+func Dial(ctx context.Context, rawurl string, h stompngo.Headers,
+	opts ...Option) (*stompngo.Connection, error) {
+	var cf config
+	for _, o := range opts {
+		o(&cf)
+	}
+	if h == nil {
+		return nil, stompngo.EHDRNIL
+	}
+	av := h.Value(stompngo.HK_ACCEPT_VERSION)
+	ws, _, e := websocket.Dial(ctx, rawurl, &websocket.DialOptions{
+		HTTPClient:   cf.hc,
+		HTTPHeader:   cf.hdr,
+		Subprotocols: Subprotocols(av)})
+	if e != nil {
+		return nil, e
+	}
+	if p := version(ws.Subprotocol()); p != "" && av != "" {
+		h = h.Clone().Delete(stompngo.HK_ACCEPT_VERSION).
+			Add(stompngo.HK_ACCEPT_VERSION, p)
+	}
+	return stompngo.DialConn(ctx, NetConn(ws, cf.binary), h, cf.dopts...)
+}
+
+/*
+	Accept upgrades an HTTP request to a STOMP WebSocket, selecting the
+	highest subprotocol the client offers, and returns it as a net.Conn for
+	a broker.  On error an HTTP error response has already been written.
+*/
+func Accept(w http.ResponseWriter, r *http.Request, opts ...Option) (net.Conn, error) {
+	var cf config
+	for _, o := range opts {
+		o(&cf)
+	}
+	ws, e := websocket.Accept(w, r, &websocket.AcceptOptions{
+		Subprotocols:   Subprotocols(strings.Join(stompngo.Protocols(), ",")),
+		OriginPatterns: cf.origin})
+	if e != nil {
+		return nil, e
+	}
+	return NetConn(ws, cf.binary), nil
+}
+
+/*
+	The protocol level of a STOMP subprotocol, "" if unknown.
+*/
+func version(sp string) string {
+	for p, v := range subprotocols {
+		if v == sp {
+			return p
+		}
+	}
+	return ""
+}
@@ -0,0 +1,210 @@
+//
+// Copyright © 2026 Guy M. Allard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package websocket_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ws "github.com/coder/websocket"
+	"github.com/photostorm/stompngo"
+	"github.com/photostorm/stompngo/stomptest"
+	"github.com/photostorm/stompngo/websocket"
+)
+
+const testWait = 5 * time.Second
+
+/*
+	Connect headers for a protocol level.
+*/
+func connHeaders(p string) stompngo.Headers {
+	return stompngo.Headers{stompngo.HK_ACCEPT_VERSION, p,
+		stompngo.HK_HOST, "localhost"}
+}
+
+/*
+	The ws:// URL of a test server.
+*/
+func wsURL(s *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+/*
+	Test a round trip through a broker served over WebSocket, at all protocol
+	levels, with a body larger than the write buffer and the default
+	WebSocket read limit.
+*/
+func TestWebSocketRoundTrip(t *testing.T) {
+	b := stomptest.NewBroker()
+	defer b.Close()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, e := websocket.Accept(w, r)
+		if e != nil {
+			return
+		}
+		b.ServeConn(n)
+	}))
+	defer s.Close()
+	body := strings.Repeat("websocket ", testBodyLen/10)
+	for _, p := range stompngo.Protocols() {
+		ctx, cf := context.WithTimeout(context.Background(), testWait)
+		c, e := websocket.Dial(ctx, wsURL(s), connHeaders(p))
+		if e != nil {
+			t.Fatalf("TestWebSocketRoundTrip Expected [nil], got [%v]\n", e)
+		}
+		if c.Protocol() != p {
+			t.Fatalf("TestWebSocketRoundTrip Expected [%s], got [%s]\n", p, c.Protocol())
+		}
+		d := "/queue/websocket.rt." + p
+		sc, e := c.Subscribe(stompngo.Headers{stompngo.HK_DESTINATION, d,
+			stompngo.HK_ID, "rt"})
+		if e != nil {
+			t.Fatalf("TestWebSocketRoundTrip Expected [nil], got [%v]\n", e)
+		}
+		e = c.SendWithReceipt(ctx, stompngo.Headers{stompngo.HK_DESTINATION, d}, body)
+		if e != nil {
+			t.Fatalf("TestWebSocketRoundTrip Expected [nil], got [%v]\n", e)
+		}
+		select {
+		case md := <-sc:
+			if md.Error != nil || md.Message.BodyString() != body {
+				t.Fatalf("TestWebSocketRoundTrip Expected the body, got [%d] bytes [%v]\n",
+					len(md.Message.Body), md.Error)
+			}
+		case <-ctx.Done():
+			t.Fatalf("TestWebSocketRoundTrip Expected a message, got none\n")
+		}
+		if e = c.Disconnect(stompngo.Headers{}); e != nil {
+			t.Fatalf("TestWebSocketRoundTrip Expected [nil], got [%v]\n", e)
+		}
+		cf()
+	}
+}
+
+/*
+	Test subprotocol selection narrows accept-version, and that batched
+	frames and heart beats are each sent as one message.
+*/
+func TestWebSocketMessages(t *testing.T) {
+	got := make(chan []string, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wc, e := ws.Accept(w, r, &ws.AcceptOptions{Subprotocols: []string{"v11.stomp"}})
+		if e != nil {
+			return
+		}
+		defer wc.CloseNow()
+		wc.SetReadLimit(-1)
+		var ms []string
+		defer func() { got <- ms }()
+		for {
+			_, m, e := wc.Read(context.Background())
+			if e != nil {
+				return
+			}
+			ms = append(ms, string(m))
+			f, _ := stompngo.NewFrameReader(bytes.NewReader(m),
+				stompngo.DefaultReadLimits).ReadFrame()
+			var rf stompngo.Frame
+			switch f.Command {
+			case stompngo.CONNECT, stompngo.STOMP:
+				rf = stompngo.Frame{Command: stompngo.CONNECTED,
+					Headers: stompngo.Headers{stompngo.HK_VERSION, stompngo.SPL_11,
+						stompngo.HK_HEART_BEAT, "0,50"}}
+			case stompngo.DISCONNECT:
+				rf = stompngo.Frame{Command: stompngo.RECEIPT,
+					Headers: stompngo.Headers{stompngo.HK_RECEIPT_ID,
+						f.Headers.Value(stompngo.HK_RECEIPT)}}
+			default:
+				continue
+			}
+			var bb bytes.Buffer
+			if stompngo.NewFrameWriter(&bb).WriteFrame(rf) != nil ||
+				wc.Write(context.Background(), ws.MessageText, bb.Bytes()) != nil {
+				return
+			}
+		}
+	}))
+	defer s.Close()
+	ctx, cf := context.WithTimeout(context.Background(), testWait)
+	defer cf()
+	c, e := websocket.Dial(ctx, wsURL(s), connHeaders("1.0,1.1,1.2").
+		Add(stompngo.HK_HEART_BEAT, "50,0"))
+	if e != nil {
+		t.Fatalf("TestWebSocketMessages Expected [nil], got [%v]\n", e)
+	}
+	if c.Protocol() != stompngo.SPL_11 {
+		t.Fatalf("TestWebSocketMessages Expected [%s], got [%s]\n", stompngo.SPL_11,
+			c.Protocol())
+	}
+	c.SetWriteBatch(testSends, 50*time.Millisecond)
+	body := strings.Repeat("x", testBodyLen)
+	var fs []*stompngo.SendFuture
+	for i := 0; i < testSends; i++ {
+		fs = append(fs, c.SendAsync(ctx, stompngo.Headers{stompngo.HK_DESTINATION,
+			"/queue/websocket.msgs"}, body))
+	}
+	for _, f := range fs {
+		if e = f.Wait(ctx); e != nil {
+			t.Fatalf("TestWebSocketMessages Expected [nil], got [%v]\n", e)
+		}
+	}
+	time.Sleep(200 * time.Millisecond) // Heart beats
+	if e = c.Disconnect(stompngo.Headers{}); e != nil {
+		t.Fatalf("TestWebSocketMessages Expected [nil], got [%v]\n", e)
+	}
+	ms := <-got
+	if !strings.Contains(ms[0], "accept-version:1.1\n") {
+		t.Fatalf("TestWebSocketMessages Expected accept-version 1.1, got [%q]\n", ms[0])
+	}
+	sends, beats := 0, 0
+	for _, m := range ms {
+		if m == "\n" {
+			beats++
+			continue
+		}
+		fr := stompngo.NewFrameReader(strings.NewReader(m), stompngo.DefaultReadLimits)
+		f, e := fr.ReadFrame()
+		if e != nil {
+			t.Fatalf("TestWebSocketMessages Expected a frame, got [%v]\n", e)
+		}
+		if _, e = fr.ReadFrame(); e != io.EOF {
+			t.Fatalf("TestWebSocketMessages Expected one frame per message, got [%v]\n", e)
+		}
+		if f.Command == stompngo.SEND {
+			if len(f.Body) != testBodyLen {
+				t.Fatalf("TestWebSocketMessages Expected [%d] bytes, got [%d]\n",
+					testBodyLen, len(f.Body))
+			}
+			sends++
+		}
+	}
+	if sends != testSends || beats == 0 {
+		t.Fatalf("TestWebSocketMessages Expected [%d] sends and heart beats, got [%d] [%d]\n",
+			testSends, sends, beats)
+	}
+}
+
+const (
+	testBodyLen = 100000
+	testSends   = 3
+)
@@ -19,6 +19,7 @@ package stompngo
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"net"
 
 	// "bytes"
@@ -26,40 +27,30 @@ import (
 	"time"
 )
 
-/*
-	Write data to logical network writer.  Writer will take care of the output wire data.
-	If the underlying connection goes bad and writer give up working, the closed ssdc chan
-	will make sure write action aware that happens.
-*/
-func (c *Connection) writeWireData(wd wiredata) error {
-	select {
-	case c.output <- wd:
-	case <-c.ssdc:
-		return ECONBAD
-	}
-	return nil
-}
-
 /*
 	Logical network writer.  Read wiredata structures from the communication
 	channel, and put the frame on the wire.
 */
 func (c *Connection) writer() {
+	var ds []wiredata // Current batch
+	var es []error    // Current batch results
 writerLoop:
 	for {
 		select {
 		case d := <-c.output:
 			c.log("WTR_WIREWRITE start")
+			ds = c.writeBatch(append(ds[:0], d))
+			es = append(es[:0], make([]error, len(ds))...)
 			if c.eltd != nil {
 				st := time.Now().UnixNano()
-				c.wireWrite(d)
-				c.eltd.wov.ens += time.Now().UnixNano() - st
-				c.eltd.wov.ec++
+				c.wireWriteBatch(ds, es)
+				c.eltd.add(&c.eltd.wov, "WOV", st)
 			} else {
-				c.wireWrite(d)
+				c.wireWriteBatch(ds, es)
 			}
-
-			if d.frame.Command == DISCONNECT {
+			last := ds[len(ds)-1].frame.Command
+			clear(ds)
+			if last == DISCONNECT {
 				break writerLoop // we are done with this connection
 			}
 		case _ = <-c.ssdc:
@@ -77,22 +68,125 @@ writerLoop:
 }
 
 /*
-	Connection logical write.
+	Add queued frames to a batch holding one frame, per SetWriteBatch.  A
+	DISCONNECT always ends a batch.
+*/
+func (c *Connection) writeBatch(ds []wiredata) []wiredata {
+	mx := int(c.wbmx.Load())
+	if len(ds) >= mx || ds[0].frame.Command == DISCONNECT {
+		return ds
+	}
+	var lc <-chan time.Time
+	if ln := time.Duration(c.wbln.Load()); ln > 0 {
+		t := time.NewTimer(ln)
+		defer t.Stop()
+		lc = t.C
+	}
+	for len(ds) < mx {
+		var d wiredata
+		if lc == nil {
+			select {
+			case d = <-c.output:
+			default:
+				return ds
+			}
+		} else {
+			select {
+			case d = <-c.output:
+			case <-lc:
+				return ds
+			case <-c.ssdc:
+				return ds
+			case <-c.wtrsdc:
+				return ds
+			}
+		}
+		ds = append(ds, d)
+		if d.frame.Command == DISCONNECT {
+			break
+		}
+	}
+	return ds
+}
+
+/*
+	Write a batch of frames with one flush, and report the result of each.
+	The last frame flushes the batch.  If it was not written, the batch is
+	flushed here, and a flush error is reported for every frame in the
+	buffer.
+*/
+func (c *Connection) wireWriteBatch(ds []wiredata, es []error) {
+	n := len(ds) - 1
+	for i := range ds {
+		es[i] = c.wireWrite(ds[i], i == n)
+	}
+	var fe error
+	if es[n] != nil && n > 0 {
+		fe = c.wireFlush()
+	}
+	for i := range ds {
+		if es[i] == nil && i < n {
+			es[i] = fe
+		}
+		ds[i].report(es[i])
+	}
+}
+
+/*
+	Connection logical write, flushed if flush is true.
+*/
+func (c *Connection) wireWrite(d wiredata, flush bool) error {
+	// Nothing is on the wire yet, so a done context is simply reported.
+	if e := d.ctx.Err(); e != nil {
+		return e
+	}
+	if d.ctx.Done() == nil { // Can never be canceled
+		return c.wireWriteFrame(&d.frame, d.body, flush)
+	}
+	stop := c.watchWriteContext(d.ctx)
+	e := c.wireWriteFrame(&d.frame, d.body, flush)
+	if stop() {
+		_ = c.netconn.SetWriteDeadline(c.dld.t0)
+		if e != nil {
+			// The wire write was interrupted, possibly part way through a
+			// frame.  The stream can not be trusted after that.
+			c.log("WTR_WIREWRITE context done", d.ctx.Err())
+			e = d.ctx.Err()
+			c.sysAbort()
+		}
+	}
+	return e
+}
+
+/*
+	Flush written frames to the wire.
+*/
+func (c *Connection) wireFlush() error {
+	if e := c.wtr.Flush(); e != nil {
+		return e
+	}
+	if c.hbd != nil {
+		c.hbd.sdl.Lock()
+		c.hbd.ls = time.Now().UnixNano() // Latest good send
+		c.hbd.sdl.Unlock()
+	}
+	return nil
+}
+
+/*
+	Put a single frame on the wire, and flush if flush is true.  A non-nil bs
+	supplies the frame body.
 */
-func (c *Connection) wireWrite(d wiredata) {
-	f := &d.frame
+func (c *Connection) wireWriteFrame(f *Frame, bs *sendStream, flush bool) error {
 	// fmt.Printf("WWD01 f:[%v]\n", f)
 	switch f.Command {
 	case "\n": // HeartBeat frame
-		if c.dld.wde && c.dld.wds {
-			_ = c.netconn.SetWriteDeadline(time.Now().Add(c.dld.wdld))
-		}
+		c.armWriteDeadline()
 		var e error
 		if c.eltd != nil {
 			st := time.Now().UnixNano()
 			_, e = c.wtr.WriteString(f.Command)
-			c.eltd.wcmd.ens += time.Now().UnixNano() - st
-			c.eltd.wcmd.ec++
+			c.eltd.add(&c.eltd.wcmd, "WCMD", st)
 		} else {
 			_, e = c.wtr.WriteString(f.Command)
 		}
@@ -103,40 +197,34 @@ func (c *Connection) wireWrite(d wiredata) {
 					c.dld.dlnotify(e, true)
 				}
 			}
-			d.errchan <- e
-			return
+			return e
 		}
 	default: // Other frames
-		if e := f.writeFrame(c.wtr, c); e != nil {
-			d.errchan <- e
-			return
-		}
-		if e := c.wtr.Flush(); e != nil {
-			d.errchan <- e
-			return
+		if e := f.writeFrame(c.wtr, c, bs); e != nil {
+			return e
 		}
+		c.logx("send", f)
 	}
-	if e := c.wtr.Flush(); e != nil {
-		d.errchan <- e
-		return
+	if flush {
+		if e := c.wireFlush(); e != nil {
+			return e
+		}
 	}
 	//
-	if c.hbd != nil {
-		c.hbd.sdl.Lock()
-		c.hbd.ls = time.Now().UnixNano() // Latest good send
-		c.hbd.sdl.Unlock()
+	sz := f.Size(f.encoded(c.Protocol()))
+	if bs != nil {
+		sz += bs.n
 	}
-	c.mets.tfw++                // Frame written count
-	c.mets.tbw += f.Size(false) // Bytes written count
+	c.mets.write(f.Command, sz)
 	//
-	d.errchan <- nil
-	return
+	return nil
 }
 
 /*
-	Physical frame write to the wire.
+	Physical frame write to the wire.  A non-nil bs supplies the body, and
+	its length is always sent as the content-length.
 */
-func (f *Frame) writeFrame(w *bufio.Writer, c *Connection) error {
+func (f *Frame) writeFrame(w *bufio.Writer, c *Connection, bs *sendStream) error {
 
 	var sctok bool
 	// Content type.  Always add it if the client does not suppress and does not
@@ -153,18 +241,18 @@ func (f *Frame) writeFrame(w *bufio.Writer, c *Connection) error {
 	// Content length - Always add it if client does not suppress it and
 	// does not supply it.
 	_, sclok = f.Headers.Contains(HK_SUPPRESS_CL)
-	if !sclok {
+	if bs != nil {
+		sclok = false
+		f.Headers = f.Headers.Delete(HK_CONTENT_LENGTH).Add(HK_CONTENT_LENGTH,
+			strconv.FormatInt(bs.n, 10))
+	} else if !sclok {
 		if _, clok := f.Headers.Contains(HK_CONTENT_LENGTH); !clok {
 			f.Headers = append(f.Headers, HK_CONTENT_LENGTH, strconv.Itoa(len(f.Body)))
 		}
 	}
-	// Encode the headers if needed
-	if c.Protocol() > SPL_10 && f.Command != CONNECT {
-		for i := 0; i < len(f.Headers); i += 2 {
-			f.Headers[i] = encode(f.Headers[i])
-			f.Headers[i+1] = encode(f.Headers[i+1])
-		}
-	}
+	// Encode the headers if needed.  They are encoded as written, and the
+	// frame keeps the values supplied.
+	enc := f.encoded(c.Protocol())
 
 	if sclok {
 		nz := bytes.IndexByte(f.Body, 0)
@@ -178,9 +266,7 @@ func (f *Frame) writeFrame(w *bufio.Writer, c *Connection) error {
 		}
 	}
 
-	if c.dld.wde && c.dld.wds {
-		_ = c.netconn.SetWriteDeadline(time.Now().Add(c.dld.wdld))
-	}
+	c.armWriteDeadline()
 
 	// Writes start
 
@@ -188,11 +274,10 @@ func (f *Frame) writeFrame(w *bufio.Writer, c *Connection) error {
 	var e error
 	if c.eltd != nil {
 		st := time.Now().UnixNano()
-		_, e = w.WriteString(f.Command + "\n")
-		c.eltd.wcmd.ens += time.Now().UnixNano() - st
-		c.eltd.wcmd.ec++
+		e = writeLine(w, f.Command)
+		c.eltd.add(&c.eltd.wcmd, "WCMD", st)
 	} else {
-		_, e = w.WriteString(f.Command + "\n")
+		e = writeLine(w, f.Command)
 	}
 
 	if c.checkWriteError(e) != nil {
@@ -201,17 +286,14 @@ func (f *Frame) writeFrame(w *bufio.Writer, c *Connection) error {
 	// fmt.Println("WRCMD", f.Command)
 	// Write the frame Headers
 	for i := 0; i < len(f.Headers); i += 2 {
-		if c.dld.wde && c.dld.wds {
-			_ = c.netconn.SetWriteDeadline(time.Now().Add(c.dld.wdld))
-		}
+		c.armWriteDeadline()
 
 		if c.eltd != nil {
 			st := time.Now().UnixNano()
-			_, e = w.WriteString(f.Headers[i] + ":" + f.Headers[i+1] + "\n")
-			c.eltd.wivh.ens += time.Now().UnixNano() - st
-			c.eltd.wivh.ec++
+			e = writeHeader(w, f.Headers[i], f.Headers[i+1], enc)
+			c.eltd.add(&c.eltd.wivh, "WIVH", st)
 		} else {
-			_, e = w.WriteString(f.Headers[i] + ":" + f.Headers[i+1] + "\n")
+			e = writeHeader(w, f.Headers[i], f.Headers[i+1], enc)
 		}
 
 		if c.checkWriteError(e) != nil {
@@ -221,9 +303,7 @@ func (f *Frame) writeFrame(w *bufio.Writer, c *Connection) error {
 	}
 
 	// Write the last Header LF
-	if c.dld.wde && c.dld.wds {
-		_ = c.netconn.SetWriteDeadline(time.Now().Add(c.dld.wdld))
-	}
+	c.armWriteDeadline()
 	e = w.WriteByte('\n')
 	if c.checkWriteError(e) != nil {
 		return e
@@ -231,27 +311,47 @@ func (f *Frame) writeFrame(w *bufio.Writer, c *Connection) error {
 	// fmt.Printf("WDBG40 ok:%v\n", sclok)
 
 	// Write the body
-	if len(f.Body) != 0 { // Foolish to write 0 length data
+	if bs != nil {
+		if e := c.writeStream(w, bs); e != nil {
+			return e
+		}
+	} else if len(f.Body) != 0 { // Foolish to write 0 length data
 		// fmt.Println("WRBDY", f.Body)
 		e := c.writeBody(f)
 		if c.checkWriteError(e) != nil {
 			return e
 		}
 	}
-	if c.dld.wde && c.dld.wds {
-		_ = c.netconn.SetWriteDeadline(time.Now().Add(c.dld.wdld))
-	}
+	c.armWriteDeadline()
 	e = w.WriteByte(0)
 	if c.checkWriteError(e) != nil {
 		return e
 	}
 	// End of write loop - set no deadline
 	if c.dld.wde {
-		_ = c.netconn.SetWriteDeadline(c.dld.t0)
+		c.clearWriteDeadline()
 	}
 	return nil
 }
 
+/*
+	Write a frame command line.
+*/
+func writeLine(w *bufio.Writer, s string) error {
+	_, _ = w.WriteString(s)
+	return w.WriteByte('\n')
+}
+
+/*
+	Write a frame header line, encoding the key and value if enc is true.
+*/
+func writeHeader(w *bufio.Writer, k, v string, enc bool) error {
+	_ = writeEncoded(w, k, enc)
+	_ = w.WriteByte(':')
+	_ = writeEncoded(w, v, enc)
+	return w.WriteByte('\n')
+}
+
 func (c *Connection) checkWriteError(e error) error {
 	if e == nil {
 		return e
@@ -274,14 +374,11 @@ func (c *Connection) writeBody(f *Frame) error {
 	var n = 0
 	var e error
 	for {
-		if c.dld.wde && c.dld.wds {
-			_ = c.netconn.SetWriteDeadline(time.Now().Add(c.dld.wdld))
-		}
+		c.armWriteDeadline()
 		if c.eltd != nil {
 			st := time.Now().UnixNano()
 			n, e = c.wtr.Write(f.Body)
-			c.eltd.wbdy.ens += time.Now().UnixNano() - st
-			c.eltd.wbdy.ec++
+			c.eltd.add(&c.eltd.wbdy, "WBDY", st)
 		} else {
 			n, e = c.wtr.Write(f.Body)
 		}
@@ -307,6 +404,38 @@ func (c *Connection) writeBody(f *Frame) error {
 	}
 }
 
+/*
+	Interrupt any blocked wire write when ctx is done, by moving the write
+	deadline into the past.  The returned stop function ends the watch, and
+	reports whether the deadline was moved.
+*/
+func (c *Connection) watchWriteContext(ctx context.Context) func() bool {
+	sc := make(chan struct{})
+	dc := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.dld.wdlk.Lock()
+			c.dld.wabt = true
+			_ = c.netconn.SetWriteDeadline(time.Unix(1, 0))
+			c.dld.wdlk.Unlock()
+			dc <- true
+		case <-sc:
+			dc <- false
+		}
+	}()
+	return func() bool {
+		close(sc)
+		if !<-dc {
+			return false
+		}
+		c.dld.wdlk.Lock()
+		c.dld.wabt = false
+		c.dld.wdlk.Unlock()
+		return true
+	}
+}
+
 func isErrorTimeout(e error) bool {
 	if e == nil {
 		return false